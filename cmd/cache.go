@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"snoman/internal/biputils/isocache"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of generated installer ISOs",
+	Long: fmt.Sprintf(`
+	Manage the cache of generated installer ISOs
+
+	ISOs are cached by a hash of the install config, agent config, custom manifests, release image and
+	installer binary, and reused by later runs with the same inputs. The cache is kept under %d GB by
+	removing the least recently used ISOs. The default cache directory can be changed with $%s
+	`, isocache.DEFAULT_CACHE_SIZE_GB, isocache.CACHE_DIR_ENV),
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Fatalf("Error executing cache command: %v", ErrResourceTypeNotSpecified)
+	},
+}
+
+func initCacheCmd() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.PersistentFlags().String("cache-dir", isocache.DefaultDir(), "The ISO cache directory")
+
+	// Subcommands
+	cacheCmd.AddCommand(cacheListCmd)
+
+	cacheCmd.AddCommand(cachePruneCmd)
+	cachePruneCmd.Flags().Uint("keep-gb", 0, "Keep the most recently used ISOs up to this size. By default every ISO is removed")
+}
+
+// List cached ISOs
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the cached ISOs, most recently used first",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("cache-dir")
+
+		entries, err := isocache.New(dir, 0).List()
+		if err != nil {
+			logger.Fatalf("unable to list the iso cache: %v", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "KEY\tSIZE\tCREATED\tLAST USED\tRELEASE IMAGE")
+		for _, entry := range entries {
+			fmt.Fprintf(tw, "%s\t%dM\t%s\t%s\t%s\n", entry.Key[:min(len(entry.Key), 12)], entry.Size/(1024*1024), entry.Created.Format(time.RFC3339), entry.LastUsed.Format(time.RFC3339), entry.ReleaseImage)
+		}
+		tw.Flush()
+	},
+}
+
+// Prune cached ISOs
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cached ISOs, least recently used first",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, _ := cmd.Flags().GetString("cache-dir")
+		keepGB, _ := cmd.Flags().GetUint("keep-gb")
+
+		cache := isocache.New(dir, keepGB)
+		removed, err := cache.Prune(cache.MaxBytes)
+		if err != nil {
+			logger.Fatalf("unable to prune the iso cache: %v", err)
+		}
+
+		var freed uint64
+		for _, entry := range removed {
+			freed += entry.Size
+		}
+
+		logger.Infof("removed %d cached isos, freeing %dM", len(removed), freed/(1024*1024))
+	},
+}
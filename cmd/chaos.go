@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"snoman/internal/chaos"
+	"snoman/internal/vms/machines"
+	"snoman/internal/workflows/bip"
+
+	"github.com/spf13/cobra"
+)
+
+var chaosCmd = &cobra.Command{
+	Use:   "chaos",
+	Short: "Inject faults into a virtual machine for resilience testing",
+	Long: `
+	Inject faults into a virtual machine for resilience testing
+
+	Every fault is applied through libvirt and logged. Faults that can be undone record what they
+	changed on the domain, so the matching restore command works from a later invocation
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Fatalf("Error executing chaos command: %v", ErrResourceTypeNotSpecified)
+	},
+}
+
+// chaosActions are the single fault commands, each one runs the fault action of the same name
+var chaosActions = []struct {
+	action    string
+	short     string
+	iface     bool
+	disk      bool
+	needsDisk bool
+}{
+	{chaos.ACTION_LINK_DOWN, "Set the link of the VM interfaces down", true, false, false},
+	{chaos.ACTION_LINK_UP, "Set the link of the VM interfaces back up", true, false, false},
+	{chaos.ACTION_PAUSE, "Pause the VM", false, false, false},
+	{chaos.ACTION_RESUME, "Resume a paused VM", false, false, false},
+	{chaos.ACTION_RESET, "Force reset the VM", false, false, false},
+	{chaos.ACTION_DETACH_DISK, "Hot unplug a disk from the VM", false, true, true},
+	{chaos.ACTION_ATTACH_DISK, "Plug a disk detached by detach-disk back into the VM", false, true, true},
+	{chaos.ACTION_ISOLATE_NIC, "Move the VM interfaces onto an isolated copy of their network, nothing else on it answers", true, false, false},
+	{chaos.ACTION_RESTORE_NIC, "Move interfaces isolated by isolate-nic back onto their network", true, false, false},
+	{chaos.ACTION_CUT_FORWARD, "Remove the forward of the VM networks, the host still reaches every VM on them", true, false, false},
+	{chaos.ACTION_RESTORE_FORWARD, "Put back the forward removed by cut-forward", true, false, false},
+}
+
+func initChaosCmd() {
+	rootCmd.AddCommand(chaosCmd)
+
+	// Subcommands
+	for _, action := range chaosActions {
+		actionCmd := &cobra.Command{
+			Use:   fmt.Sprintf("%s [name or uuid]", action.action),
+			Short: action.short,
+			Run:   runChaosAction(action.action),
+		}
+
+		if action.iface {
+			actionCmd.Flags().String("interface", "", "The MAC address or guest name of the interface. Defaults to every interface")
+		}
+
+		if action.disk {
+			actionCmd.Flags().String("disk", "", "[Required] The target device of the disk, ex: vdb")
+			actionCmd.MarkFlagRequired("disk")
+		}
+
+		chaosCmd.AddCommand(actionCmd)
+	}
+
+	chaosCmd.AddCommand(chaosRunCmd)
+	chaosRunCmd.Flags().String("vm", machines.DEFAULT_VM_NAME, "The VM for faults that do not name one")
+}
+
+func runChaosAction(action string) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		fault := chaos.FaultSpec{
+			Action: action,
+			VM:     machines.DEFAULT_VM_NAME,
+		}
+
+		if len(args) > 0 {
+			fault.VM = args[0]
+		}
+
+		// Only some actions have these flags
+		fault.Interface, _ = cmd.Flags().GetString("interface")
+		fault.Disk, _ = cmd.Flags().GetString("disk")
+
+		if err := chaos.Inject(cmd.Context(), fault); err != nil {
+			logger.Fatal(err)
+		}
+	}
+}
+
+// Chaos Schedule
+var chaosRunCmd = &cobra.Command{
+	Use:   "run [schedule file]",
+	Short: "Inject the faults of a schedule file",
+	Long: fmt.Sprintf(`
+	Inject the faults of a schedule file and wait until all of them have been injected and undone
+
+	Example schedule:
+
+	  vm: default-sno-vm
+	  faults:
+	    - action: link-down
+	      phase: %s
+	      at: 5m
+	      duration: 30s
+	    - action: pause
+	      at: 10m
+	      duration: 1m
+	    - action: detach-disk
+	      disk: vdb
+	      at: 12m
+
+	Faults are injected "at" after the schedule starts, or after their phase is reached when one is set.
+	Faults with a duration are undone once it passes, or when the schedule is interrupted.
+	The %s phase is reached when the VM starts. The %s and %s phases are only reached when the
+	schedule is run by a workflow, ex: run bootstrap-in-place --chaos-schedule
+	`, chaos.PHASE_VM_STARTED, chaos.PHASE_VM_STARTED, bip.PHASE_INSTALLER_GENERATED, bip.PHASE_BOOTING),
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		spec, err := loadChaosSchedule(args[0])
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		if spec.VM == "" {
+			spec.VM, _ = cmd.Flags().GetString("vm")
+		}
+
+		scheduler := chaos.NewScheduler(spec)
+		if err := scheduler.Start(cmd.Context()); err != nil {
+			logger.Fatalf("unable to start the chaos schedule: %v", err)
+		}
+
+		// Interrupting stops the schedule, which undoes any active faults
+		go func() {
+			<-cmd.Context().Done()
+			scheduler.Stop()
+		}()
+
+		scheduler.Wait()
+	},
+}
+
+func loadChaosSchedule(path string) (*chaos.ScheduleSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the chaos schedule: %w", err)
+	}
+
+	spec := &chaos.ScheduleSpec{}
+	if err := spec.UnmarshalYAML(data); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal the chaos schedule: %w", err)
+	}
+
+	return spec, nil
+}
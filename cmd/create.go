@@ -43,8 +43,10 @@ func initCreateCmd() {
 	createBootstrapIsoCmd.Flags().String("abi-path", biputils.DEFAULT_ABI_PATH, fmt.Sprintf("Path to the agent based installer (default: %s)", biputils.DEFAULT_ABI_PATH))
 	createBootstrapIsoCmd.Flags().String("custom-manifests", "", "Path to the folder containing custom manifests to be added to the ISO")
 	createBootstrapIsoCmd.Flags().String("ocp", biputils.DEFAULT_OPENSHIFT_VERSION, fmt.Sprintf("The version of OCP to use in the ISO (default: %s)", biputils.DEFAULT_OPENSHIFT_VERSION))
-	createBootstrapIsoCmd.Flags().String("arch", biputils.DEFAULT_OPENSHIFT_ARCH, fmt.Sprintf("The OCP arch to use in the ISO (default: %s)", biputils.DEFAULT_OPENSHIFT_ARCH))
+	createBootstrapIsoCmd.Flags().String("arch", biputils.DEFAULT_OPENSHIFT_ARCH, fmt.Sprintf("The OCP arch to use in the ISO, ex: x86_64, aarch64 or arm64 (default: %s)", biputils.DEFAULT_OPENSHIFT_ARCH))
 	createBootstrapIsoCmd.Flags().String("release-image", "", "The pull spec of the OCP release image to use for creating the ISO")
+	createBootstrapIsoCmd.Flags().Bool("no-cache", false, "Always run the installer instead of reusing a cached ISO with the same inputs")
+	addReleaseFlags(createBootstrapIsoCmd)
 }
 
 // Create VM
@@ -56,12 +58,12 @@ var createVmCmd = &cobra.Command{
 		host := spec.Network.Hosts[0]
 		spec.Network.Hosts = nil
 
-		err := machines.CreateVirtualMachine(spec)
+		err := machines.CreateVirtualMachine(cmd.Context(), spec)
 		if err != nil {
 			logger.Error(err)
 		}
 
-		if err := network.AddHostToNetwork(spec.Network.UUID, &host); err != nil {
+		if err := network.AddHostToNetwork(cmd.Context(), spec.Network.UUID, &host); err != nil {
 			logger.Error(err)
 		}
 	},
@@ -104,7 +106,7 @@ var createNetCmd = &cobra.Command{
 			spec = network.GetDefaultVirtualMachineNetworkSpec()
 		}
 
-		err := network.Create(spec)
+		err := network.Create(cmd.Context(), spec)
 		if err != nil {
 			logger.Error(err)
 		}
@@ -136,8 +138,10 @@ var createBootstrapIsoCmd = &cobra.Command{
 		spec.OpenshiftVersion, _ = cmd.Flags().GetString("ocp")
 		spec.OpenshiftArch, _ = cmd.Flags().GetString("arch")
 		spec.ReleaseImage, _ = cmd.Flags().GetString("release-image")
+		spec.DisableCache, _ = cmd.Flags().GetBool("no-cache")
+		setReleaseFlags(cmd, spec)
 
-		if err := biputils.GenerateIso(spec, workdir); err != nil {
+		if err := biputils.GenerateIso(cmd.Context(), spec, workdir); err != nil {
 			logger.Fatalf("unable to generate bootstrap iso: %v", err)
 		}
 
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"snoman/internal/vms/machines"
 	"snoman/internal/vms/network"
 
 	"github.com/spf13/cobra"
@@ -19,6 +20,30 @@ func initDestroyCmd() {
 
 	// Subcommands
 	destroyCmd.AddCommand(destroyNetCmd)
+	destroyCmd.AddCommand(destroyVmCmd)
+}
+
+// Destroy VM
+var destroyVmCmd = &cobra.Command{
+	Use:   "vm [name or uuid]",
+	Short: "Destroy a libvirt VM by name or UUID",
+	Long: `
+	Destroy a libvirt VM by name or UUID along with any volumes snoman created for it
+
+	if no name or UUID is provided, the default VM name will be used
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		vmname := machines.DEFAULT_VM_NAME
+
+		if len(args) > 0 {
+			vmname = args[0]
+		}
+
+		err := machines.Destroy(cmd.Context(), vmname)
+		if err != nil {
+			logger.Error(err)
+		}
+	},
 }
 
 // Destroy VM Network
@@ -37,7 +62,7 @@ var destroyNetCmd = &cobra.Command{
 			netname = args[0]
 		}
 
-		err := network.Destroy(netname)
+		err := network.Destroy(cmd.Context(), netname)
 		if err != nil {
 			logger.Error(err)
 		}
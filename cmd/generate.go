@@ -26,7 +26,9 @@ func initGenerateCmd() {
 	// Subcommands
 	// Generate VM configuration
 	generateCmd.AddCommand(generateVmCmd)
+	generateVmCmd.Flags().String("from-vm", "", "Name or UUID of the libvirt domain to use as a base")
 	generateVmCmd.Flags().String("from", "", "Path to the spec file to use for spec generation. This will just sanitize the input.")
+	generateVmCmd.Flags().Bool("xml", false, "Generate the libvirt xml config for the machine")
 
 	// Generate network configuration
 	generateCmd.AddCommand(generateNetCmd)
@@ -41,6 +43,11 @@ func initGenerateCmd() {
 	generateBipAgentConfigCmd.Flags().String("host-ip", "", "IP address that the resulting host will use")
 	generateBipAgentConfigCmd.Flags().String("host-mac", "", "MAC address that the resulting host will use")
 	generateBipAgentConfigCmd.Flags().String("host-route", "", "Route that the resulting host will use")
+	generateBipAgentConfigCmd.Flags().String("rendezvous-ip", "", "IP address of the host that runs the installation service. Defaults to the host IP")
+	generateBipAgentConfigCmd.Flags().String("role", bipagentconfig.DEFAULT_HOST_ROLE, "The role of the host, master or worker")
+	generateBipAgentConfigCmd.Flags().StringToString("root-device-hints", nil, "Hints selecting the install disk, ex: deviceName=/dev/vda,minSizeGigabytes=100")
+	generateBipAgentConfigCmd.Flags().StringSlice("ntp-source", nil, "Additional NTP servers for the host")
+	generateBipAgentConfigCmd.Flags().StringSlice("dns-server", nil, "Additional DNS servers for the host, used after the host route")
 
 	// Generate BIP install config
 	generateCmd.AddCommand(generateBipInstallConfigCmd)
@@ -52,6 +59,15 @@ func initGenerateCmd() {
 	generateBipInstallConfigCmd.Flags().String("install-disk", "", "The install disk path")
 	generateBipInstallConfigCmd.Flags().String("pull-secret-file", "", "Path to the file containing the cluster pull secret. If left empty the PULL_SECRET env variable will be used")
 	generateBipInstallConfigCmd.Flags().String("ssh-pub-key", "", "[Required] Path to the file containing the cluster ssh public key")
+	generateBipInstallConfigCmd.Flags().String("arch", bipinstallconfig.DEFAULT_ARCHITECTURE, "The cluster cpu architecture, ex: amd64, arm64, x86_64, aarch64")
+	generateBipInstallConfigCmd.Flags().String("network-type", bipinstallconfig.DEFAULT_NETWORK_TYPE, "The cluster network plugin, OVNKubernetes or OpenShiftSDN")
+	generateBipInstallConfigCmd.Flags().Uint("host-prefix", bipinstallconfig.DEFAULT_HOST_PREFIX, "The prefix length of the pod subnet each node gets from the cluster network")
+	generateBipInstallConfigCmd.Flags().String("publish", bipinstallconfig.DEFAULT_PUBLISH, "How the cluster endpoints are published, External or Internal")
+	generateBipInstallConfigCmd.Flags().Bool("fips", false, "Install the cluster in FIPS mode")
+	generateBipInstallConfigCmd.Flags().String("baseline-capabilities", "", "The baseline capability set, ex: None, vCurrent, v4.14. The cluster default is used when empty")
+	generateBipInstallConfigCmd.Flags().StringSlice("capabilities", nil, "Capabilities to enable on top of the baseline, ex: marketplace,NodeTuning")
+	addMirrorFlags(generateBipInstallConfigCmd)
+	addProxyFlags(generateBipInstallConfigCmd)
 
 }
 
@@ -62,10 +78,12 @@ var generateVmCmd = &cobra.Command{
 	Long: `
 	Generate a libvirt machine spec
 
-	If --from is not specified, the default machine configuration will be used
+	If --from or --from-vm are not specified, the default machine configuration will be used
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
+		outputXML, _ := cmd.Flags().GetBool("xml")
 		source, _ := cmd.Flags().GetString("from")
+		vmSource, _ := cmd.Flags().GetString("from-vm")
 
 		var spec *machines.VirtualMachineSpec
 		if source != "" {
@@ -79,11 +97,24 @@ var generateVmCmd = &cobra.Command{
 			if err := spec.UnmarshalYAML(data); err != nil {
 				logger.Fatalf("unable to parse the provided machine spec: %w", err)
 			}
+		} else if vmSource != "" {
+			var err error
+			spec, err = machines.Find(cmd.Context(), vmSource)
+			if err != nil {
+				logger.Fatalf("could not generate spec from virtual machine: %v", err)
+			}
 		} else {
 			spec = machines.GetDefaultVirtualMachineSpec()
 		}
 
-		output, err := spec.MarshalYAML()
+		var output string
+		var err error
+		if outputXML {
+			output, err = spec.MarshalXML()
+		} else {
+			output, err = spec.MarshalYAML()
+		}
+
 		if err != nil {
 			logger.Fatalf("unable to generate the machine spec: %w", err)
 		}
@@ -126,7 +157,7 @@ var generateNetCmd = &cobra.Command{
 			spec.UnmarshalXML(data)
 		} else if netSource != "" {
 			var err error
-			spec, err = network.Find(netSource)
+			spec, err = network.Find(cmd.Context(), netSource)
 			if err != nil {
 				logger.Fatalf("could not generate spec from network: %v", err)
 			}
@@ -174,7 +205,7 @@ var generateBipAgentConfigCmd = &cobra.Command{
 		// Host MAC
 		acspec.HostMAC = srcspec.Network.Hosts[0].MacAddress
 		if hostmac, _ := cmd.Flags().GetString("host-mac"); hostmac != "" {
-			srcspec.Network.Hosts[0].MacAddress = hostmac
+			acspec.HostMAC = hostmac
 		}
 
 		// Host Route
@@ -183,6 +214,20 @@ var generateBipAgentConfigCmd = &cobra.Command{
 			acspec.HostRoute = hostroute
 		}
 
+		// Host options
+		acspec.RendezvousIP, _ = cmd.Flags().GetString("rendezvous-ip")
+		acspec.Role, _ = cmd.Flags().GetString("role")
+		acspec.NTPSources, _ = cmd.Flags().GetStringSlice("ntp-source")
+		acspec.DNSServers, _ = cmd.Flags().GetStringSlice("dns-server")
+
+		hints, _ := cmd.Flags().GetStringToString("root-device-hints")
+
+		var err error
+		acspec.RootDeviceHints, err = bipagentconfig.ParseRootDeviceHints(hints)
+		if err != nil {
+			logger.Fatalf("unable to parse root device hints: %v", err)
+		}
+
 		config, err := bipagentconfig.GetBipAgentConfig(acspec)
 		if err != nil {
 			logger.Fatalf("unable to generate bootstrap agent config: %v", err)
@@ -225,17 +270,34 @@ var generateBipInstallConfigCmd = &cobra.Command{
 		}
 
 		if machineNetworkCIDR, _ := cmd.Flags().GetString("machine-network-cidr"); machineNetworkCIDR != "" {
-			icspec.ClusterNetwork = machineNetworkCIDR
+			icspec.MachineNetwork = machineNetworkCIDR
 		}
 
 		if installdisk, _ := cmd.Flags().GetString("install-disk"); installdisk != "" {
 			icspec.InstallDisk = installdisk
 		}
 
+		// Cluster options
+		icspec.Architecture, _ = cmd.Flags().GetString("arch")
+		icspec.NetworkType, _ = cmd.Flags().GetString("network-type")
+		icspec.HostPrefix, _ = cmd.Flags().GetUint("host-prefix")
+		icspec.Publish, _ = cmd.Flags().GetString("publish")
+		icspec.FIPS, _ = cmd.Flags().GetBool("fips")
+		icspec.BaselineCapabilitySet, _ = cmd.Flags().GetString("baseline-capabilities")
+		icspec.AdditionalCapabilities, _ = cmd.Flags().GetStringSlice("capabilities")
+
+		icspec.Proxy = getProxySpec(cmd)
+
+		// Disconnected installs
+		var err error
+		icspec.Mirror, err = getMirrorSpec(cmd)
+		if err != nil {
+			logger.Fatalf("unable to configure the mirror registry: %v", err)
+		}
+
 		// Pull Secret
 		pullSecretFile, _ := cmd.Flags().GetString("pull-secret-file")
 
-		var err error
 		icspec.PullSecret, err = secrets.GetPullSecret(pullSecretFile)
 		if err != nil {
 			logger.Fatalf("unable to parse pull secret: %v", err)
@@ -250,7 +312,7 @@ var generateBipInstallConfigCmd = &cobra.Command{
 
 		config, err := bipinstallconfig.GetBipInstallConfig(icspec)
 		if err != nil {
-			logger.Fatalf("unable to generate bootstrap agent config: %v", err)
+			logger.Fatalf("unable to generate bootstrap install config: %v", err)
 		}
 
 		fmt.Println(config)
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"snoman/internal/biputils/installconfig"
+
+	"github.com/spf13/cobra"
+)
+
+// addMirrorFlags will add the disconnected install flags to a command that generates an install config
+func addMirrorFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("mirror", nil, "Pull images of a source repository from a mirror, ex: quay.io/openshift-release-dev/ocp-release=mirror.lab:5000/ocp/release. Can be repeated")
+	cmd.Flags().String("mirror-config", "", "Path to an ImageDigestMirrorSet or ImageContentSourcePolicy yaml with the mirrors, ex: from oc-mirror")
+	cmd.Flags().String("mirror-ca-file", "", "Path to the PEM CA bundle of the mirror registry, added to the cluster trust bundle")
+	cmd.Flags().String("mirror-pull-secret-file", "", "Path to a pull secret with the mirror registry credentials, merged into the cluster pull secret")
+}
+
+// getMirrorSpec will build the mirror spec from the flags, it returns nil when no mirror flag was used
+func getMirrorSpec(cmd *cobra.Command) (*installconfig.MirrorSpec, error) {
+	pairs, _ := cmd.Flags().GetStringArray("mirror")
+	configFile, _ := cmd.Flags().GetString("mirror-config")
+	caFile, _ := cmd.Flags().GetString("mirror-ca-file")
+	pullSecretFile, _ := cmd.Flags().GetString("mirror-pull-secret-file")
+
+	if len(pairs) == 0 && configFile == "" {
+		if caFile != "" || pullSecretFile != "" {
+			return nil, fmt.Errorf("--mirror or --mirror-config is required to use a mirror registry")
+		}
+
+		return nil, nil
+	}
+
+	spec := &installconfig.MirrorSpec{}
+
+	if configFile != "" {
+		sources, err := installconfig.LoadImageDigestSources(configFile)
+		if err != nil {
+			return nil, err
+		}
+		spec.ImageDigestSources = append(spec.ImageDigestSources, sources...)
+	}
+
+	sources, err := installconfig.ParseMirrorMap(pairs)
+	if err != nil {
+		return nil, err
+	}
+	spec.ImageDigestSources = append(spec.ImageDigestSources, sources...)
+
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the mirror CA bundle: %w", err)
+		}
+		spec.AdditionalTrustBundle = string(data)
+	}
+
+	if pullSecretFile != "" {
+		data, err := os.ReadFile(pullSecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the mirror pull secret: %w", err)
+		}
+		spec.PullSecret = string(data)
+	}
+
+	return spec, nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"snoman/internal/vms/storage"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var poolCmd = &cobra.Command{
+	Use:   "pool",
+	Short: "Manage libvirt storage pools",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Fatalf("Error executing pool command: %v", ErrResourceTypeNotSpecified)
+	},
+}
+
+func initPoolCmd() {
+	rootCmd.AddCommand(poolCmd)
+
+	// Subcommands
+	poolCmd.AddCommand(poolCreateCmd)
+	poolCreateCmd.Flags().String("path", "", fmt.Sprintf("Directory backing the pool (default: %s/<name>)", storage.DEFAULT_POOL_ROOT))
+	poolCreateCmd.Flags().StringP("workdir", "w", "", fmt.Sprintf("Root the pool in <workdir>/%s/<name> instead of the default location", storage.POOL_WORKDIR_SUBFOLDER))
+	poolCreateCmd.Flags().Bool("no-autostart", false, "Do not start the pool when libvirt starts")
+
+	poolCmd.AddCommand(poolListCmd)
+
+	poolCmd.AddCommand(poolDestroyCmd)
+	poolDestroyCmd.Flags().Bool("delete-data", false, "Delete every volume in the pool and its directory")
+}
+
+// Create a storage pool
+var poolCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a directory storage pool",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		workdir, _ := cmd.Flags().GetString("workdir")
+		spec := storage.GetDefaultStoragePoolSpec(args[0], workdir)
+
+		if path, _ := cmd.Flags().GetString("path"); path != "" {
+			spec.Path = path
+		}
+
+		if noAutostart, _ := cmd.Flags().GetBool("no-autostart"); noAutostart {
+			spec.Autostart = false
+		}
+
+		if err := storage.CreatePool(cmd.Context(), spec); err != nil {
+			logger.Fatalf("unable to create storage pool: %v", err)
+		}
+	},
+}
+
+// List storage pools
+var poolListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the libvirt storage pools",
+	Run: func(cmd *cobra.Command, args []string) {
+		pools, err := storage.ListPools(cmd.Context())
+		if err != nil {
+			logger.Fatalf("unable to list storage pools: %v", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tACTIVE\tAUTOSTART\tCAPACITY\tAVAILABLE\tPATH")
+		for _, pool := range pools {
+			fmt.Fprintf(tw, "%s\t%t\t%t\t%dG\t%dG\t%s\n", pool.Name, pool.Active, pool.Autostart, pool.CapacityGB, pool.AvailableGB, pool.Path)
+		}
+		tw.Flush()
+	},
+}
+
+// Destroy a storage pool
+var poolDestroyCmd = &cobra.Command{
+	Use:   "destroy [name]",
+	Short: "Stop and undefine a storage pool",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		deleteData, _ := cmd.Flags().GetBool("delete-data")
+
+		if err := storage.DestroyPool(cmd.Context(), args[0], deleteData); err != nil {
+			logger.Fatalf("unable to destroy storage pool: %v", err)
+		}
+	},
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"os"
+	"snoman/internal/biputils"
+	"snoman/internal/vms/machines"
+	vmutils "snoman/internal/vms/utils"
+
+	"github.com/spf13/cobra"
+)
+
+func initPreflightCmd() {
+	rootCmd.AddCommand(preflightCmd)
+
+	preflightCmd.Flags().String("vm-config", "", "Path to the configuration yaml for the virtual machine")
+	preflightCmd.Flags().String("abi-path", "", "Path to the agent based installer. Defaults to the value in the VM config or "+biputils.DEFAULT_ABI_PATH)
+}
+
+// Pre-flight
+// Check the host can run the virtual machine before starting any long running workflow
+var preflightCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Check the host has the resources and tools needed to create a VM",
+	Long: `
+	Check the host has the resources and tools needed to create a VM
+
+	If --vm-config is not specified, the default machine configuration will be used
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var spec *machines.VirtualMachineSpec
+
+		vmConfigFile, _ := cmd.Flags().GetString("vm-config")
+		if vmConfigFile == "" {
+			spec = machines.GetDefaultVirtualMachineSpec()
+		} else {
+			data, err := os.ReadFile(vmConfigFile)
+			if err != nil {
+				logger.Fatalf("unable to read the virtual machine config file: %v", err)
+			}
+
+			spec = &machines.VirtualMachineSpec{}
+			if err := spec.UnmarshalYAML(data); err != nil {
+				logger.Fatalf("unable to parse the virtual machine config file: %v", err)
+			}
+		}
+
+		vmutils.SetSpecLibvirtURI(spec.URI)
+
+		if abiPath, _ := cmd.Flags().GetString("abi-path"); abiPath != "" {
+			if spec.BipSpec == nil {
+				spec.BipSpec = &biputils.BootstrapInPlaceIsoSpec{}
+			}
+			spec.BipSpec.AbiPath = abiPath
+		}
+
+		report, err := machines.Preflight(cmd.Context(), spec)
+		if err != nil {
+			logger.Fatalf("unable to run pre-flight checks: %v", err)
+		}
+
+		// A network booted VM is installed from artifacts served by someone else
+		if !spec.PxeBoot {
+			machines.PreflightInstaller(report, spec.BipSpec)
+		}
+
+		if err := report.Print(os.Stdout); err != nil {
+			logger.Fatalf("unable to print the pre-flight report: %v", err)
+		}
+
+		if err := report.Err(); err != nil {
+			logger.Fatal(err)
+		}
+	},
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"snoman/internal/biputils/installconfig"
+	"snoman/internal/proxy"
+
+	"github.com/spf13/cobra"
+)
+
+// addProxyFlags will add the cluster proxy flags to a command that generates an install config
+func addProxyFlags(cmd *cobra.Command) {
+	cmd.Flags().String("http-proxy", "", "The proxy for HTTP traffic of the cluster and openshift-install, ex: http://proxy.lab:3128")
+	cmd.Flags().String("https-proxy", "", "The proxy for HTTPS traffic of the cluster and openshift-install")
+	cmd.Flags().StringSlice("no-proxy", nil, "Extra destinations that skip the proxy. The machine, cluster and service networks and the cluster domain always do")
+}
+
+// getProxySpec will build the proxy spec from the flags, it returns nil when no proxy was set
+func getProxySpec(cmd *cobra.Command) *installconfig.ProxySpec {
+	spec := &installconfig.ProxySpec{}
+	spec.HTTPProxy, _ = cmd.Flags().GetString("http-proxy")
+	spec.HTTPSProxy, _ = cmd.Flags().GetString("https-proxy")
+	spec.NoProxy, _ = cmd.Flags().GetStringSlice("no-proxy")
+
+	if spec.HTTPProxy == "" && spec.HTTPSProxy == "" {
+		return nil
+	}
+
+	return spec
+}
+
+// addServeProxyFlags will add the built-in proxy flags, prefix namespaces them on commands with other flags
+func addServeProxyFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().Uint(prefix+"port", proxy.DEFAULT_PORT, "The port the built-in proxy listens on")
+	cmd.Flags().StringSlice(prefix+"allow", nil, "Only proxy to these hosts, domains (.example.com or *.example.com) or CIDRs")
+	cmd.Flags().StringSlice(prefix+"deny", nil, "Never proxy to these hosts or domains, even when they are allowed. CIDRs can only be allowed")
+}
+
+// getServeProxySpec will build the built-in proxy spec from the flags added with the same prefix
+func getServeProxySpec(cmd *cobra.Command, prefix string) *proxy.Spec {
+	spec := &proxy.Spec{}
+	spec.Port, _ = cmd.Flags().GetUint(prefix + "port")
+	spec.Allow, _ = cmd.Flags().GetStringSlice(prefix + "allow")
+	spec.Deny, _ = cmd.Flags().GetStringSlice(prefix + "deny")
+
+	return spec
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	log "snoman/internal/logger"
+	"snoman/internal/proxy"
+	"snoman/internal/vms/network"
+
+	"github.com/spf13/cobra"
+)
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Run the built-in forward proxy",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Fatalf("Error executing proxy command: %v", ErrResourceTypeNotSpecified)
+	},
+}
+
+func initProxyCmd() {
+	rootCmd.AddCommand(proxyCmd)
+
+	// Subcommands
+	proxyCmd.AddCommand(proxyServeCmd)
+
+	wd, _ := os.Getwd()
+	wd = filepath.Join(wd, "workdir")
+	wd, _ = filepath.Abs(wd)
+
+	proxyServeCmd.Flags().String("network", "", "Serve the proxy on the gateway of this libvirt network")
+	proxyServeCmd.Flags().String("address", "", "Serve the proxy on this address instead of a network gateway")
+	addServeProxyFlags(proxyServeCmd, "")
+	proxyServeCmd.Flags().StringP("workdir", "w", wd, "The working folder the access log is written to")
+	proxyServeCmd.MarkFlagsMutuallyExclusive("network", "address")
+	proxyServeCmd.MarkFlagsOneRequired("network", "address")
+}
+
+// Serve the forward proxy
+var proxyServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve an HTTP and HTTPS (CONNECT) forward proxy until interrupted",
+	Long: `
+	Serve an HTTP and HTTPS (CONNECT) forward proxy until interrupted
+
+	The proxy is bound to the gateway of a libvirt network, so the virtual machines on it can reach the
+	internet through it. Point a cluster at it with --http-proxy and --https-proxy to check that it
+	honours the proxy, every request is written to logs/proxy-access.log in the working folder.
+	Destinations can be restricted with --allow and --deny, which take hosts, domains (.example.com or
+	*.example.com) and CIDRs. Deny patterns win over allow patterns. Destinations are not resolved, so a
+	CIDR only matches clients connecting to an IP, which is why CIDRs can be allowed but not denied
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		host, _ := cmd.Flags().GetString("address")
+		if networkName, _ := cmd.Flags().GetString("network"); networkName != "" {
+			netspec, err := network.Find(cmd.Context(), networkName)
+			if err != nil {
+				logger.Fatalf("unable to find the proxy network: %v", err)
+			}
+
+			host = network.ServiceAddress(netspec)
+		}
+
+		workdir, _ := cmd.Flags().GetString("workdir")
+		if err := os.MkdirAll(workdir, 0755); err != nil {
+			logger.Fatalf("unable to create the working folder: %v", err)
+		}
+
+		accessLog, err := log.OpenLogFile(workdir, proxy.DEFAULT_ACCESS_LOG_FILE)
+		if err != nil {
+			logger.Fatalf("unable to open the proxy access log: %v", err)
+		}
+		defer accessLog.Close()
+
+		server, err := proxy.NewServer(host, getServeProxySpec(cmd, ""), accessLog)
+		if err != nil {
+			logger.Fatalf("unable to create the proxy: %v", err)
+		}
+
+		if err := server.Start(); err != nil {
+			logger.Fatalf("unable to start the proxy: %v", err)
+		}
+
+		<-cmd.Context().Done()
+		logger.Info("stopping the proxy")
+		if err := server.Shutdown(context.Background()); err != nil {
+			logger.Errorf("unable to stop the proxy: %v", err)
+		}
+	},
+}
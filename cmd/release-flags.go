@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"snoman/internal/biputils"
+	"snoman/internal/release"
+
+	"github.com/spf13/cobra"
+)
+
+// addReleaseFlags will add the release resolution and installer flags to a command that generates an ISO
+func addReleaseFlags(cmd *cobra.Command) {
+	cmd.Flags().String("release", "", "The release to install: a version (4.15.1), a channel (stable-4.15), a nightly (4.15.0-0.nightly-2024-03-01-123456) or a pull spec, which may be pinned by digest")
+	cmd.Flags().String("release-registry", release.DEFAULT_RELEASE_REGISTRY, "The repository release versions are pulled from")
+	cmd.Flags().String("nightly-registry", "", "The repository nightlies are pulled from. Defaults to the repository of the architecture on "+release.DEFAULT_NIGHTLY_REGISTRY)
+	cmd.Flags().String("upgrade-graph", "", "Path to an upgrade graph JSON file to resolve channels from, ex: saved from https://api.openshift.com/api/upgrades_info/v1/graph?channel=stable-4.15")
+	cmd.Flags().String("installer-dir", "", "Path to a folder of installers, ex: openshift-install-4.15.1 or 4.15.1/openshift-install. The one of the release is used instead of the installer path")
+	cmd.Flags().String("installer-version-check", biputils.DEFAULT_INSTALLER_VERSION_CHECK, "What to do when the installer is not of the release major and minor version: fail, warn or skip")
+}
+
+// setReleaseFlags will set the release resolution and installer fields of the ISO spec from the flags that were used
+func setReleaseFlags(cmd *cobra.Command, spec *biputils.BootstrapInPlaceIsoSpec) {
+	if cmd.Flags().Changed("release") {
+		spec.Release, _ = cmd.Flags().GetString("release")
+	}
+
+	if cmd.Flags().Changed("release-registry") {
+		spec.ReleaseRegistry, _ = cmd.Flags().GetString("release-registry")
+	}
+
+	if cmd.Flags().Changed("nightly-registry") {
+		spec.NightlyRegistry, _ = cmd.Flags().GetString("nightly-registry")
+	}
+
+	if cmd.Flags().Changed("upgrade-graph") {
+		spec.UpgradeGraphPath, _ = cmd.Flags().GetString("upgrade-graph")
+	}
+
+	if cmd.Flags().Changed("installer-dir") {
+		spec.InstallerDir, _ = cmd.Flags().GetString("installer-dir")
+	}
+
+	if cmd.Flags().Changed("installer-version-check") {
+		spec.InstallerVersionCheck, _ = cmd.Flags().GetString("installer-version-check")
+	}
+}
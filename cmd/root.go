@@ -4,9 +4,15 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"os/signal"
 
 	log "snoman/internal/logger"
+	"snoman/internal/runner"
+	"snoman/internal/vms/hypervisor"
+	vmutils "snoman/internal/vms/utils"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -20,6 +26,15 @@ var verbose bool
 // jsonOutput is the optional command that will display logs as JSON
 var jsonOutput bool
 
+// libvirtURI is the optional libvirt connection URI used for every libvirt call
+var libvirtURI string
+
+// recordCommands is the optional file every external command invocation is recorded to
+var recordCommands string
+
+// replayCommands is the optional recording to answer external commands from instead of running them
+var replayCommands string
+
 // version is an optional command that will display the current release version
 var releaseVersion string
 
@@ -29,13 +44,35 @@ var rootCmd = &cobra.Command{
 	Version: releaseVersion,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		logger = log.Set(verbose, jsonOutput)
+
+		if libvirtURI != "" {
+			vmutils.SetLibvirtURI(libvirtURI)
+		}
+
+		if err := setCommandRunner(); err != nil {
+			logger.Fatalf("unable to set up the command runner: %v", err)
+		}
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		// Every hypervisor call of the command shares one connection
+		if err := hypervisor.Close(); err != nil {
+			logger.Debugf("unable to close the libvirt connection: %v", err)
+		}
+
+		if replayer, ok := runner.Get().(*runner.Replayer); ok && replayer.Remaining() > 0 {
+			logger.Warnf("%d recorded commands were not replayed", replayer.Remaining())
+		}
 	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// Long running commands use the context to clean up when interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
@@ -46,9 +83,38 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Display verbose logs")
 	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "log-json", false, "Format the log output as JSON")
+	rootCmd.PersistentFlags().StringVar(&recordCommands, "record-commands", "", "Record every external command (openshift-install, virt-install, ...) and its output to this file")
+	rootCmd.PersistentFlags().StringVar(&replayCommands, "replay-commands", "", "Answer external commands from a file written by --record-commands instead of running them")
+	rootCmd.PersistentFlags().StringVarP(&libvirtURI, "connect", "c", "", fmt.Sprintf("The libvirt connection URI, ex: qemu+ssh://user@host/system, qemu:///session, %s (libvirt test driver) or %s (in-memory) (default: $%s or %s)", hypervisor.TEST_URI, hypervisor.FAKE_URI, vmutils.LIBVIRT_URI_ENV, vmutils.DEFAULT_LIBVIRT_URI))
 
+	initCacheCmd()
+	initChaosCmd()
 	initCreateCmd()
 	initDestroyCmd()
 	initGenerateCmd()
+	initPoolCmd()
+	initPreflightCmd()
+	initProxyCmd()
 	initRunCmd()
+	initVmCmd()
+	initVolumeCmd()
+}
+
+// setCommandRunner will pick how external commands are run based on the record and replay flags
+func setCommandRunner() error {
+	switch {
+	case recordCommands != "" && replayCommands != "":
+		return fmt.Errorf("--record-commands and --replay-commands can not be used together")
+	case recordCommands != "":
+		runner.Set(runner.NewRecorder(&runner.ExecRunner{}, recordCommands))
+	case replayCommands != "":
+		rec, err := runner.LoadRecording(replayCommands)
+		if err != nil {
+			return err
+		}
+
+		runner.Set(runner.NewReplayer(rec))
+	}
+
+	return nil
 }
@@ -1,14 +1,20 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"snoman/internal/biputils"
+	"snoman/internal/biputils/installconfig"
 	"snoman/internal/biputils/secrets"
+	"snoman/internal/pxe"
+	"snoman/internal/targets/redfish"
 	"snoman/internal/vms/machines"
+	vmutils "snoman/internal/vms/utils"
 	"snoman/internal/workflows/bip"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 )
 
 var runCmd = &cobra.Command{
@@ -36,7 +42,19 @@ func initRunCmd() {
 	runBipCmd.Flags().String("pull-secret-file", "", "Path to the file containing the cluster pull secret. If left empty the PULL_SECRET env variable will be used")
 	runBipCmd.Flags().String("iso-file", "", "Path to the installer iso file to use for the VM")
 	runBipCmd.Flags().String("iso-config", "", "Path to the configuration yaml for the iso file")
+	runBipCmd.Flags().String("arch", "", "The cluster architecture, ex: x86_64, aarch64 or arm64. Defaults to the VM arch, or x86_64, and the VM is emulated when the hypervisor is of another architecture. With --iso-config, ocp_release_arch is used instead")
+	runBipCmd.Flags().Bool("no-iso-cache", false, "Always generate the installer ISO instead of reusing a cached ISO with the same inputs")
 	runBipCmd.Flags().StringP("workdir", "w", wd, "The working folder to generate any required files in")
+	runBipCmd.Flags().String("redfish-config", "", fmt.Sprintf("Path to the redfish BMC configuration yaml. When set, a bare metal server is installed instead of a VM, its host_ip is where the cluster api is resolved to. If the password is not in the file the %s env variable will be used", redfish.BMC_PASSWORD_ENV))
+	runBipCmd.Flags().String("chaos-schedule", "", "Path to a chaos schedule yaml of faults to inject into the VM while the workflow runs")
+	runBipCmd.Flags().Bool("pxe", false, "Network boot the VM from the agent PXE artifacts, served from the VM network gateway, instead of an ISO")
+	runBipCmd.Flags().Uint("pxe-http-port", pxe.DEFAULT_HTTP_PORT, "The port the PXE artifacts are served over HTTP on")
+	runBipCmd.Flags().Bool("pxe-tftp", false, fmt.Sprintf("Also serve the PXE artifacts over TFTP on port %d and boot the VM from there", pxe.DEFAULT_TFTP_PORT))
+	addReleaseFlags(runBipCmd)
+	addMirrorFlags(runBipCmd)
+	addProxyFlags(runBipCmd)
+	runBipCmd.Flags().Bool("serve-proxy", false, "Serve the built-in proxy on the VM network gateway and install the cluster through it. The requests are logged to logs/proxy-access.log in the workdir")
+	addServeProxyFlags(runBipCmd, "proxy-")
 
 	//runCmd.AddCommand(runIbuCmd)
 }
@@ -82,31 +100,124 @@ var runBipCmd = &cobra.Command{
 			spec.MachineConfig = &machines.VirtualMachineSpec{}
 			spec.MachineConfig.UnmarshalYAML(data)
 		}
+		vmutils.SetSpecLibvirtURI(spec.MachineConfig.URI)
 
 		// ISO Configuration
 		spec.IsoSpec = &biputils.BootstrapInPlaceIsoSpec{}
 		spec.IsoSpec.IsoPath, _ = cmd.Flags().GetString("iso-file")
+		// The release follows the VM architecture unless one is asked for
+		spec.IsoSpec.OpenshiftArch = spec.MachineConfig.Arch
+		if cmd.Flags().Changed("arch") {
+			spec.IsoSpec.OpenshiftArch, _ = cmd.Flags().GetString("arch")
+		}
+
 		if spec.IsoSpec.IsoPath == "" {
 			// Check for an ISO config and generate one if needed
 			isoConfigFile, _ := cmd.Flags().GetString("iso-config")
 			if isoConfigFile == "" {
 				logger.Info("using default ISO configuration")
+				setReleaseFlags(cmd, spec.IsoSpec)
+				if err := spec.IsoSpec.ResolveRelease(); err != nil {
+					logger.Fatal(err)
+				}
 				spec.IsoSpec.FillAndValidateIsoGenFields()
 			} else {
+				if cmd.Flags().Changed("arch") {
+					logger.Fatal("--arch can not be used with --iso-config, set ocp_release_arch in the file instead")
+				}
+				spec.IsoSpec.OpenshiftArch = ""
+
 				data, err := os.ReadFile(isoConfigFile)
 				if err != nil {
 					logger.Fatalf("unable to read the iso config file: %v", err)
 				}
 
-				if err := spec.IsoSpec.UnmarshalYAML(data); err != nil {
+				// The release flags override the file, so they are set before the release is resolved
+				if err := yaml.Unmarshal(data, spec.IsoSpec); err != nil {
 					logger.Fatalf("unable to unmarshal iso config file: %v", err)
 				}
+				setReleaseFlags(cmd, spec.IsoSpec)
+
+				if err := spec.IsoSpec.FillAndValidateIsoGenFields(); err != nil {
+					logger.Fatalf("unable to unmarshal iso config file: %v", err)
+				}
+			}
+
+			if noCache, _ := cmd.Flags().GetBool("no-iso-cache"); noCache {
+				spec.IsoSpec.DisableCache = true
 			}
 		}
 
 		spec.Workdir, _ = cmd.Flags().GetString("workdir")
 
-		if err := bip.Run(spec); err != nil {
+		// Bare metal target
+		redfishConfigFile, _ := cmd.Flags().GetString("redfish-config")
+		if redfishConfigFile != "" {
+			data, err := os.ReadFile(redfishConfigFile)
+			if err != nil {
+				logger.Fatalf("unable to read the redfish config file: %v", err)
+			}
+
+			rfspec := &redfish.RedfishSpec{}
+			if err := rfspec.UnmarshalYAML(data); err != nil {
+				logger.Fatalf("unable to unmarshal redfish config file: %v", err)
+			}
+
+			spec.Target, err = redfish.NewProvider(rfspec)
+			if err != nil {
+				logger.Fatalf("unable to create redfish target: %v", err)
+			}
+		}
+
+		// Network boot
+		if usePxe, _ := cmd.Flags().GetBool("pxe"); usePxe {
+			if spec.Target != nil {
+				logger.Fatal("--pxe can not be used with --redfish-config")
+			}
+
+			if iso, _ := cmd.Flags().GetString("iso-file"); iso != "" {
+				logger.Fatal("--pxe can not be used with --iso-file")
+			}
+
+			spec.Pxe = &bip.PxeSpec{}
+			spec.Pxe.HttpPort, _ = cmd.Flags().GetUint("pxe-http-port")
+			if useTftp, _ := cmd.Flags().GetBool("pxe-tftp"); useTftp {
+				spec.Pxe.TftpPort = pxe.DEFAULT_TFTP_PORT
+			}
+		}
+
+		spec.Proxy = getProxySpec(cmd)
+
+		// Built-in proxy, the extra no-proxy destinations still apply to it
+		if serveProxy, _ := cmd.Flags().GetBool("serve-proxy"); serveProxy {
+			if spec.Proxy != nil {
+				logger.Fatal("--serve-proxy can not be used with --http-proxy or --https-proxy")
+			}
+
+			if spec.Target != nil {
+				logger.Fatal("--serve-proxy can not be used with --redfish-config")
+			}
+
+			spec.ServeProxy = getServeProxySpec(cmd, "proxy-")
+			spec.Proxy = &installconfig.ProxySpec{}
+			spec.Proxy.NoProxy, _ = cmd.Flags().GetStringSlice("no-proxy")
+		}
+
+		// Disconnected installs
+		spec.Mirror, err = getMirrorSpec(cmd)
+		if err != nil {
+			logger.Fatalf("unable to configure the mirror registry: %v", err)
+		}
+
+		// Fault injection
+		if scheduleFile, _ := cmd.Flags().GetString("chaos-schedule"); scheduleFile != "" {
+			spec.Chaos, err = loadChaosSchedule(scheduleFile)
+			if err != nil {
+				logger.Fatal(err)
+			}
+		}
+
+		if err := bip.Run(cmd.Context(), spec); err != nil {
 			logger.Errorf("unable to run bootstrap in place: %v", err)
 		}
 	},
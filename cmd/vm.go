@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"snoman/internal/vms/hypervisor"
+	"snoman/internal/vms/machines"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var vmCmd = &cobra.Command{
+	Use:   "vm",
+	Short: "Inspect and manage a running virtual machine",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Fatalf("Error executing vm command: %v", ErrResourceTypeNotSpecified)
+	},
+}
+
+const statsFormatTable = "table"
+
+func initVmCmd() {
+	rootCmd.AddCommand(vmCmd)
+
+	// Subcommands
+	vmCmd.AddCommand(vmStatsCmd)
+	vmStatsCmd.Flags().Bool("watch", false, "Keep sampling until interrupted")
+	vmStatsCmd.Flags().Uint("interval", 5, "Seconds between samples when watching")
+	vmStatsCmd.Flags().String("format", statsFormatTable, fmt.Sprintf("Output format: %s, %s or %s", statsFormatTable, machines.STATS_FORMAT_CSV, machines.STATS_FORMAT_JSONL))
+
+	vmCmd.AddCommand(vmMediaCmd)
+	vmMediaCmd.AddCommand(vmMediaListCmd)
+	vmMediaCmd.AddCommand(vmMediaInsertCmd)
+	vmMediaInsertCmd.Flags().String("target", "", "The cdrom device to use, ex: sda. Defaults to the first empty cdrom")
+	vmMediaInsertCmd.Flags().Bool("boot", false, "Boot from the cdrom first on the next boot")
+	vmMediaCmd.AddCommand(vmMediaEjectCmd)
+	vmMediaEjectCmd.Flags().String("target", "", "The cdrom device to eject, ex: sda. Defaults to every cdrom")
+
+	vmCmd.AddCommand(vmBootOrderCmd)
+
+	vmCmd.AddCommand(vmClockCmd)
+	vmClockCmd.Flags().String("offset", "", "Move the guest clock this far from the host clock, ex: 90d, 1y6mo, -12h")
+	vmClockCmd.Flags().Bool("reset", false, "Set the guest clock back to the host clock")
+	vmClockCmd.Flags().Bool("restart", false, "Power cycle the VM, or start it if it is off, so the new offset applies now")
+}
+
+// VM Stats
+var vmStatsCmd = &cobra.Command{
+	Use:   "stats [name or uuid]",
+	Short: "Show the resource usage of a virtual machine",
+	Long: `
+	Show the cpu, memory, disk and network usage of a running virtual machine
+
+	Disk and network values are totals since the machine started. The cpu percentage is
+	relative to one host cpu and is only known from the second sample on, so use --watch to see it
+
+	if no name or UUID is provided, the default VM name will be used
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		vmname := machines.DEFAULT_VM_NAME
+		if len(args) > 0 {
+			vmname = args[0]
+		}
+
+		watch, _ := cmd.Flags().GetBool("watch")
+		interval, _ := cmd.Flags().GetUint("interval")
+		format, _ := cmd.Flags().GetString("format")
+
+		write := printStatsTable(os.Stdout)
+		if format != statsFormatTable {
+			w, err := machines.NewStatsWriter(os.Stdout, format)
+			if err != nil {
+				logger.Fatalf("unable to show stats: %v", err)
+			}
+			write = w.Write
+		}
+
+		if !watch {
+			stats, err := hypervisor.Get().DomainStats(cmd.Context(), vmname)
+			if err != nil {
+				logger.Fatalf("unable to get the stats of '%s': %v", vmname, err)
+			}
+
+			if err := write(machines.NewStatsSample(nil, stats)); err != nil {
+				logger.Fatalf("unable to show stats: %v", err)
+			}
+
+			return
+		}
+
+		if interval == 0 {
+			logger.Fatalf("--interval must be greater than 0")
+		}
+
+		if err := machines.SampleStats(cmd.Context(), vmname, time.Duration(interval)*time.Second, write); err != nil {
+			logger.Fatalf("unable to watch the stats of '%s': %v", vmname, err)
+		}
+	},
+}
+
+// VM Media
+var vmMediaCmd = &cobra.Command{
+	Use:   "media",
+	Short: "Manage the cdrom media of a virtual machine",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Fatalf("Error executing vm media command: %v", ErrResourceTypeNotSpecified)
+	},
+}
+
+var vmMediaListCmd = &cobra.Command{
+	Use:   "list [name or uuid]",
+	Short: "List the cdrom devices of a virtual machine and their media",
+	Run: func(cmd *cobra.Command, args []string) {
+		vmname := machines.DEFAULT_VM_NAME
+		if len(args) > 0 {
+			vmname = args[0]
+		}
+
+		media, err := machines.ListMedia(cmd.Context(), vmname)
+		if err != nil {
+			logger.Fatalf("unable to list media: %v", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TARGET\tBUS\tMEDIA")
+		for _, m := range media {
+			source := m.Source
+			if source == "" {
+				source = "-"
+			}
+
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", m.Target, m.Bus, source)
+		}
+		tw.Flush()
+	},
+}
+
+var vmMediaInsertCmd = &cobra.Command{
+	Use:   "insert <name or uuid> <iso path>",
+	Short: "Insert an ISO into a cdrom of a virtual machine",
+	Long: `
+	Insert an ISO into a cdrom of a virtual machine. Running machines see the new media right away
+	and the change is kept across restarts
+
+	If the machine has no empty cdrom a new one is added. A running machine can only get a new cdrom
+	on a bus that supports hot plug (scsi on aarch64), stop x86_64 machines first as their cdroms are
+	on sata. On remote hypervisors the ISO is uploaded to the pool of the machine disk first
+	`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		target, _ := cmd.Flags().GetString("target")
+		boot, _ := cmd.Flags().GetBool("boot")
+
+		if err := machines.InsertMedia(cmd.Context(), args[0], args[1], target); err != nil {
+			logger.Fatalf("unable to insert media: %v", err)
+		}
+
+		if boot {
+			if err := machines.SetBootOrder(cmd.Context(), args[0], []string{"cdrom", "hd"}); err != nil {
+				logger.Fatalf("unable to set the boot order: %v", err)
+			}
+		}
+	},
+}
+
+var vmMediaEjectCmd = &cobra.Command{
+	Use:   "eject [name or uuid]",
+	Short: "Eject the media from the cdroms of a virtual machine",
+	Run: func(cmd *cobra.Command, args []string) {
+		vmname := machines.DEFAULT_VM_NAME
+		if len(args) > 0 {
+			vmname = args[0]
+		}
+
+		target, _ := cmd.Flags().GetString("target")
+
+		if err := machines.EjectMedia(cmd.Context(), vmname, target); err != nil {
+			logger.Fatalf("unable to eject media: %v", err)
+		}
+	},
+}
+
+// VM Boot Order
+var vmBootOrderCmd = &cobra.Command{
+	Use:   "boot-order <name or uuid> <device>[,<device>...]",
+	Short: "Set the boot device order of a virtual machine",
+	Long: fmt.Sprintf(`
+	Set the order the virtual machine tries its boot devices in, ex: cdrom,hd
+
+	Supported devices are %s. The new order applies from the next boot
+	`, strings.Join(machines.BOOT_DEVICES, ", ")),
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := machines.SetBootOrder(cmd.Context(), args[0], strings.Split(args[1], ",")); err != nil {
+			logger.Fatalf("unable to set the boot order: %v", err)
+		}
+	},
+}
+
+// VM Clock
+var vmClockCmd = &cobra.Command{
+	Use:   "clock [name or uuid]",
+	Short: "Show or move the guest clock of a virtual machine",
+	Long: `
+	Show or move how far the guest clock of a virtual machine is set from the host clock
+
+	The guest reads its clock when it boots, so a new offset applies from the next start. Use --restart
+	to power cycle the VM straight away, which also looks like a shutdown lasting as long as the offset.
+	Months and years are calendar months and years from now
+
+	if no name or UUID is provided, the default VM name will be used
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		vmname := machines.DEFAULT_VM_NAME
+		if len(args) > 0 {
+			vmname = args[0]
+		}
+
+		offset, _ := cmd.Flags().GetString("offset")
+		reset, _ := cmd.Flags().GetBool("reset")
+		restart, _ := cmd.Flags().GetBool("restart")
+
+		if offset != "" && reset {
+			logger.Fatal("--offset and --reset can not be used together")
+		}
+
+		var seconds int64
+		var err error
+		if offset != "" || reset {
+			seconds, err = machines.SetClockOffset(cmd.Context(), vmname, offset)
+			if err != nil {
+				logger.Fatalf("unable to set the clock offset: %v", err)
+			}
+		} else {
+			seconds, err = machines.GetClockOffset(cmd.Context(), vmname)
+			if err != nil {
+				logger.Fatalf("unable to get the clock offset: %v", err)
+			}
+		}
+
+		if restart {
+			if err := machines.RestartVirtualMachine(cmd.Context(), vmname); err != nil {
+				logger.Fatalf("unable to restart '%s': %v", vmname, err)
+			}
+		} else if offset != "" || reset {
+			logger.Info("the new offset applies from the next start, use --restart to apply it now")
+		}
+
+		offsetDuration := time.Duration(seconds) * time.Second
+		fmt.Printf("offset:     %s (%d seconds)\n", offsetDuration, seconds)
+		fmt.Printf("guest time: %s\n", time.Now().Add(offsetDuration).Format(time.RFC3339))
+	},
+}
+
+// printStatsTable returns a function that prints each sample as a row, with the header before the first
+func printStatsTable(w io.Writer) func(machines.StatsSample) error {
+	const rowFormat = "%-20s %6s %10s %10s %12s %12s %12s %12s\n"
+	const mib = 1024 * 1024
+
+	headerPrinted := false
+	return func(s machines.StatsSample) error {
+		if !headerPrinted {
+			fmt.Fprintf(w, rowFormat, "TIME", "CPU%", "MEM_MIB", "RSS_MIB", "DISK_RD_MIB", "DISK_WR_MIB", "NET_RX_MIB", "NET_TX_MIB")
+			headerPrinted = true
+		}
+
+		cpu := "-"
+		if s.CPUPercent > 0 {
+			cpu = fmt.Sprintf("%.1f", s.CPUPercent)
+		}
+
+		_, err := fmt.Fprintf(w, rowFormat,
+			s.Time.Format(time.RFC3339),
+			cpu,
+			fmt.Sprint(s.MemoryBytes/mib),
+			fmt.Sprint(s.RSSBytes/mib),
+			fmt.Sprint(s.DiskReadBytes/mib),
+			fmt.Sprint(s.DiskWriteBytes/mib),
+			fmt.Sprint(s.NetRxBytes/mib),
+			fmt.Sprint(s.NetTxBytes/mib),
+		)
+
+		return err
+	}
+}
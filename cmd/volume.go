@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"snoman/internal/vms/storage"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var volumeCmd = &cobra.Command{
+	Use:   "volume",
+	Short: "Manage volumes in libvirt storage pools",
+	Run: func(cmd *cobra.Command, args []string) {
+		logger.Fatalf("Error executing volume command: %v", ErrResourceTypeNotSpecified)
+	},
+}
+
+func initVolumeCmd() {
+	rootCmd.AddCommand(volumeCmd)
+	volumeCmd.PersistentFlags().String("pool", "default", "The storage pool the volume lives in")
+
+	// Subcommands
+	volumeCmd.AddCommand(volumeCreateCmd)
+	volumeCreateCmd.Flags().Uint64("size", 0, "[Required] The size of the volume in GB")
+	volumeCreateCmd.Flags().String("format", storage.VOLUME_FORMAT_QCOW2, "The format of the volume")
+
+	volumeCmd.AddCommand(volumeListCmd)
+	volumeCmd.AddCommand(volumeDeleteCmd)
+	volumeCmd.AddCommand(volumeCloneCmd)
+
+	volumeCmd.AddCommand(volumeUploadCmd)
+	volumeUploadCmd.Flags().String("name", "", "Name of the volume to create (default: the file name)")
+}
+
+// Create a volume
+var volumeCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create an empty volume",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pool, _ := cmd.Flags().GetString("pool")
+		format, _ := cmd.Flags().GetString("format")
+		size, _ := cmd.Flags().GetUint64("size")
+		if size == 0 {
+			logger.Fatal("argument size is required when creating a volume")
+		}
+
+		path, err := storage.CreateVolume(cmd.Context(), pool, args[0], size, format)
+		if err != nil {
+			logger.Fatalf("unable to create volume: %v", err)
+		}
+
+		fmt.Println(path)
+	},
+}
+
+// List volumes
+var volumeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the volumes in a pool",
+	Run: func(cmd *cobra.Command, args []string) {
+		pool, _ := cmd.Flags().GetString("pool")
+
+		vols, err := storage.ListVolumes(cmd.Context(), pool)
+		if err != nil {
+			logger.Fatalf("unable to list volumes: %v", err)
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tCAPACITY\tALLOCATION\tPATH")
+		for _, vol := range vols {
+			fmt.Fprintf(tw, "%s\t%dG\t%dG\t%s\n", vol.Name, vol.CapacityGB, vol.AllocationGB, vol.Path)
+		}
+		tw.Flush()
+	},
+}
+
+// Delete a volume
+var volumeDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a volume",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pool, _ := cmd.Flags().GetString("pool")
+
+		if err := storage.DeleteVolume(cmd.Context(), pool, args[0]); err != nil {
+			logger.Fatalf("unable to delete volume: %v", err)
+		}
+	},
+}
+
+// Clone a volume
+var volumeCloneCmd = &cobra.Command{
+	Use:   "clone [source] [name]",
+	Short: "Clone a volume within its pool",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		pool, _ := cmd.Flags().GetString("pool")
+
+		path, err := storage.CloneVolume(cmd.Context(), pool, args[0], args[1])
+		if err != nil {
+			logger.Fatalf("unable to clone volume: %v", err)
+		}
+
+		fmt.Println(path)
+	},
+}
+
+// Upload a file (usually an ISO) into a volume
+var volumeUploadCmd = &cobra.Command{
+	Use:   "upload [file]",
+	Short: "Upload a local file, like an installer ISO, into a new volume",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		pool, _ := cmd.Flags().GetString("pool")
+		name, _ := cmd.Flags().GetString("name")
+		if name == "" {
+			name = filepath.Base(args[0])
+		}
+
+		path, err := storage.UploadVolume(cmd.Context(), pool, name, args[0])
+		if err != nil {
+			logger.Fatalf("unable to upload volume: %v", err)
+		}
+
+		fmt.Println(path)
+	},
+}
@@ -0,0 +1,73 @@
+package arch
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Architectures by their kernel names, which release images, ISOs and libvirt use. install-config and Go use
+// the GOARCH names instead, ex: aarch64 is arm64
+const (
+	X86_64  = "x86_64"
+	AARCH64 = "aarch64"
+	PPC64LE = "ppc64le"
+	S390X   = "s390x"
+)
+
+var goArchitectures = map[string]string{
+	X86_64:  "amd64",
+	AARCH64: "arm64",
+	PPC64LE: "ppc64le",
+	S390X:   "s390x",
+}
+
+// Normalize will return the kernel name of an architecture given either of its names, empty stays empty
+func Normalize(arch string) (string, error) {
+	arch = strings.ToLower(strings.TrimSpace(arch))
+	if arch == "" {
+		return "", nil
+	}
+
+	if _, ok := goArchitectures[arch]; ok {
+		return arch, nil
+	}
+
+	for kernel, goarch := range goArchitectures {
+		if arch == goarch {
+			return kernel, nil
+		}
+	}
+
+	names := []string{}
+	for kernel, goarch := range goArchitectures {
+		names = append(names, kernel)
+		if goarch != kernel {
+			names = append(names, goarch)
+		}
+	}
+	sort.Strings(names)
+
+	return "", fmt.Errorf("unsupported architecture '%s', expected one of %s", arch, strings.Join(names, ", "))
+}
+
+// GoArch will return the GOARCH name of an architecture given either of its names, unknown names are returned
+// as is so they fail validation where they are used
+func GoArch(arch string) string {
+	kernel, err := Normalize(arch)
+	if err != nil || kernel == "" {
+		return arch
+	}
+
+	return goArchitectures[kernel]
+}
+
+// Host will return the kernel name of the architecture snoman is running on
+func Host() string {
+	if kernel, err := Normalize(runtime.GOARCH); err == nil {
+		return kernel
+	}
+
+	return runtime.GOARCH
+}
@@ -2,31 +2,126 @@ package biputils
 
 import (
 	"fmt"
+	"snoman/internal/arch"
+	"snoman/internal/biputils/installconfig"
+	"snoman/internal/biputils/isocache"
+	"snoman/internal/release"
 	vmutils "snoman/internal/vms/utils"
 
 	"gopkg.in/yaml.v2"
 )
 
 type BootstrapInPlaceIsoSpec struct {
-	IsoPath           string `yaml:"iso_path,omitempty" validate:"omitempty,file"`
-	AbiPath           string `yaml:"agent_based_installer_path,omitempty" validate:"omitempty,file"`
-	CustomManifestDir string `yaml:"custom_manifests_path,omitempty" validate:"omitempty,dir"`
-	OpenshiftVersion  string `yaml:"ocp_release_version" validate:"omitempty,semver"`
-	OpenshiftArch     string `yaml:"ocp_release_arch" validate:"omitempty"`
-	ReleaseImage      string `yaml:"release_image,omitempty" validate:"omitempty"`
-	AgentConfigPath   string `yaml:"agent_config_file" validate:"file"`
-	InstallConfigPath string `yaml:"install_config_file" validate:"file"`
+	IsoPath string `yaml:"iso_path,omitempty" validate:"omitempty,file"`
+	AbiPath string `yaml:"agent_based_installer_path,omitempty" validate:"omitempty,file"`
+	// InstallerDir holds installers of several versions, ex: openshift-install-4.15.1 or 4.15.1/openshift-install.
+	// The one of the release is used instead of AbiPath
+	InstallerDir string `yaml:"installer_directory,omitempty" validate:"omitempty,dir"`
+	// InstallerVersionCheck is what to do when the installer is not of the release major and minor version
+	InstallerVersionCheck string `yaml:"installer_version_check,omitempty" validate:"omitempty,oneof=fail warn skip"`
+	CustomManifestDir     string `yaml:"custom_manifests_path,omitempty" validate:"omitempty,dir"`
+	// CustomManifestValues can be used by manifest templates, ex: {{ .Values.key }}
+	CustomManifestValues map[string]string `yaml:"custom_manifests_values,omitempty" validate:"omitempty"`
+	OpenshiftVersion     string            `yaml:"ocp_release_version" validate:"omitempty,semver"`
+	// OpenshiftArch accepts either name of an architecture, ex: aarch64 or arm64, and is stored as the one
+	// release images and ISOs use
+	OpenshiftArch string `yaml:"ocp_release_arch" validate:"omitempty,oneof=x86_64 aarch64 ppc64le s390x"`
+	ReleaseImage  string `yaml:"release_image,omitempty" validate:"omitempty"`
+	// Release is a version, a channel like stable-4.15, a nightly tag or a pull spec. It is used instead of
+	// OpenshiftVersion and resolved into ReleaseImage when that is empty
+	Release string `yaml:"ocp_release,omitempty" validate:"omitempty"`
+	// ReleaseRegistry and NightlyRegistry replace the repositories versions and nightlies are pulled from
+	ReleaseRegistry string `yaml:"release_registry,omitempty" validate:"omitempty"`
+	NightlyRegistry string `yaml:"nightly_registry,omitempty" validate:"omitempty"`
+	// UpgradeGraphPath is the upgrade graph JSON file channels are resolved from
+	UpgradeGraphPath string `yaml:"upgrade_graph_file,omitempty" validate:"omitempty,file"`
+	// ResolvedRelease is how the release image was found, it is pinned by digest before generating
+	ResolvedRelease *release.Release `yaml:"-"`
+	// The configs are only needed to generate the ISO
+	AgentConfigPath   string `yaml:"agent_config_file" validate:"required_without=IsoPath,omitempty,file"`
+	InstallConfigPath string `yaml:"install_config_file" validate:"required_without=IsoPath,omitempty,file"`
+	// Proxy is used by openshift-install to reach the release image
+	Proxy *installconfig.ProxySpec `yaml:"proxy,omitempty" validate:"omitempty"`
+	// Generated ISOs are cached and reused while everything that goes into them stays the same. The cache
+	// directory is created by the first ISO put in it, so it does not have to exist
+	CacheDir     string `yaml:"cache_directory,omitempty" validate:"omitempty"`
+	CacheSizeGB  uint   `yaml:"cache_size_gb,omitempty" validate:"omitempty"`
+	DisableCache bool   `yaml:"disable_cache,omitempty" validate:"omitempty"`
+	// LogWorkdir is where the logs folder of the run is, the installer working folder is used when empty
+	LogWorkdir string `yaml:"-"`
 }
 
 const (
 	DEFAULT_ABI_PATH          = "/bin/openshift-install"
-	DEFAULT_RELEASE_IMAGE     = "quay.io/openshift-release-dev/ocp-release"
+	DEFAULT_RELEASE_IMAGE     = release.DEFAULT_RELEASE_REGISTRY
 	DEFAULT_OPENSHIFT_VERSION = "4.15.1"
-	DEFAULT_OPENSHIFT_ARCH    = "x86_64"
+	DEFAULT_OPENSHIFT_ARCH    = arch.X86_64
 )
 
+// installerArch is the architecture name openshift-install uses in the artifact names, ex: agent.aarch64.iso
+func installerArch(openshiftArch string) string {
+	name, err := arch.Normalize(openshiftArch)
+	if err != nil {
+		return openshiftArch
+	}
+
+	if name == "" {
+		return DEFAULT_OPENSHIFT_ARCH
+	}
+
+	return name
+}
+
+// ResolveRelease will set the release image from Release, or OpenshiftVersion when it is empty. A release
+// image that is already set is only recorded
+func (spec *BootstrapInPlaceIsoSpec) ResolveRelease() error {
+	if spec.ResolvedRelease != nil {
+		return nil
+	}
+
+	if spec.OpenshiftArch == "" {
+		spec.OpenshiftArch = DEFAULT_OPENSHIFT_ARCH
+	}
+
+	request := spec.ReleaseImage
+	if request == "" {
+		request = spec.Release
+	}
+	if request == "" {
+		if spec.OpenshiftVersion == "" {
+			spec.OpenshiftVersion = DEFAULT_OPENSHIFT_VERSION
+		}
+		request = spec.OpenshiftVersion
+	}
+
+	resolver := &release.Resolver{
+		Arch:            spec.OpenshiftArch,
+		Registry:        spec.ReleaseRegistry,
+		NightlyRegistry: spec.NightlyRegistry,
+		GraphPath:       spec.UpgradeGraphPath,
+	}
+
+	resolved, err := resolver.Resolve(request)
+	if err != nil {
+		return fmt.Errorf("unable to resolve the release image: %w", err)
+	}
+
+	spec.ResolvedRelease = resolved
+	spec.ReleaseImage = resolved.Image
+	if resolved.Version != "" {
+		spec.OpenshiftVersion = resolved.Version
+	}
+
+	return nil
+}
+
 // fillAndValidateIsoGenFields will populate any needed empty fields with defaults and then validate the struct
 func (spec *BootstrapInPlaceIsoSpec) FillAndValidateIsoGenFields() error {
+	var err error
+	if spec.OpenshiftArch, err = arch.Normalize(spec.OpenshiftArch); err != nil {
+		return fmt.Errorf("unable to validate BootstrapInPlaceIsoSpec: %w", err)
+	}
+
 	// If an ISO path was given, we don't need anything else
 	if spec.IsoPath != "" {
 		if err := vmutils.SpecValidator.Struct(spec); err != nil {
@@ -36,22 +131,26 @@ func (spec *BootstrapInPlaceIsoSpec) FillAndValidateIsoGenFields() error {
 		return nil
 	}
 
-	// We need the installer path to generate the ISO
-	if spec.AbiPath == "" {
+	// We need the installer path to generate the ISO, unless it is picked from the installer directory
+	if spec.AbiPath == "" && spec.InstallerDir == "" {
 		spec.AbiPath = DEFAULT_ABI_PATH
 	}
 
+	if spec.InstallerVersionCheck == "" {
+		spec.InstallerVersionCheck = DEFAULT_INSTALLER_VERSION_CHECK
+	}
+
 	// The release image is used by ABI to generate the ISO
-	if spec.ReleaseImage == "" {
-		if spec.OpenshiftVersion == "" {
-			spec.OpenshiftVersion = DEFAULT_OPENSHIFT_VERSION
-		}
+	if err := spec.ResolveRelease(); err != nil {
+		return err
+	}
 
-		if spec.OpenshiftArch == "" {
-			spec.OpenshiftArch = DEFAULT_OPENSHIFT_ARCH
-		}
+	if spec.CacheDir == "" {
+		spec.CacheDir = isocache.DefaultDir()
+	}
 
-		spec.ReleaseImage = fmt.Sprintf("%s:%s-%s", DEFAULT_RELEASE_IMAGE, spec.OpenshiftVersion, spec.OpenshiftArch)
+	if spec.CacheSizeGB == 0 {
+		spec.CacheSizeGB = isocache.DEFAULT_CACHE_SIZE_GB
 	}
 
 	// Validate the fields that exist
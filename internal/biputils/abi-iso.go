@@ -1,19 +1,57 @@
 package biputils
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"snoman/internal/logger"
+	"snoman/internal/runner"
+	"strings"
 )
 
 const installer_image_override_env = "OPENSHIFT_INSTALL_RELEASE_IMAGE_OVERRIDE"
 
-func GenerateIso(spec *BootstrapInPlaceIsoSpec, workdir string) error {
+func GenerateIso(ctx context.Context, spec *BootstrapInPlaceIsoSpec, workdir string) error {
 	if err := spec.FillAndValidateIsoGenFields(); err != nil {
 		return fmt.Errorf("unable to validate the required fields for iso generation: %w", err)
 	}
 
+	// The installer is part of the cache key, so it is picked first
+	if err := prepareInstaller(ctx, spec); err != nil {
+		return fmt.Errorf("unable to prepare the installer: %w", err)
+	}
+
+	// Reuse the ISO of a previous run with the same inputs
+	isoPath := GetIsoPath(workdir, spec.OpenshiftArch)
+	var cacheKey string
+	if !spec.DisableCache {
+		key, err := isoCacheKey(spec)
+		if err != nil {
+			return fmt.Errorf("unable to compute the iso cache key: %w", err)
+		}
+
+		if getCachedIso(spec, key, workdir) {
+			return nil
+		}
+		cacheKey = key
+	}
+
+	// Run openshift-install to generate the iso image
+	// ${INSTALLER_BIN} agent create image --log-level debug --dir="${INSTALLER_WORKDIR}"
+	if err := runAgentCreate(ctx, spec, workdir, "image"); err != nil {
+		return err
+	}
+
+	if cacheKey != "" {
+		putCachedIso(spec, cacheKey, workdir, isoPath)
+	}
+
+	return nil
+}
+
+// runAgentCreate will copy the configs into the workdir and run openshift-install agent create <target>
+func runAgentCreate(ctx context.Context, spec *BootstrapInPlaceIsoSpec, workdir string, target string) error {
 	// Move the agent config to the working directory
 	agentConfigData, err := os.ReadFile(spec.AgentConfigPath)
 	if err != nil {
@@ -36,23 +74,57 @@ func GenerateIso(spec *BootstrapInPlaceIsoSpec, workdir string) error {
 		return fmt.Errorf("could not write %s: %w", installConfigPath, err)
 	}
 
-	// Run openshift-install to generate the iso image
-	// ${INSTALLER_BIN} agent create image --log-level debug --dir="${INSTALLER_WORKDIR}"
+	// Custom manifests are added to the cluster by openshift-install
+	manifests, err := writeManifests(spec, workdir)
+	if err != nil {
+		return fmt.Errorf("unable to add the custom manifests: %w", err)
+	}
 
 	args := []string{
-		"agent", "create", "image",
+		"agent", "create", target,
 		"--log-level", "debug",
 		fmt.Sprintf("--dir=%s", workdir),
 	}
 
 	// Create the command
-	isoGenCmd := exec.Command(spec.AbiPath, args...)
-	isoGenCmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s", installer_image_override_env, spec.ReleaseImage))
+	isoGenCmd := runner.NewCommand(spec.AbiPath, args...)
+	isoGenCmd.Env = []string{fmt.Sprintf("%s=%s", installer_image_override_env, spec.ReleaseImage)}
+	if spec.Proxy != nil {
+		isoGenCmd.Env = append(isoGenCmd.Env, spec.Proxy.Env()...)
+	}
+
+	// Save everything the installer prints and log it as structured entries
+	logWorkdir := spec.LogWorkdir
+	if logWorkdir == "" {
+		logWorkdir = workdir
+	}
+
+	transcript, err := logger.OpenLogFile(logWorkdir, fmt.Sprintf(INSTALLER_TRANSCRIPT_FILE, target))
+	if err != nil {
+		return fmt.Errorf("unable to create the installer transcript: %w", err)
+	}
+	defer transcript.Close()
+
+	output := newInstallerOutput(transcript)
+	isoGenCmd.Stdout = output.Stream("stdout")
+	isoGenCmd.Stderr = output.Stream("stderr")
+	isoGenCmd.Quiet = true
+
+	err = runner.Run(ctx, isoGenCmd)
+	output.Flush()
 
-	_, err = isoGenCmd.CombinedOutput()
 	if err != nil {
+		if lines := output.ErrorLines(); len(lines) > 0 {
+			return fmt.Errorf("error running the agent based installer: %w: %s", err, strings.Join(lines, "; "))
+		}
+
 		return fmt.Errorf("error running the agent based installer: %w", err)
 	}
 
+	log := logger.Get()
+	for _, manifest := range manifests {
+		log.Infow("included custom manifest", "target", target, "manifest", manifest.Name, "objects", manifest.Objects)
+	}
+
 	return nil
 }
@@ -0,0 +1,50 @@
+package biputils
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+const PXE_ARTIFACTS_SUBFOLDER = "boot-artifacts"
+
+// PxeArtifacts are the files openshift-install generates to network boot the agent installer
+type PxeArtifacts struct {
+	Dir        string
+	Kernel     string
+	Initrd     string
+	Rootfs     string
+	IpxeScript string
+}
+
+// GetPxeArtifacts returns the names openshift-install uses for the artifacts of the architecture
+func GetPxeArtifacts(workdir string, openshiftArch string) *PxeArtifacts {
+	arch := installerArch(openshiftArch)
+
+	return &PxeArtifacts{
+		Dir:        filepath.Join(workdir, PXE_ARTIFACTS_SUBFOLDER),
+		Kernel:     fmt.Sprintf("agent.%s-vmlinuz", arch),
+		Initrd:     fmt.Sprintf("agent.%s-initrd.img", arch),
+		Rootfs:     fmt.Sprintf("agent.%s-rootfs.img", arch),
+		IpxeScript: fmt.Sprintf("agent.%s.ipxe", arch),
+	}
+}
+
+// GeneratePxeArtifacts will run openshift-install to create the kernel, initrd, rootfs and iPXE script.
+// The agent config must set bootArtifactsBaseURL to where the artifacts will be served from
+func GeneratePxeArtifacts(ctx context.Context, spec *BootstrapInPlaceIsoSpec, workdir string) (*PxeArtifacts, error) {
+	if err := spec.FillAndValidateIsoGenFields(); err != nil {
+		return nil, fmt.Errorf("unable to validate the required fields for pxe generation: %w", err)
+	}
+
+	if err := prepareInstaller(ctx, spec); err != nil {
+		return nil, fmt.Errorf("unable to prepare the installer: %w", err)
+	}
+
+	// ${INSTALLER_BIN} agent create pxe-files --log-level debug --dir="${INSTALLER_WORKDIR}"
+	if err := runAgentCreate(ctx, spec, workdir, "pxe-files"); err != nil {
+		return nil, err
+	}
+
+	return GetPxeArtifacts(workdir, spec.OpenshiftArch), nil
+}
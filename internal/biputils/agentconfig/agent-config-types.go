@@ -1,8 +1,259 @@
 package agentconfig
 
+import (
+	"fmt"
+	vmutils "snoman/internal/vms/utils"
+	"strconv"
+)
+
 type BootstrapInPlaceAgentConfigSpec struct {
-	VmName    string
-	HostIP    string
-	HostMAC   string
-	HostRoute string
+	VmName    string `validate:"required,hostname"`
+	HostIP    string `validate:"required,ip"`
+	HostMAC   string `validate:"omitempty,mac"`
+	HostRoute string `validate:"required,ip"`
+	// BootArtifactsBaseURL is where the PXE artifacts are served from, it is only needed for PXE installs
+	BootArtifactsBaseURL string `validate:"omitempty,url"`
+	// Interfaces are the host NICs, without any a single eno1 NIC with HostMAC is used
+	Interfaces []HostInterface `validate:"omitempty,dive"`
+	Bonds      []HostBond      `validate:"omitempty,dive"`
+	Vlans      []HostVlan      `validate:"omitempty,dive"`
+	// IPInterface is the interface, bond or vlan HostIP is set on. It defaults to the first NIC
+	IPInterface string
+	// RendezvousIP is the address of the host that runs the installation service, it defaults to HostIP
+	RendezvousIP string `validate:"omitempty,ip"`
+	Role         string `validate:"omitempty,oneof=master worker"`
+	// RootDeviceHints select the disk the host installs onto, the first suitable disk is used without them
+	RootDeviceHints *RootDeviceHints `validate:"omitempty"`
+	// NTPSources are added to the NTP servers of the host, DNSServers are used after HostRoute
+	NTPSources []string `validate:"omitempty,dive,hostname|ip"`
+	DNSServers []string `validate:"omitempty,dive,ip"`
+}
+
+type HostInterface struct {
+	Name       string `validate:"required"`
+	MacAddress string `validate:"required,mac"`
+}
+
+type HostBond struct {
+	Name  string   `validate:"required"`
+	Mode  string   `validate:"required"`
+	Ports []string `validate:"required,min=1"`
+}
+
+type HostVlan struct {
+	Name string `validate:"required"`
+	Base string `validate:"required"`
+	ID   uint   `validate:"required,min=1,max=4094"`
+}
+
+// RootDeviceHints are the agent-config rootDeviceHints, every hint that is set has to match the disk
+type RootDeviceHints struct {
+	DeviceName       string `yaml:"deviceName,omitempty" validate:"omitempty,startswith=/dev/"`
+	HCTL             string `yaml:"hctl,omitempty"`
+	Model            string `yaml:"model,omitempty"`
+	Vendor           string `yaml:"vendor,omitempty"`
+	SerialNumber     string `yaml:"serialNumber,omitempty"`
+	MinSizeGigabytes uint   `yaml:"minSizeGigabytes,omitempty"`
+	WWN              string `yaml:"wwn,omitempty"`
+	Rotational       *bool  `yaml:"rotational,omitempty"`
+}
+
+const (
+	DEFAULT_INTERFACE_NAME = "eno1"
+	DEFAULT_HOST_ROLE      = "master"
+	// DEFAULT_PREFIX_LENGTH is the prefix of the static host address
+	DEFAULT_PREFIX_LENGTH = 24
+)
+
+// NewHostVlan will name the vlan the way NetworkManager does, <base>.<id>
+func NewHostVlan(base string, id uint) HostVlan {
+	return HostVlan{
+		Name: fmt.Sprintf("%s.%d", base, id),
+		Base: base,
+		ID:   id,
+	}
+}
+
+// Validate will check the fields and that the bonds, vlans and IPInterface only name interfaces that exist
+func (spec *BootstrapInPlaceAgentConfigSpec) Validate() error {
+	if err := vmutils.SpecValidator.Struct(spec); err != nil {
+		return fmt.Errorf("unable to validate BootstrapInPlaceAgentConfigSpec: %w", err)
+	}
+
+	names := map[string]string{}
+	addName := func(name string, kind string) error {
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("%s '%s' has the same name as a %s", kind, name, other)
+		}
+
+		names[name] = kind
+		return nil
+	}
+
+	if len(spec.Interfaces) == 0 {
+		if spec.HostMAC == "" {
+			return fmt.Errorf("a host MAC address is required when no interfaces are set")
+		}
+
+		names[DEFAULT_INTERFACE_NAME] = "interface"
+	}
+
+	for _, iface := range spec.Interfaces {
+		if err := addName(iface.Name, "interface"); err != nil {
+			return err
+		}
+	}
+
+	for _, bond := range spec.Bonds {
+		for _, port := range bond.Ports {
+			if names[port] != "interface" {
+				return fmt.Errorf("bond '%s' port '%s' is not a host interface", bond.Name, port)
+			}
+		}
+
+		if err := addName(bond.Name, "bond"); err != nil {
+			return err
+		}
+	}
+
+	for _, vlan := range spec.Vlans {
+		if _, ok := names[vlan.Base]; !ok {
+			return fmt.Errorf("vlan '%s' base '%s' is not a host interface or bond", vlan.Name, vlan.Base)
+		}
+
+		if err := addName(vlan.Name, "vlan"); err != nil {
+			return err
+		}
+	}
+
+	if _, ok := names[spec.IPInterface]; spec.IPInterface != "" && !ok {
+		return fmt.Errorf("the host IP interface '%s' is not a host interface, bond or vlan", spec.IPInterface)
+	}
+
+	return nil
+}
+
+// AgentConfig is the openshift-install agent-config.yaml
+type AgentConfig struct {
+	APIVersion           string     `yaml:"apiVersion"`
+	Kind                 string     `yaml:"kind"`
+	Metadata             ObjectMeta `yaml:"metadata"`
+	RendezvousIP         string     `yaml:"rendezvousIP"`
+	BootArtifactsBaseURL string     `yaml:"bootArtifactsBaseURL,omitempty"`
+	AdditionalNTPSources []string   `yaml:"additionalNTPSources,omitempty"`
+	Hosts                []Host     `yaml:"hosts"`
+}
+
+type ObjectMeta struct {
+	Name string `yaml:"name"`
+}
+
+type Host struct {
+	Hostname        string           `yaml:"hostname"`
+	Role            string           `yaml:"role,omitempty"`
+	RootDeviceHints *RootDeviceHints `yaml:"rootDeviceHints,omitempty"`
+	Interfaces      []Interface      `yaml:"interfaces"`
+	NetworkConfig   NetworkConfig    `yaml:"networkConfig"`
+}
+
+type Interface struct {
+	Name       string `yaml:"name"`
+	MacAddress string `yaml:"macAddress"`
+}
+
+// NetworkConfig is the NMState config of the host
+type NetworkConfig struct {
+	Interfaces  []NMStateInterface `yaml:"interfaces"`
+	Routes      NMStateRoutes      `yaml:"routes"`
+	DNSResolver NMStateDNSResolver `yaml:"dns-resolver"`
+}
+
+type NMStateInterface struct {
+	Name            string                  `yaml:"name"`
+	Type            string                  `yaml:"type"`
+	State           string                  `yaml:"state"`
+	MacAddress      string                  `yaml:"mac-address,omitempty"`
+	LinkAggregation *NMStateLinkAggregation `yaml:"link-aggregation,omitempty"`
+	Vlan            *NMStateVlan            `yaml:"vlan,omitempty"`
+	IPv4            NMStateIPv4             `yaml:"ipv4"`
+}
+
+type NMStateLinkAggregation struct {
+	Mode string   `yaml:"mode"`
+	Port []string `yaml:"port"`
+}
+
+type NMStateVlan struct {
+	BaseIface string `yaml:"base-iface"`
+	ID        uint   `yaml:"id"`
+}
+
+type NMStateIPv4 struct {
+	Enabled bool             `yaml:"enabled"`
+	Address []NMStateAddress `yaml:"address,omitempty"`
+	DHCP    bool             `yaml:"dhcp,omitempty"`
+}
+
+type NMStateAddress struct {
+	IP           string `yaml:"ip"`
+	PrefixLength uint   `yaml:"prefix-length"`
+}
+
+type NMStateRoutes struct {
+	Config []NMStateRoute `yaml:"config"`
+}
+
+type NMStateRoute struct {
+	Destination      string `yaml:"destination"`
+	NextHopAddress   string `yaml:"next-hop-address"`
+	NextHopInterface string `yaml:"next-hop-interface"`
+}
+
+type NMStateDNSResolver struct {
+	Config NMStateDNSConfig `yaml:"config"`
+}
+
+type NMStateDNSConfig struct {
+	Server []string `yaml:"server"`
+}
+
+// ParseRootDeviceHints will build the hints from their agent-config names, ex: deviceName=/dev/vda
+func ParseRootDeviceHints(hints map[string]string) (*RootDeviceHints, error) {
+	if len(hints) == 0 {
+		return nil, nil
+	}
+
+	parsed := &RootDeviceHints{}
+	for key, value := range hints {
+		switch key {
+		case "deviceName":
+			parsed.DeviceName = value
+		case "hctl":
+			parsed.HCTL = value
+		case "model":
+			parsed.Model = value
+		case "vendor":
+			parsed.Vendor = value
+		case "serialNumber":
+			parsed.SerialNumber = value
+		case "wwn":
+			parsed.WWN = value
+		case "minSizeGigabytes":
+			size, err := strconv.ParseUint(value, 10, 0)
+			if err != nil {
+				return nil, fmt.Errorf("invalid root device hint minSizeGigabytes '%s': %w", value, err)
+			}
+			parsed.MinSizeGigabytes = uint(size)
+		case "rotational":
+			rotational, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid root device hint rotational '%s': %w", value, err)
+			}
+			parsed.Rotational = &rotational
+		default:
+			return nil, fmt.Errorf("unknown root device hint '%s'", key)
+		}
+	}
+
+	return parsed, nil
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"gopkg.in/yaml.v2"
 )
 
 func CreateBootstrapAgentConfigFile(spec *BootstrapInPlaceAgentConfigSpec, workdir string) error {
@@ -18,3 +20,116 @@ func CreateBootstrapAgentConfigFile(spec *BootstrapInPlaceAgentConfigSpec, workd
 
 	return nil
 }
+
+func GetBipAgentConfig(spec *BootstrapInPlaceAgentConfigSpec) (string, error) {
+	if err := spec.Validate(); err != nil {
+		return "", err
+	}
+
+	data, err := yaml.Marshal(NewBipAgentConfig(spec))
+	if err != nil {
+		return "", fmt.Errorf("unable to generate agent config contents: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// NewBipAgentConfig will build the agent config of the single host, spec should be validated first
+func NewBipAgentConfig(spec *BootstrapInPlaceAgentConfigSpec) *AgentConfig {
+	rendezvousIP := spec.RendezvousIP
+	if rendezvousIP == "" {
+		rendezvousIP = spec.HostIP
+	}
+
+	role := spec.Role
+	if role == "" {
+		role = DEFAULT_HOST_ROLE
+	}
+
+	hostInterfaces := spec.Interfaces
+	if len(hostInterfaces) == 0 {
+		hostInterfaces = []HostInterface{{Name: DEFAULT_INTERFACE_NAME, MacAddress: spec.HostMAC}}
+	}
+
+	routeInterface := spec.IPInterface
+	if routeInterface == "" {
+		routeInterface = hostInterfaces[0].Name
+	}
+
+	host := Host{
+		Hostname:        spec.VmName,
+		Role:            role,
+		RootDeviceHints: spec.RootDeviceHints,
+		NetworkConfig: NetworkConfig{
+			Interfaces: newNMStateInterfaces(spec, hostInterfaces, routeInterface),
+			Routes: NMStateRoutes{
+				Config: []NMStateRoute{{
+					Destination:      "0.0.0.0/0",
+					NextHopAddress:   spec.HostRoute,
+					NextHopInterface: routeInterface,
+				}},
+			},
+			DNSResolver: NMStateDNSResolver{
+				Config: NMStateDNSConfig{Server: append([]string{spec.HostRoute}, spec.DNSServers...)},
+			},
+		},
+	}
+
+	for _, iface := range hostInterfaces {
+		host.Interfaces = append(host.Interfaces, Interface{Name: iface.Name, MacAddress: iface.MacAddress})
+	}
+
+	return &AgentConfig{
+		APIVersion:           "v1alpha1",
+		Kind:                 "AgentConfig",
+		Metadata:             ObjectMeta{Name: fmt.Sprintf("%s-sno-cluster", spec.VmName)},
+		RendezvousIP:         rendezvousIP,
+		BootArtifactsBaseURL: spec.BootArtifactsBaseURL,
+		AdditionalNTPSources: spec.NTPSources,
+		Hosts:                []Host{host},
+	}
+}
+
+// newNMStateInterfaces will lay out the NMState interfaces. The host IP goes on the route interface, NICs that
+// are bond ports or vlan bases are left unaddressed, and everything else uses DHCP
+func newNMStateInterfaces(spec *BootstrapInPlaceAgentConfigSpec, hostInterfaces []HostInterface, routeInterface string) []NMStateInterface {
+	// Interfaces something else is layered on top of do not get an address of their own
+	lower := map[string]bool{}
+	for _, bond := range spec.Bonds {
+		for _, port := range bond.Ports {
+			lower[port] = true
+		}
+	}
+	for _, vlan := range spec.Vlans {
+		lower[vlan.Base] = true
+	}
+
+	interfaces := []NMStateInterface{}
+	add := func(iface NMStateInterface) {
+		iface.State = "up"
+
+		switch {
+		case iface.Name == routeInterface:
+			iface.IPv4 = NMStateIPv4{
+				Enabled: true,
+				Address: []NMStateAddress{{IP: spec.HostIP, PrefixLength: DEFAULT_PREFIX_LENGTH}},
+			}
+		case !lower[iface.Name]:
+			iface.IPv4 = NMStateIPv4{Enabled: true, DHCP: true}
+		}
+
+		interfaces = append(interfaces, iface)
+	}
+
+	for _, iface := range hostInterfaces {
+		add(NMStateInterface{Name: iface.Name, Type: "ethernet", MacAddress: iface.MacAddress})
+	}
+	for _, bond := range spec.Bonds {
+		add(NMStateInterface{Name: bond.Name, Type: "bond", LinkAggregation: &NMStateLinkAggregation{Mode: bond.Mode, Port: bond.Ports}})
+	}
+	for _, vlan := range spec.Vlans {
+		add(NMStateInterface{Name: vlan.Name, Type: "vlan", Vlan: &NMStateVlan{BaseIface: vlan.Base, ID: vlan.ID}})
+	}
+
+	return interfaces
+}
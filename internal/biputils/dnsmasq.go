@@ -1,11 +1,12 @@
 package biputils
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"os/user"
 	"snoman/internal/logger"
+	"snoman/internal/runner"
 
 	"go.uber.org/zap"
 )
@@ -14,13 +15,18 @@ const (
 	BIP_NETWORK_CONF_FILE = "/etc/NetworkManager/conf.d/bip.conf"
 )
 
-func CreateDnsmasqConfig(address string) error {
+// CreateDnsmasqConfig will have the NetworkManager dnsmasq resolve the address with a config written to
+// path, BIP_NETWORK_CONF_FILE is used when it is empty
+func CreateDnsmasqConfig(ctx context.Context, path string, address string) error {
 	log := logger.Get()
-	if !userIsRoot() {
-		return fmt.Errorf("bootstrap in place requires elevated priveleges. Please run again as root")
+	if path == "" {
+		if !userIsRoot() {
+			return fmt.Errorf("bootstrap in place requires elevated priveleges. Please run again as root")
+		}
+		path = BIP_NETWORK_CONF_FILE
 	}
 
-	if err := setDnsMasqConfig(address, log); err != nil {
+	if err := setDnsMasqConfig(ctx, path, address, log); err != nil {
 		return fmt.Errorf("unable to configure dnsmasq: %w", err)
 	}
 
@@ -28,16 +34,17 @@ func CreateDnsmasqConfig(address string) error {
 }
 
 // setDnsMasqConfig requires sudo
-func setDnsMasqConfig(address string, log *zap.SugaredLogger) error {
-	log.Infof("writing dnsmasq config for bootstrap in place to %s", BIP_NETWORK_CONF_FILE)
+func setDnsMasqConfig(ctx context.Context, path string, address string, log *zap.SugaredLogger) error {
+	log.Infof("writing dnsmasq config for bootstrap in place to %s", path)
 
 	filedata := []byte(fmt.Sprintf("[main]\ndns=dnsmasq\naddress=%s", address))
 
-	os.WriteFile(BIP_NETWORK_CONF_FILE, filedata, 0644)
+	if err := os.WriteFile(path, filedata, 0644); err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
 
 	log.Infof("reloading NetworkManager after new config was added")
-	cmd := exec.Command("systemctl", "reload", "NetworkManager.service")
-	if err := cmd.Run(); err != nil {
+	if err := runner.Run(ctx, runner.NewCommand("systemctl", "reload", "NetworkManager.service")); err != nil {
 		return fmt.Errorf("unable to restart NetworkManager: %w", err)
 	}
 
@@ -1,5 +1,11 @@
 package installconfig
 
+import (
+	"fmt"
+	"snoman/internal/arch"
+	vmutils "snoman/internal/vms/utils"
+)
+
 type BootstrapInPlaceInstallConfigSpec struct {
 	BaseDomain        string
 	ClusterName       string
@@ -9,4 +15,121 @@ type BootstrapInPlaceInstallConfigSpec struct {
 	InstallDisk       string
 	PullSecret        string
 	SshPubKey         string
+	// Architecture accepts the release names (x86_64, aarch64) as well as the install-config ones (amd64, arm64)
+	Architecture string `validate:"omitempty,oneof=amd64 arm64 ppc64le s390x"`
+	NetworkType  string `validate:"omitempty,oneof=OVNKubernetes OpenShiftSDN"`
+	HostPrefix   uint   `validate:"omitempty,min=1,max=128"`
+	Publish      string `validate:"omitempty,oneof=External Internal"`
+	FIPS         bool
+	// BaselineCapabilitySet is None, vCurrent or a version like v4.14, the cluster default is used when empty
+	BaselineCapabilitySet  string
+	AdditionalCapabilities []string
+	// Mirror is set for disconnected installs
+	Mirror *MirrorSpec
+	// Proxy is set when the cluster reaches the internet through an HTTP proxy
+	Proxy *ProxySpec `validate:"omitempty"`
+}
+
+const (
+	DEFAULT_ARCHITECTURE = "amd64"
+	DEFAULT_NETWORK_TYPE = "OVNKubernetes"
+	DEFAULT_HOST_PREFIX  = 23
+	DEFAULT_PUBLISH      = "External"
+	// TRUST_BUNDLE_POLICY_ALWAYS adds the trust bundle to the cluster CAs, not only to the proxy
+	TRUST_BUNDLE_POLICY_ALWAYS = "Always"
+)
+
+// Architecture will return the install-config name of a release image architecture, ex: x86_64 is amd64
+func Architecture(name string) string {
+	return arch.GoArch(name)
+}
+
+// FillAndValidate will populate any empty optional fields with defaults and then validate the struct
+func (spec *BootstrapInPlaceInstallConfigSpec) FillAndValidate() error {
+	spec.Architecture = Architecture(spec.Architecture)
+	if spec.Architecture == "" {
+		spec.Architecture = DEFAULT_ARCHITECTURE
+	}
+
+	if spec.NetworkType == "" {
+		spec.NetworkType = DEFAULT_NETWORK_TYPE
+	}
+
+	if spec.HostPrefix == 0 {
+		spec.HostPrefix = DEFAULT_HOST_PREFIX
+	}
+
+	if spec.Publish == "" {
+		spec.Publish = DEFAULT_PUBLISH
+	}
+
+	if err := vmutils.SpecValidator.Struct(spec); err != nil {
+		return fmt.Errorf("unable to validate BootstrapInPlaceInstallConfigSpec: %w", err)
+	}
+
+	return nil
+}
+
+// InstallConfig is the part of the openshift-install install-config.yaml that snoman generates
+type InstallConfig struct {
+	APIVersion       string            `yaml:"apiVersion"`
+	BaseDomain       string            `yaml:"baseDomain"`
+	Compute          []MachinePool     `yaml:"compute"`
+	ControlPlane     MachinePool       `yaml:"controlPlane"`
+	Metadata         ObjectMeta        `yaml:"metadata"`
+	Networking       Networking        `yaml:"networking"`
+	Platform         Platform          `yaml:"platform"`
+	BootstrapInPlace *BootstrapInPlace `yaml:"bootstrapInPlace,omitempty"`
+	Capabilities     *Capabilities     `yaml:"capabilities,omitempty"`
+	FIPS             bool              `yaml:"fips,omitempty"`
+	Publish          string            `yaml:"publish"`
+	PullSecret       string            `yaml:"pullSecret"`
+	SSHKey           string            `yaml:"sshKey"`
+	Proxy            *Proxy            `yaml:"proxy,omitempty"`
+	// Disconnected installs
+	AdditionalTrustBundle       string              `yaml:"additionalTrustBundle,omitempty"`
+	AdditionalTrustBundlePolicy string              `yaml:"additionalTrustBundlePolicy,omitempty"`
+	ImageDigestSources          []ImageDigestSource `yaml:"imageDigestSources,omitempty"`
+}
+
+type MachinePool struct {
+	Architecture   string   `yaml:"architecture"`
+	Hyperthreading string   `yaml:"hyperthreading"`
+	Name           string   `yaml:"name"`
+	Platform       struct{} `yaml:"platform"`
+	Replicas       int      `yaml:"replicas"`
+}
+
+type ObjectMeta struct {
+	Name string `yaml:"name"`
+}
+
+type Networking struct {
+	ClusterNetwork []ClusterNetworkEntry `yaml:"clusterNetwork"`
+	MachineNetwork []MachineNetworkEntry `yaml:"machineNetwork"`
+	NetworkType    string                `yaml:"networkType"`
+	ServiceNetwork []string              `yaml:"serviceNetwork"`
+}
+
+type ClusterNetworkEntry struct {
+	CIDR       string `yaml:"cidr"`
+	HostPrefix uint   `yaml:"hostPrefix"`
+}
+
+type MachineNetworkEntry struct {
+	CIDR string `yaml:"cidr"`
+}
+
+// Platform only supports none, single node clusters do not integrate with an infrastructure provider
+type Platform struct {
+	None struct{} `yaml:"none"`
+}
+
+type BootstrapInPlace struct {
+	InstallationDisk string `yaml:"installationDisk"`
+}
+
+type Capabilities struct {
+	BaselineCapabilitySet         string   `yaml:"baselineCapabilitySet,omitempty"`
+	AdditionalEnabledCapabilities []string `yaml:"additionalEnabledCapabilities,omitempty"`
 }
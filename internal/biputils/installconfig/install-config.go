@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"snoman/internal/biputils/secrets"
+	"strings"
+
+	"gopkg.in/yaml.v2"
 )
 
 func CreateBootstrapInstallConfigFile(spec *BootstrapInPlaceInstallConfigSpec, workdir string) error {
@@ -18,3 +22,92 @@ func CreateBootstrapInstallConfigFile(spec *BootstrapInPlaceInstallConfigSpec, w
 
 	return nil
 }
+
+func GetBipInstallConfig(spec *BootstrapInPlaceInstallConfigSpec) (string, error) {
+	if err := spec.FillAndValidate(); err != nil {
+		return "", err
+	}
+
+	config, err := NewBipInstallConfig(spec)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate install config contents: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// NewBipInstallConfig will build the install config of a single node cluster, spec must already be filled
+func NewBipInstallConfig(spec *BootstrapInPlaceInstallConfigSpec) (*InstallConfig, error) {
+	config := &InstallConfig{
+		APIVersion: "v1",
+		BaseDomain: spec.BaseDomain,
+		Compute: []MachinePool{{
+			Architecture:   spec.Architecture,
+			Hyperthreading: "Enabled",
+			Name:           "worker",
+			Replicas:       0,
+		}},
+		ControlPlane: MachinePool{
+			Architecture:   spec.Architecture,
+			Hyperthreading: "Enabled",
+			Name:           "master",
+			Replicas:       1,
+		},
+		Metadata: ObjectMeta{Name: spec.ClusterName},
+		Networking: Networking{
+			ClusterNetwork: []ClusterNetworkEntry{{CIDR: spec.ClusterNetwork, HostPrefix: spec.HostPrefix}},
+			MachineNetwork: []MachineNetworkEntry{{CIDR: spec.MachineNetwork}},
+			NetworkType:    spec.NetworkType,
+			ServiceNetwork: []string{spec.ClusterSvcNetwork},
+		},
+		FIPS:    spec.FIPS,
+		Publish: spec.Publish,
+		// The secrets are often read from files, so drop the trailing new line
+		PullSecret: strings.TrimSpace(spec.PullSecret),
+		SSHKey:     strings.TrimSpace(spec.SshPubKey),
+	}
+
+	if spec.InstallDisk != "" {
+		config.BootstrapInPlace = &BootstrapInPlace{InstallationDisk: spec.InstallDisk}
+	}
+
+	if spec.BaselineCapabilitySet != "" || len(spec.AdditionalCapabilities) > 0 {
+		config.Capabilities = &Capabilities{
+			BaselineCapabilitySet:         spec.BaselineCapabilitySet,
+			AdditionalEnabledCapabilities: spec.AdditionalCapabilities,
+		}
+	}
+
+	if proxy := spec.ClusterProxy(); proxy != nil {
+		config.Proxy = &Proxy{
+			HTTPProxy:  proxy.HTTPProxy,
+			HTTPSProxy: proxy.HTTPSProxy,
+			NoProxy:    strings.Join(proxy.NoProxy, ","),
+		}
+	}
+
+	// The nodes pull everything from the mirror with the credentials and CA of the mirror registry
+	if spec.Mirror != nil {
+		config.ImageDigestSources = spec.Mirror.ImageDigestSources
+
+		if spec.Mirror.AdditionalTrustBundle != "" {
+			config.AdditionalTrustBundle = strings.TrimSpace(spec.Mirror.AdditionalTrustBundle) + "\n"
+			config.AdditionalTrustBundlePolicy = TRUST_BUNDLE_POLICY_ALWAYS
+		}
+
+		if spec.Mirror.PullSecret != "" {
+			pullSecret, err := secrets.MergePullSecrets(config.PullSecret, spec.Mirror.PullSecret)
+			if err != nil {
+				return nil, fmt.Errorf("unable to add the mirror credentials to the pull secret: %w", err)
+			}
+			config.PullSecret = pullSecret
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,109 @@
+package installconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// MirrorSpec configures a disconnected install that pulls every image from a mirror registry
+type MirrorSpec struct {
+	ImageDigestSources []ImageDigestSource
+	// AdditionalTrustBundle is the PEM CA bundle of the mirror registry
+	AdditionalTrustBundle string
+	// PullSecret is a docker auth config with the mirror credentials, it is merged into the cluster pull secret
+	PullSecret string
+}
+
+// ImageDigestSource is an install-config imageDigestSources entry, images pulled by digest from source are
+// pulled from the mirrors instead
+type ImageDigestSource struct {
+	Source  string   `yaml:"source"`
+	Mirrors []string `yaml:"mirrors"`
+}
+
+// digestMirrorSet is the part of an ImageDigestMirrorSet or ImageContentSourcePolicy with the mirrors
+type digestMirrorSet struct {
+	Kind string `yaml:"kind"`
+	Spec struct {
+		ImageDigestMirrors      []ImageDigestSource `yaml:"imageDigestMirrors"`
+		RepositoryDigestMirrors []ImageDigestSource `yaml:"repositoryDigestMirrors"`
+	} `yaml:"spec"`
+}
+
+// ParseMirrorMap will build the digest sources from source=mirror pairs, a source can be repeated to add mirrors
+func ParseMirrorMap(pairs []string) ([]ImageDigestSource, error) {
+	mirrors := map[string][]string{}
+	for _, pair := range pairs {
+		source, mirror, found := strings.Cut(pair, "=")
+		if !found || source == "" || mirror == "" {
+			return nil, fmt.Errorf("invalid mirror '%s', expected source=mirror", pair)
+		}
+
+		mirrors[source] = append(mirrors[source], mirror)
+	}
+
+	sources := []ImageDigestSource{}
+	for source, m := range mirrors {
+		sources = append(sources, ImageDigestSource{Source: source, Mirrors: m})
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Source < sources[j].Source })
+
+	return sources, nil
+}
+
+// LoadImageDigestSources will read the mirrors of the ImageDigestMirrorSet or ImageContentSourcePolicy
+// documents in the file, like the ones oc-mirror and oc adm release mirror write
+func LoadImageDigestSources(path string) ([]ImageDigestSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read mirror config '%s': %w", path, err)
+	}
+
+	sources := []ImageDigestSource{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		set := &digestMirrorSet{}
+		if err := decoder.Decode(set); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to parse mirror config '%s': %w", path, err)
+		}
+
+		switch set.Kind {
+		case "ImageDigestMirrorSet":
+			sources = append(sources, set.Spec.ImageDigestMirrors...)
+		case "ImageContentSourcePolicy":
+			sources = append(sources, set.Spec.RepositoryDigestMirrors...)
+		case "":
+			// Empty documents between separators
+		default:
+			return nil, fmt.Errorf("mirror config '%s' has an unsupported kind '%s', expected ImageDigestMirrorSet or ImageContentSourcePolicy", path, set.Kind)
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("mirror config '%s' does not have any mirrors", path)
+	}
+
+	return sources, nil
+}
+
+// IsMirrored will return true if the image repository is one of the mirrors or inside one
+func (spec *MirrorSpec) IsMirrored(repository string) bool {
+	for _, source := range spec.ImageDigestSources {
+		for _, mirror := range source.Mirrors {
+			if repository == mirror || strings.HasPrefix(repository, mirror+"/") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
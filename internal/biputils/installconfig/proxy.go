@@ -0,0 +1,66 @@
+package installconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProxySpec is the HTTP proxy the cluster, and openshift-install, reach the internet through
+type ProxySpec struct {
+	HTTPProxy  string   `yaml:"http_proxy,omitempty" validate:"omitempty,url"`
+	HTTPSProxy string   `yaml:"https_proxy,omitempty" validate:"omitempty,url"`
+	NoProxy    []string `yaml:"no_proxy,omitempty" validate:"omitempty"`
+}
+
+// Proxy is the install-config proxy, noProxy is a comma separated list
+type Proxy struct {
+	HTTPProxy  string `yaml:"httpProxy,omitempty"`
+	HTTPSProxy string `yaml:"httpsProxy,omitempty"`
+	NoProxy    string `yaml:"noProxy,omitempty"`
+}
+
+// Env will return the proxy environment variables, in both cases since tools disagree on which one they read
+func (spec *ProxySpec) Env() []string {
+	env := []string{}
+	add := func(name string, value string) {
+		if value != "" {
+			env = append(env, fmt.Sprintf("%s=%s", name, value), fmt.Sprintf("%s=%s", strings.ToLower(name), value))
+		}
+	}
+
+	add("HTTP_PROXY", spec.HTTPProxy)
+	add("HTTPS_PROXY", spec.HTTPSProxy)
+	add("NO_PROXY", strings.Join(spec.NoProxy, ","))
+
+	return env
+}
+
+// ClusterProxy will return the proxy with the cluster networks and domain added to NoProxy, so traffic inside
+// the cluster and its machine network never goes through the proxy
+func (spec *BootstrapInPlaceInstallConfigSpec) ClusterProxy() *ProxySpec {
+	if spec.Proxy == nil {
+		return nil
+	}
+
+	entries := append([]string{}, spec.Proxy.NoProxy...)
+	entries = append(entries, spec.MachineNetwork, spec.ClusterNetwork, spec.ClusterSvcNetwork)
+	if spec.ClusterName != "" && spec.BaseDomain != "" {
+		entries = append(entries, fmt.Sprintf(".%s.%s", spec.ClusterName, spec.BaseDomain))
+	}
+
+	noProxy := []string{}
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry != "" && !seen[entry] {
+			seen[entry] = true
+			noProxy = append(noProxy, entry)
+		}
+	}
+
+	return &ProxySpec{
+		HTTPProxy:  spec.Proxy.HTTPProxy,
+		HTTPSProxy: spec.Proxy.HTTPSProxy,
+		NoProxy:    noProxy,
+	}
+}
@@ -0,0 +1,222 @@
+package biputils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"snoman/internal/logger"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// INSTALLER_TRANSCRIPT_FILE is the log file the installer output is saved to, named after the agent create target
+	INSTALLER_TRANSCRIPT_FILE = "openshift-install-%s.log"
+	// DEFAULT_INSTALLER_ERROR_LINES is how many of the last installer errors are added to the returned error
+	DEFAULT_INSTALLER_ERROR_LINES = 5
+)
+
+// installerLevels maps the logrus levels of openshift-install to zap levels
+var installerLevels = map[string]zapcore.Level{
+	"trace":   zapcore.DebugLevel,
+	"debug":   zapcore.DebugLevel,
+	"info":    zapcore.InfoLevel,
+	"warning": zapcore.WarnLevel,
+	"warn":    zapcore.WarnLevel,
+	"error":   zapcore.ErrorLevel,
+	"fatal":   zapcore.ErrorLevel,
+	"panic":   zapcore.ErrorLevel,
+}
+
+// installerOutput logs the output of openshift-install as structured entries, saves it to a transcript and
+// keeps the last error lines so a failure can say what went wrong
+type installerOutput struct {
+	mux        sync.Mutex
+	log        *zap.SugaredLogger
+	transcript io.Writer
+	errorLines []string
+	streams    []*installerStream
+}
+
+func newInstallerOutput(transcript io.Writer) *installerOutput {
+	return &installerOutput{
+		log:        logger.Get().With("installer", "openshift-install"),
+		transcript: transcript,
+	}
+}
+
+// Stream will return the writer for one output stream of openshift-install, ex: stdout
+func (o *installerOutput) Stream(name string) io.Writer {
+	stream := &installerStream{out: o, name: name}
+	o.streams = append(o.streams, stream)
+
+	return stream
+}
+
+// Flush will handle any output left without a trailing new line, call it once openshift-install exits
+func (o *installerOutput) Flush() {
+	for _, stream := range o.streams {
+		stream.flush()
+	}
+}
+
+// ErrorLines will return the last errors openshift-install reported, oldest first
+func (o *installerOutput) ErrorLines() []string {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	return append([]string{}, o.errorLines...)
+}
+
+func (o *installerOutput) line(stream string, line string) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	// The transcript keeps the output as written, both streams interleaved
+	if o.transcript != nil {
+		fmt.Fprintln(o.transcript, line)
+	}
+
+	level, msg, fields, ok := parseInstallerLine(line)
+	if !ok {
+		// Anything that is not a log entry on stderr is most likely a crash
+		level, msg, fields = zapcore.InfoLevel, line, nil
+		if stream == "stderr" {
+			level = zapcore.WarnLevel
+			o.addErrorLine(line)
+		}
+	} else if level >= zapcore.ErrorLevel {
+		o.addErrorLine(describeInstallerError(msg, fields))
+	}
+
+	o.log.Logw(level, msg, append(fields, "stream", stream)...)
+}
+
+func (o *installerOutput) addErrorLine(line string) {
+	o.errorLines = append(o.errorLines, line)
+	if len(o.errorLines) > DEFAULT_INSTALLER_ERROR_LINES {
+		o.errorLines = o.errorLines[len(o.errorLines)-DEFAULT_INSTALLER_ERROR_LINES:]
+	}
+}
+
+// describeInstallerError will add the error field logrus entries usually carry to the message
+func describeInstallerError(msg string, fields []interface{}) string {
+	for i := 0; i+1 < len(fields); i += 2 {
+		if key := fields[i]; key == "error" || key == "err" {
+			return fmt.Sprintf("%s: %v", msg, fields[i+1])
+		}
+	}
+
+	return msg
+}
+
+// parseInstallerLine will parse a logrus text entry, ex: level=info msg="Consuming Install Config"
+// The time, level and msg keys are returned on their own and the rest as key value pairs
+func parseInstallerLine(line string) (zapcore.Level, string, []interface{}, bool) {
+	var level zapcore.Level
+	var msg string
+	var fields []interface{}
+	hasLevel, hasMsg := false, false
+
+	rest := strings.TrimSpace(line)
+	for rest != "" {
+		key, value, remaining, ok := nextLogfmtPair(rest)
+		if !ok {
+			return level, "", nil, false
+		}
+		rest = remaining
+
+		switch key {
+		case "time":
+		case "level":
+			level, hasLevel = installerLevels[value]
+			if !hasLevel {
+				level, hasLevel = zapcore.InfoLevel, true
+			}
+		case "msg":
+			msg, hasMsg = value, true
+		default:
+			fields = append(fields, key, value)
+		}
+	}
+
+	return level, msg, fields, hasLevel && hasMsg
+}
+
+// nextLogfmtPair will read one key=value pair, the value can be quoted
+func nextLogfmtPair(s string) (string, string, string, bool) {
+	key, rest, found := strings.Cut(s, "=")
+	if !found || key == "" || strings.ContainsAny(key, " \t\"") {
+		return "", "", "", false
+	}
+
+	// Unquoted values run until the next space
+	if !strings.HasPrefix(rest, `"`) {
+		value, remaining, _ := strings.Cut(rest, " ")
+		return key, value, strings.TrimLeft(remaining, " "), true
+	}
+
+	// Find the closing quote, skipping escaped characters
+	end := -1
+	for i := 1; i < len(rest); i++ {
+		if rest[i] == '\\' {
+			i++
+		} else if rest[i] == '"' {
+			end = i
+			break
+		}
+	}
+
+	if end < 0 {
+		return "", "", "", false
+	}
+
+	value, err := strconv.Unquote(rest[:end+1])
+	if err != nil {
+		return "", "", "", false
+	}
+
+	return key, value, strings.TrimLeft(rest[end+1:], " "), true
+}
+
+// installerStream splits one output stream of openshift-install into lines
+type installerStream struct {
+	out  *installerOutput
+	name string
+	mux  sync.Mutex
+	buf  []byte
+}
+
+func (s *installerStream) Write(p []byte) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.buf = append(s.buf, p...)
+	for {
+		i := bytes.IndexByte(s.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		if line := bytes.TrimRight(s.buf[:i], "\r "); len(line) > 0 {
+			s.out.line(s.name, string(line))
+		}
+		s.buf = s.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+func (s *installerStream) flush() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if line := bytes.TrimSpace(s.buf); len(line) > 0 {
+		s.out.line(s.name, string(line))
+	}
+	s.buf = nil
+}
@@ -0,0 +1,187 @@
+package biputils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// streamWrite is one write to an installer output stream
+type streamWrite struct {
+	stream string
+	data   string
+}
+
+func TestInstallerOutput(t *testing.T) {
+	tests := []struct {
+		name           string
+		writes         []streamWrite
+		wantErrors     []string
+		wantTranscript string
+	}{
+		{
+			name: "log entries",
+			writes: []streamWrite{
+				{"stderr", "level=info msg=\"Consuming Install Config from target directory\"\n"},
+				{"stderr", "level=warning msg=\"Release Image Architecture not detected\"\n"},
+			},
+			wantTranscript: "level=info msg=\"Consuming Install Config from target directory\"\n" +
+				"level=warning msg=\"Release Image Architecture not detected\"\n",
+		},
+		{
+			name: "errors with escaped quotes",
+			writes: []streamWrite{
+				{"stderr", `time="2024-03-01T12:00:00Z" level=error msg="failed to fetch \"Agent ISO\"" error="open \"/tmp/x\": no such file"` + "\n"},
+				{"stderr", "level=fatal msg=\"quoted\\\"value\"\n"},
+			},
+			wantErrors: []string{
+				`failed to fetch "Agent ISO": open "/tmp/x": no such file`,
+				`quoted"value`,
+			},
+			wantTranscript: `time="2024-03-01T12:00:00Z" level=error msg="failed to fetch \"Agent ISO\"" error="open \"/tmp/x\": no such file"` + "\n" +
+				"level=fatal msg=\"quoted\\\"value\"\n",
+		},
+		{
+			name: "partial writes",
+			writes: []streamWrite{
+				{"stderr", "level=err"},
+				{"stderr", "or msg=\"split "},
+				{"stderr", "across writes\"\r\nlevel=info msg=done\nlevel=error msg=\"without a new line\""},
+			},
+			wantErrors: []string{
+				"split across writes",
+				"without a new line",
+			},
+			wantTranscript: "level=error msg=\"split across writes\"\n" +
+				"level=info msg=done\n" +
+				"level=error msg=\"without a new line\"\n",
+		},
+		{
+			name: "lines that are not log entries",
+			writes: []streamWrite{
+				{"stdout", "plain stdout output\n"},
+				{"stderr", "panic: runtime error: invalid memory address\n\n"},
+				{"stderr", "level=error msg=\"unterminated\n"},
+				{"stderr", "level=info\n"},
+			},
+			wantErrors: []string{
+				"panic: runtime error: invalid memory address",
+				`level=error msg="unterminated`,
+				"level=info",
+			},
+			wantTranscript: "plain stdout output\n" +
+				"panic: runtime error: invalid memory address\n" +
+				"level=error msg=\"unterminated\n" +
+				"level=info\n",
+		},
+		{
+			name: "only the last errors are kept",
+			writes: func() []streamWrite {
+				writes := []streamWrite{}
+				for i := 1; i <= DEFAULT_INSTALLER_ERROR_LINES+2; i++ {
+					writes = append(writes, streamWrite{"stderr", fmt.Sprintf("level=error msg=\"error %d\"\n", i)})
+				}
+				return writes
+			}(),
+			wantErrors: []string{"error 3", "error 4", "error 5", "error 6", "error 7"},
+			wantTranscript: func() string {
+				transcript := ""
+				for i := 1; i <= DEFAULT_INSTALLER_ERROR_LINES+2; i++ {
+					transcript += fmt.Sprintf("level=error msg=\"error %d\"\n", i)
+				}
+				return transcript
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcript := &bytes.Buffer{}
+			out := newInstallerOutput(transcript)
+			streams := map[string]io.Writer{
+				"stdout": out.Stream("stdout"),
+				"stderr": out.Stream("stderr"),
+			}
+
+			for _, w := range tt.writes {
+				n, err := streams[w.stream].Write([]byte(w.data))
+				if err != nil || n != len(w.data) {
+					t.Fatalf("Write() = %d, %v, want %d", n, err, len(w.data))
+				}
+			}
+			out.Flush()
+
+			wantErrors := append([]string{}, tt.wantErrors...)
+			if got := out.ErrorLines(); !reflect.DeepEqual(got, wantErrors) {
+				t.Errorf("ErrorLines() = %q, want %q", got, wantErrors)
+			}
+			if got := transcript.String(); got != tt.wantTranscript {
+				t.Errorf("transcript = %q, want %q", got, tt.wantTranscript)
+			}
+		})
+	}
+}
+
+func TestParseInstallerLine(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		wantMsg    string
+		wantFields []interface{}
+		wantOk     bool
+	}{
+		{
+			name:    "time and level are not fields",
+			line:    `time="2024-03-01T12:00:00Z" level=info msg="Consuming Install Config"`,
+			wantMsg: "Consuming Install Config",
+			wantOk:  true,
+		},
+		{
+			name:       "extra fields",
+			line:       `level=debug msg=Fetching asset="Agent Installer ISO" count=3`,
+			wantMsg:    "Fetching",
+			wantFields: []interface{}{"asset", "Agent Installer ISO", "count", "3"},
+			wantOk:     true,
+		},
+		{
+			name:    "escaped quote and backslash",
+			line:    `level=info msg="a \"quoted\" C:\\path"`,
+			wantMsg: `a "quoted" C:\path`,
+			wantOk:  true,
+		},
+		{
+			name: "missing msg",
+			line: "level=info",
+		},
+		{
+			name: "missing level",
+			line: `msg="hello"`,
+		},
+		{
+			name: "unterminated quote",
+			line: `level=info msg="hello`,
+		},
+		{
+			name: "not logfmt",
+			line: "Error: unknown command",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, msg, fields, ok := parseInstallerLine(tt.line)
+			if ok != tt.wantOk {
+				t.Fatalf("parseInstallerLine(%s) ok = %v, want %v", tt.line, ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+
+			if msg != tt.wantMsg || !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("parseInstallerLine(%s) = %q, %q, want %q, %q", tt.line, msg, fields, tt.wantMsg, tt.wantFields)
+			}
+		})
+	}
+}
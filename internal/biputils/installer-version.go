@@ -0,0 +1,296 @@
+package biputils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"snoman/internal/arch"
+	"snoman/internal/logger"
+	"snoman/internal/registry"
+	"snoman/internal/release"
+	"snoman/internal/runner"
+	"sort"
+	"strings"
+)
+
+// What to do when the installer is not of the major and minor version of the release
+const (
+	INSTALLER_VERSION_CHECK_FAIL    = "fail"
+	INSTALLER_VERSION_CHECK_WARN    = "warn"
+	INSTALLER_VERSION_CHECK_SKIP    = "skip"
+	DEFAULT_INSTALLER_VERSION_CHECK = INSTALLER_VERSION_CHECK_FAIL
+
+	// INSTALLER_BIN_NAME prefixes the installers of an installer directory, ex: openshift-install-4.15.1
+	INSTALLER_BIN_NAME = "openshift-install"
+)
+
+// InstallerVersion is what openshift-install version reports about an installer
+type InstallerVersion struct {
+	Path    string
+	Version string
+	// ReleaseImage is the release the installer was built for
+	ReleaseImage string
+	// Arch is the architecture of that release, ex: amd64
+	Arch string
+}
+
+// GetInstallerVersion will ask the installer at path for its version and embedded release image
+func GetInstallerVersion(ctx context.Context, path string) (*InstallerVersion, error) {
+	stdout := &bytes.Buffer{}
+	cmd := runner.NewCommand(path, "version")
+	cmd.Stdout = stdout
+	cmd.Quiet = true
+
+	if err := runner.Run(ctx, cmd); err != nil {
+		return nil, fmt.Errorf("unable to get the version of installer '%s': %w", path, err)
+	}
+
+	return parseInstallerVersion(path, stdout.String()), nil
+}
+
+// parseInstallerVersion will read the output of openshift-install version, ex:
+//
+//	openshift-install 4.15.1
+//	built from commit 0a6a3d5b4a0e1c2f
+//	release image quay.io/openshift-release-dev/ocp-release@sha256:...
+//	release architecture amd64
+func parseInstallerVersion(path string, output string) *InstallerVersion {
+	installer := &InstallerVersion{Path: path}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, INSTALLER_BIN_NAME+" "):
+			installer.Version = strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(line, INSTALLER_BIN_NAME)), "v")
+		case strings.HasPrefix(line, "release image "):
+			installer.ReleaseImage = strings.TrimSpace(strings.TrimPrefix(line, "release image "))
+		case strings.HasPrefix(line, "release architecture "):
+			installer.Arch = strings.TrimSpace(strings.TrimPrefix(line, "release architecture "))
+		}
+	}
+
+	return installer
+}
+
+// prepareInstaller will pick the installer of the release from the installer directory and check that the
+// installer is of the release major and minor version, as other installers fail in confusing ways
+func prepareInstaller(ctx context.Context, spec *BootstrapInPlaceIsoSpec) error {
+	log := logger.Get()
+
+	if spec.InstallerDir != "" {
+		installer, err := findInstaller(ctx, spec.InstallerDir, spec.OpenshiftVersion, spec.ReleaseImage)
+		if err != nil {
+			return err
+		}
+
+		spec.AbiPath = installer.Path
+		log.Infow("using installer of the release", "installer", installer.Path, "version", installer.Version, "release", spec.OpenshiftVersion)
+
+		return checkInstallerVersion(installer, spec)
+	}
+
+	if spec.InstallerVersionCheck == INSTALLER_VERSION_CHECK_SKIP {
+		return nil
+	}
+
+	installer, err := GetInstallerVersion(ctx, spec.AbiPath)
+	if err != nil {
+		if spec.InstallerVersionCheck == INSTALLER_VERSION_CHECK_WARN {
+			log.Warn(err)
+			return nil
+		}
+
+		return err
+	}
+
+	return checkInstallerVersion(installer, spec)
+}
+
+// checkInstallerVersion will fail or warn, as the spec says, when the installer major and minor version
+// differs from the release
+func checkInstallerVersion(installer *InstallerVersion, spec *BootstrapInPlaceIsoSpec) error {
+	log := logger.Get()
+
+	if spec.InstallerVersionCheck == INSTALLER_VERSION_CHECK_SKIP {
+		return nil
+	}
+
+	log.Debugw("checking installer", "installer", installer.Path, "version", installer.Version, "embedded_release_image", installer.ReleaseImage, "release_arch", installer.Arch)
+
+	// The version of a release requested by pull spec is only known to the release image. The installer built
+	// for that image is the right one, otherwise the version is taken from the image tag
+	releaseVersion := spec.OpenshiftVersion
+	if releaseVersion == "" {
+		if sameReleaseImage(installer.ReleaseImage, spec.ReleaseImage) {
+			log.Debugf("installer %s was built for release image %s", installer.Path, spec.ReleaseImage)
+			return nil
+		}
+
+		releaseVersion = releaseImageVersion(spec.ReleaseImage)
+		if releaseVersion == "" {
+			log.Warnf("unable to check installer %s %s, it was built for release image %s and the version of release image %s is unknown, set the release version to check it", installer.Path, installer.Version, installer.ReleaseImage, spec.ReleaseImage)
+			return nil
+		}
+	}
+
+	requested, err := release.ParseVersion(releaseVersion)
+	if err != nil {
+		return fmt.Errorf("unable to check the installer version: %w", err)
+	}
+
+	// Development builds do not have a release version
+	version, err := release.ParseVersion(installer.Version)
+	if err != nil {
+		log.Warnf("unable to check installer %s for the %s release, it reports version '%s'", installer.Path, releaseVersion, installer.Version)
+		return nil
+	}
+
+	if version.Major == requested.Major && version.Minor == requested.Minor {
+		if version.Compare(requested) != 0 {
+			log.Debugf("installer %s is version %s, the release is %s", installer.Path, installer.Version, releaseVersion)
+		}
+
+		return nil
+	}
+
+	err = fmt.Errorf("installer %s is version %s, which can not install the %s release, use the installer of the release, ex: from oc adm release extract --command=%s %s", installer.Path, installer.Version, releaseVersion, INSTALLER_BIN_NAME, spec.ReleaseImage)
+	if spec.InstallerVersionCheck == INSTALLER_VERSION_CHECK_WARN {
+		log.Warn(err)
+		return nil
+	}
+
+	return err
+}
+
+// sameReleaseImage will return true when both pull specs are the same image, images pinned by digest only
+// have to share the digest
+func sameReleaseImage(image string, other string) bool {
+	ref, err := registry.ParseReference(image)
+	if err != nil {
+		return false
+	}
+
+	otherRef, err := registry.ParseReference(other)
+	if err != nil {
+		return false
+	}
+
+	if ref.Digest != "" && otherRef.Digest != "" {
+		return ref.Digest == otherRef.Digest
+	}
+
+	return ref.String() == otherRef.String()
+}
+
+// releaseImageVersion will return the version the release image is tagged with, ex: 4.15.1 of
+// quay.io/openshift-release-dev/ocp-release:4.15.1-x86_64, or empty when its tag is not a version
+func releaseImageVersion(image string) string {
+	ref, err := registry.ParseReference(image)
+	if err != nil || ref.Tag == "" {
+		return ""
+	}
+
+	version, err := release.ParseVersion(ref.Tag)
+	if err != nil {
+		return ""
+	}
+
+	// The architecture suffix of a release tag reads as a prerelease
+	if _, err := arch.Normalize(version.Prerelease); err == nil {
+		version.Prerelease = ""
+	}
+
+	return version.String()
+}
+
+// findInstaller will pick the installer of the release from dir, which holds installers named
+// openshift-install-<version> or <version>/openshift-install. The installer of the exact version is
+// preferred over the newest one of the same major and minor version. Without a version the installer
+// built for the release image is used
+func findInstaller(ctx context.Context, dir string, version string, releaseImage string) (*InstallerVersion, error) {
+	paths, err := installerCandidates(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	installers := []*InstallerVersion{}
+	for _, path := range paths {
+		installer, err := GetInstallerVersion(ctx, path)
+		if err != nil {
+			logger.Get().Debugf("skipping installer candidate: %v", err)
+			continue
+		}
+		installers = append(installers, installer)
+	}
+
+	if len(installers) == 0 {
+		return nil, fmt.Errorf("no installers found in '%s', expected %s-<version> or <version>/%s", dir, INSTALLER_BIN_NAME, INSTALLER_BIN_NAME)
+	}
+
+	if version == "" {
+		for _, installer := range installers {
+			if installer.ReleaseImage == releaseImage {
+				return installer, nil
+			}
+		}
+
+		return nil, fmt.Errorf("no installer in '%s' was built for release image %s, set the release version to pick one", dir, releaseImage)
+	}
+
+	requested, err := release.ParseVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("unable to pick an installer: %w", err)
+	}
+
+	var best *InstallerVersion
+	var bestVersion *release.Version
+	found := []string{}
+	for _, installer := range installers {
+		found = append(found, installer.Version)
+
+		v, err := release.ParseVersion(installer.Version)
+		if err != nil || v.Major != requested.Major || v.Minor != requested.Minor {
+			continue
+		}
+
+		if v.Compare(requested) == 0 {
+			return installer, nil
+		}
+
+		if bestVersion == nil || v.Compare(bestVersion) > 0 {
+			best, bestVersion = installer, v
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no installer for the %s release in '%s', found versions: %s", version, dir, strings.Join(found, ", "))
+	}
+
+	return best, nil
+}
+
+// installerCandidates are the executables in dir named like an installer, and the installers one folder down
+func installerCandidates(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read installer directory '%s': %w", dir, err)
+	}
+
+	paths := []string{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			path = filepath.Join(path, INSTALLER_BIN_NAME)
+		} else if !strings.HasPrefix(entry.Name(), INSTALLER_BIN_NAME) {
+			continue
+		}
+
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
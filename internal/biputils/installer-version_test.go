@@ -0,0 +1,112 @@
+package biputils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckInstallerVersion(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("12", 32)
+	otherDigest := "sha256:" + strings.Repeat("34", 32)
+
+	tests := []struct {
+		name         string
+		installer    InstallerVersion
+		version      string
+		releaseImage string
+		check        string
+		wantErr      string
+	}{
+		{
+			name:         "same minor version",
+			installer:    InstallerVersion{Version: "4.15.3"},
+			version:      "4.15.1",
+			releaseImage: "quay.io/openshift-release-dev/ocp-release:4.15.1-x86_64",
+		},
+		{
+			name:         "other minor version",
+			installer:    InstallerVersion{Version: "4.14.10"},
+			version:      "4.15.1",
+			releaseImage: "quay.io/openshift-release-dev/ocp-release:4.15.1-x86_64",
+			wantErr:      "can not install the 4.15.1 release",
+		},
+		{
+			name:         "other minor version only warns",
+			installer:    InstallerVersion{Version: "4.14.10"},
+			version:      "4.15.1",
+			releaseImage: "quay.io/openshift-release-dev/ocp-release:4.15.1-x86_64",
+			check:        INSTALLER_VERSION_CHECK_WARN,
+		},
+		{
+			name:         "skipped",
+			installer:    InstallerVersion{Version: "4.14.10"},
+			version:      "4.15.1",
+			releaseImage: "quay.io/openshift-release-dev/ocp-release:4.15.1-x86_64",
+			check:        INSTALLER_VERSION_CHECK_SKIP,
+		},
+		{
+			name:         "pull spec the installer was built for",
+			installer:    InstallerVersion{Version: "4.14.10", ReleaseImage: "quay.io/openshift-release-dev/ocp-release@" + digest},
+			releaseImage: "quay.io/openshift-release-dev/ocp-release@" + digest,
+		},
+		{
+			name:         "pull spec mirrored with the same digest",
+			installer:    InstallerVersion{Version: "4.14.10", ReleaseImage: "quay.io/openshift-release-dev/ocp-release@" + digest},
+			releaseImage: "mirror.lab:5000/ocp/release@" + digest,
+		},
+		{
+			name:         "pull spec tagged with the installer minor version",
+			installer:    InstallerVersion{Version: "4.15.3", ReleaseImage: "quay.io/openshift-release-dev/ocp-release@" + digest},
+			releaseImage: "mirror.lab:5000/ocp/release:4.15.1-x86_64",
+		},
+		{
+			name:         "pull spec tagged with another minor version",
+			installer:    InstallerVersion{Version: "4.14.10", ReleaseImage: "quay.io/openshift-release-dev/ocp-release@" + digest},
+			releaseImage: "mirror.lab:5000/ocp/release:4.15.1-x86_64",
+			wantErr:      "can not install the 4.15.1 release",
+		},
+		{
+			name:         "nightly pull spec tagged with another minor version",
+			installer:    InstallerVersion{Version: "4.14.10"},
+			releaseImage: "registry.ci.openshift.org/ocp/release:4.15.0-0.nightly-2024-03-01-123456",
+			wantErr:      "can not install the 4.15.0-0.nightly-2024-03-01-123456 release",
+		},
+		{
+			name:         "pull spec of another digest only warns",
+			installer:    InstallerVersion{Version: "4.14.10", ReleaseImage: "quay.io/openshift-release-dev/ocp-release@" + digest},
+			releaseImage: "quay.io/openshift-release-dev/ocp-release@" + otherDigest,
+		},
+		{
+			name:         "pull spec tagged without a version only warns",
+			installer:    InstallerVersion{Version: "4.14.10"},
+			releaseImage: "mirror.lab:5000/ocp/release:latest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := tt.check
+			if check == "" {
+				check = INSTALLER_VERSION_CHECK_FAIL
+			}
+			spec := &BootstrapInPlaceIsoSpec{
+				OpenshiftVersion:      tt.version,
+				ReleaseImage:          tt.releaseImage,
+				InstallerVersionCheck: check,
+			}
+			installer := tt.installer
+			installer.Path = "/usr/local/bin/openshift-install"
+
+			err := checkInstallerVersion(&installer, spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("checkInstallerVersion() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkInstallerVersion() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package biputils
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"snoman/internal/biputils/isocache"
+	"snoman/internal/logger"
+	"sort"
+)
+
+// GetIsoPath returns where openshift-install writes the ISO of the architecture
+func GetIsoPath(workdir string, openshiftArch string) string {
+	return filepath.Join(workdir, fmt.Sprintf("agent.%s.iso", installerArch(openshiftArch)))
+}
+
+// isoCacheFiles are generated with the ISO and only work with it, they are cached when openshift-install creates them
+var isoCacheFiles = []string{
+	filepath.Join("auth", "kubeconfig"),
+	filepath.Join("auth", "kubeadmin-password"),
+}
+
+// getCachedIso will link a cached ISO with the same inputs, and the files generated with it, into the workdir.
+// It returns true on a hit
+func getCachedIso(spec *BootstrapInPlaceIsoSpec, key string, workdir string) bool {
+	log := logger.Get()
+
+	entry, err := isocache.New(spec.CacheDir, spec.CacheSizeGB).Get(key)
+	if errors.Is(err, isocache.ErrNotFound) {
+		log.Infow("iso cache miss", "key", key)
+		return false
+	} else if err != nil {
+		log.Warnf("unable to read the iso cache: %v", err)
+		return false
+	}
+
+	if err := entry.Restore(workdir); err != nil {
+		log.Warnf("unable to use the cached iso: %v", err)
+		return false
+	}
+
+	log.Infow("iso cache hit", "key", key, "iso", entry.Path())
+
+	return true
+}
+
+// putCachedIso will add the generated ISO to the cache, a failure only costs the next run the generation time
+func putCachedIso(spec *BootstrapInPlaceIsoSpec, key string, workdir string, isoPath string) {
+	files := []string{filepath.Base(isoPath)}
+	for _, file := range isoCacheFiles {
+		if _, err := os.Stat(filepath.Join(workdir, file)); err == nil {
+			files = append(files, file)
+		}
+	}
+
+	if _, err := isocache.New(spec.CacheDir, spec.CacheSizeGB).Put(key, workdir, files, spec.ReleaseImage); err != nil {
+		logger.Get().Warnf("unable to cache the iso: %v", err)
+	}
+}
+
+// isoCacheKey will hash everything openshift-install uses to build the ISO: the configs, the rendered custom
+// manifests, the release image and the installer binary itself
+func isoCacheKey(spec *BootstrapInPlaceIsoSpec) (string, error) {
+	agentConfig, err := os.ReadFile(spec.AgentConfigPath)
+	if err != nil {
+		return "", fmt.Errorf("could not read agent config '%s': %w", spec.AgentConfigPath, err)
+	}
+
+	installConfig, err := os.ReadFile(spec.InstallConfigPath)
+	if err != nil {
+		return "", fmt.Errorf("could not read install config '%s': %w", spec.InstallConfigPath, err)
+	}
+
+	installerDigest, err := fileDigest(spec.AbiPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to hash the installer '%s': %w", spec.AbiPath, err)
+	}
+
+	parts := [][]byte{
+		[]byte(spec.OpenshiftArch),
+		[]byte(spec.ReleaseImage),
+		installerDigest,
+		installConfig,
+		agentConfig,
+	}
+
+	if spec.CustomManifestDir != "" {
+		_, rendered, err := LoadManifests(spec)
+		if err != nil {
+			return "", err
+		}
+
+		names := make([]string, 0, len(rendered))
+		for name := range rendered {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			parts = append(parts, []byte(name), rendered[name])
+		}
+	}
+
+	return isocache.Key(parts...), nil
+}
+
+func fileDigest(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
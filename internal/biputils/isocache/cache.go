@@ -0,0 +1,327 @@
+package isocache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"snoman/internal/logger"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// CACHE_DIR_ENV overrides the default cache directory
+	CACHE_DIR_ENV              = "SNOMAN_CACHE_DIR"
+	DEFAULT_CACHE_SIZE_GB uint = 20
+	ENTRY_METADATA_FILE        = "entry.yaml"
+)
+
+var ErrNotFound = fmt.Errorf("the iso is not in the cache")
+
+// Entry is one cached ISO and the files generated with it, stored in a folder named after its key
+type Entry struct {
+	Key string `yaml:"key"`
+	// Files are relative to the folder they were generated in, the ISO is the first one
+	Files        []string  `yaml:"files"`
+	ReleaseImage string    `yaml:"release_image,omitempty"`
+	Size         uint64    `yaml:"size"`
+	Created      time.Time `yaml:"created"`
+	LastUsed     time.Time `yaml:"last_used"`
+	dir          string
+}
+
+// Path is where the cached ISO is
+func (e *Entry) Path() string {
+	if len(e.Files) == 0 {
+		return ""
+	}
+
+	return filepath.Join(e.dir, e.Files[0])
+}
+
+// Restore will link the cached files into dir, at the same relative paths they were generated at
+func (e *Entry) Restore(dir string) error {
+	for _, file := range e.Files {
+		dst := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("unable to create the folder of '%s': %w", dst, err)
+		}
+
+		if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("unable to replace '%s': %w", dst, err)
+		}
+
+		if err := LinkOrCopy(filepath.Join(e.dir, file), dst); err != nil {
+			return fmt.Errorf("unable to restore '%s': %w", file, err)
+		}
+	}
+
+	return nil
+}
+
+// Cache stores generated ISOs by the hash of everything that went into them. Once the cache grows past
+// MaxBytes the least recently used ISOs are removed
+type Cache struct {
+	Dir      string
+	MaxBytes uint64
+}
+
+func New(dir string, sizeGB uint) *Cache {
+	return &Cache{
+		Dir:      dir,
+		MaxBytes: uint64(sizeGB) * 1024 * 1024 * 1024,
+	}
+}
+
+// DefaultDir will return $SNOMAN_CACHE_DIR or the isos folder in the user cache directory
+func DefaultDir() string {
+	if dir := os.Getenv(CACHE_DIR_ENV); dir != "" {
+		return dir
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "snoman", "isos")
+}
+
+// Key will hash the inputs of an ISO. Each part is length prefixed so moving bytes between parts changes the key
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		binary.Write(h, binary.BigEndian, uint64(len(part)))
+		h.Write(part)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get will return the entry of the key and mark it as used. ErrNotFound is returned on a miss
+func (c *Cache) Get(key string) (*Entry, error) {
+	entry, err := c.readEntry(filepath.Join(c.Dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	// An entry missing files is left over from an interrupted put or prune
+	for _, file := range entry.Files {
+		if _, err := os.Stat(filepath.Join(entry.dir, file)); err != nil {
+			return nil, ErrNotFound
+		}
+	}
+
+	entry.LastUsed = time.Now()
+	if err := c.writeEntry(entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Put will add the files, the ISO first, from dir to the cache under the key and then evict entries until the
+// cache fits in MaxBytes. Files are hard linked when they are on the same filesystem and copied otherwise
+func (c *Cache) Put(key string, dir string, files []string, releaseImage string) (*Entry, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("an iso is required to add a cache entry")
+	}
+
+	// Build the entry next to its final folder so a partial entry is never visible
+	tmpDir := filepath.Join(c.Dir, key+".tmp")
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return nil, fmt.Errorf("unable to clear the cache entry: %w", err)
+	}
+
+	// Entries hold the cluster credentials generated with the ISO
+	if err := os.MkdirAll(tmpDir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create the cache entry: %w", err)
+	}
+
+	now := time.Now()
+	entry := &Entry{
+		Key:          key,
+		ReleaseImage: releaseImage,
+		Created:      now,
+		LastUsed:     now,
+		dir:          tmpDir,
+	}
+
+	for _, file := range files {
+		src := filepath.Join(dir, file)
+		info, err := os.Stat(src)
+		if err != nil {
+			os.RemoveAll(tmpDir)
+			return nil, fmt.Errorf("unable to find '%s' to cache: %w", src, err)
+		}
+
+		dst := filepath.Join(tmpDir, file)
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			os.RemoveAll(tmpDir)
+			return nil, fmt.Errorf("unable to create the cache entry: %w", err)
+		}
+
+		if err := LinkOrCopy(src, dst); err != nil {
+			os.RemoveAll(tmpDir)
+			return nil, fmt.Errorf("unable to add '%s' to the cache: %w", file, err)
+		}
+
+		entry.Files = append(entry.Files, file)
+		entry.Size += uint64(info.Size())
+	}
+
+	if err := c.writeEntry(entry); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+
+	entry.dir = filepath.Join(c.Dir, key)
+	if err := os.RemoveAll(entry.dir); err != nil {
+		return nil, fmt.Errorf("unable to replace the cache entry: %w", err)
+	}
+
+	if err := os.Rename(tmpDir, entry.dir); err != nil {
+		return nil, fmt.Errorf("unable to add the cache entry: %w", err)
+	}
+
+	if _, err := c.evict(c.MaxBytes, key); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// List will return the cached ISOs, most recently used first
+func (c *Cache) List() ([]*Entry, error) {
+	dirs, err := os.ReadDir(c.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read the cache directory: %w", err)
+	}
+
+	entries := []*Entry{}
+	for _, dir := range dirs {
+		if !dir.IsDir() || filepath.Ext(dir.Name()) == ".tmp" {
+			continue
+		}
+
+		entry, err := c.readEntry(filepath.Join(c.Dir, dir.Name()))
+		if err != nil {
+			logger.Get().Warnf("skipping cache entry '%s': %v", dir.Name(), err)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+
+	return entries, nil
+}
+
+// Prune will remove the least recently used ISOs until the cache fits in maxBytes, 0 empties the cache
+func (c *Cache) Prune(maxBytes uint64) ([]*Entry, error) {
+	return c.evict(maxBytes, "")
+}
+
+// evict removes the least recently used entries, other than keep, until the cache fits in maxBytes
+func (c *Cache) evict(maxBytes uint64, keep string) ([]*Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var total uint64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+
+	removed := []*Entry{}
+	for i := len(entries) - 1; i >= 0 && total > maxBytes; i-- {
+		entry := entries[i]
+		if entry.Key == keep {
+			continue
+		}
+
+		if err := os.RemoveAll(entry.dir); err != nil {
+			return removed, fmt.Errorf("unable to remove cache entry '%s': %w", entry.Key, err)
+		}
+
+		logger.Get().Infof("evicted %s from the iso cache", entry.Key)
+		total -= entry.Size
+		removed = append(removed, entry)
+	}
+
+	return removed, nil
+}
+
+func (c *Cache) readEntry(dir string) (*Entry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ENTRY_METADATA_FILE))
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{}
+	if err := yaml.Unmarshal(data, entry); err != nil {
+		return nil, fmt.Errorf("unable to parse the cache entry: %w", err)
+	}
+	entry.dir = dir
+
+	return entry, nil
+}
+
+func (c *Cache) writeEntry(entry *Entry) error {
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the cache entry: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(entry.dir, ENTRY_METADATA_FILE), data, 0644); err != nil {
+		return fmt.Errorf("unable to write the cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// LinkOrCopy will hard link src to dst, falling back to a copy with the same permissions when they are on
+// different filesystems
+func LinkOrCopy(src string, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
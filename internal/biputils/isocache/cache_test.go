@@ -0,0 +1,243 @@
+package isocache
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        [][]byte
+		b        [][]byte
+		wantSame bool
+	}{
+		{
+			name:     "same parts",
+			a:        [][]byte{[]byte("x86_64"), []byte("quay.io/release:4.15.1")},
+			b:        [][]byte{[]byte("x86_64"), []byte("quay.io/release:4.15.1")},
+			wantSame: true,
+		},
+		{
+			name: "bytes moved between parts",
+			a:    [][]byte{[]byte("ab"), []byte("c")},
+			b:    [][]byte{[]byte("a"), []byte("bc")},
+		},
+		{
+			name: "empty part",
+			a:    [][]byte{{}, []byte("a")},
+			b:    [][]byte{[]byte("a")},
+		},
+		{
+			name: "no parts and an empty part",
+			a:    [][]byte{},
+			b:    [][]byte{{}},
+		},
+		{
+			name: "part order",
+			a:    [][]byte{[]byte("a"), []byte("b")},
+			b:    [][]byte{[]byte("b"), []byte("a")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := Key(tt.a...), Key(tt.b...)
+			if len(a) != 64 {
+				t.Errorf("Key() = %q, want a hex sha256", a)
+			}
+
+			if same := a == b; same != tt.wantSame {
+				t.Errorf("Key(%q) = %s, Key(%q) = %s, want same = %v", tt.a, a, tt.b, b, tt.wantSame)
+			}
+		})
+	}
+}
+
+// writeIsoFiles will create an ISO of size bytes and its kubeconfig in a new folder
+func writeIsoFiles(t *testing.T, size int) (string, []string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"agent.x86_64.iso":                  bytes.Repeat([]byte("i"), size),
+		filepath.Join("auth", "kubeconfig"): []byte("kubeconfig"),
+	}
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir, []string{"agent.x86_64.iso", filepath.Join("auth", "kubeconfig")}
+}
+
+func TestPutGet(t *testing.T) {
+	cache := &Cache{Dir: filepath.Join(t.TempDir(), "isos"), MaxBytes: 1024 * 1024}
+	src, files := writeIsoFiles(t, 100)
+
+	put, err := cache.Put("key", src, files, "quay.io/release@sha256:abc")
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	got, err := cache.Get("key")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if !slices.Equal(got.Files, files) || got.ReleaseImage != "quay.io/release@sha256:abc" || got.Size != 100+uint64(len("kubeconfig")) {
+		t.Errorf("Get() = %+v, want the put entry", got)
+	}
+
+	if got.Path() != filepath.Join(cache.Dir, "key", "agent.x86_64.iso") {
+		t.Errorf("Path() = %s, want the iso in the entry folder", got.Path())
+	}
+
+	if got.LastUsed.Before(put.LastUsed) {
+		t.Errorf("LastUsed = %v, want it updated from %v", got.LastUsed, put.LastUsed)
+	}
+
+	// The files are restored at the paths they were generated at
+	dst := t.TempDir()
+	if err := got.Restore(dst); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	for _, file := range files {
+		want, _ := os.ReadFile(filepath.Join(src, file))
+		if data, err := os.ReadFile(filepath.Join(dst, file)); err != nil || !bytes.Equal(data, want) {
+			t.Errorf("restored %s = %d bytes, %v, want %d bytes", file, len(data), err, len(want))
+		}
+	}
+
+	// No partial entry is left next to the real one
+	if _, err := os.Stat(filepath.Join(cache.Dir, "key.tmp")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the temporary entry folder was left behind: %v", err)
+	}
+}
+
+func TestGetMisses(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		damage func(t *testing.T, cache *Cache)
+	}{
+		{
+			name: "unknown key",
+			key:  "other",
+		},
+		{
+			name: "entry missing a file",
+			key:  "key",
+			damage: func(t *testing.T, cache *Cache) {
+				if err := os.Remove(filepath.Join(cache.Dir, "key", "auth", "kubeconfig")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "entry missing its iso",
+			key:  "key",
+			damage: func(t *testing.T, cache *Cache) {
+				if err := os.Remove(filepath.Join(cache.Dir, "key", "agent.x86_64.iso")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &Cache{Dir: t.TempDir(), MaxBytes: 1024 * 1024}
+			src, files := writeIsoFiles(t, 100)
+			if _, err := cache.Put("key", src, files, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.damage != nil {
+				tt.damage(t, cache)
+			}
+
+			if _, err := cache.Get(tt.key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() error = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+}
+
+func TestPutEvicts(t *testing.T) {
+	entrySize := uint64(100 + len("kubeconfig"))
+
+	tests := []struct {
+		name     string
+		maxBytes uint64
+		// used are the keys read back between the puts, which makes them recently used
+		used     []string
+		wantKeys []string
+	}{
+		{
+			name:     "everything fits",
+			maxBytes: 3 * entrySize,
+			wantKeys: []string{"c", "b", "a"},
+		},
+		{
+			name:     "oldest evicted",
+			maxBytes: 2 * entrySize,
+			wantKeys: []string{"c", "b"},
+		},
+		{
+			name:     "least recently used evicted",
+			maxBytes: 2 * entrySize,
+			used:     []string{"a"},
+			wantKeys: []string{"c", "a"},
+		},
+		{
+			name:     "newest kept even if it is too large",
+			maxBytes: entrySize / 2,
+			wantKeys: []string{"c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &Cache{Dir: t.TempDir(), MaxBytes: tt.maxBytes}
+
+			for _, key := range []string{"a", "b", "c"} {
+				if key == "c" {
+					for _, used := range tt.used {
+						if _, err := cache.Get(used); err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+
+				src, files := writeIsoFiles(t, 100)
+				if _, err := cache.Put(key, src, files, ""); err != nil {
+					t.Fatalf("Put(%s) error = %v", key, err)
+				}
+			}
+
+			entries, err := cache.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			keys := []string{}
+			for _, entry := range entries {
+				keys = append(keys, entry.Key)
+			}
+			if !slices.Equal(keys, tt.wantKeys) {
+				t.Errorf("cached keys = %v, want %v", keys, tt.wantKeys)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package biputils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"snoman/internal/logger"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// openshift-install adds every manifest in this folder of its workdir to the cluster
+	MANIFESTS_SUBFOLDER = "openshift"
+	// Manifests ending in this are rendered with text/template before they are added
+	MANIFEST_TEMPLATE_EXT = ".tmpl"
+)
+
+// Manifest is a custom manifest added to the installer and the objects it holds
+type Manifest struct {
+	Name    string
+	Source  string
+	Objects []string
+}
+
+// manifestTemplateData is what manifest templates can use, ex: {{ .Values.registry }}
+type manifestTemplateData struct {
+	Values           map[string]string
+	OpenshiftVersion string
+	OpenshiftArch    string
+	ReleaseImage     string
+}
+
+// manifestObject is the part of a kubernetes object every manifest needs
+type manifestObject struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+}
+
+// LoadManifests will render and validate the YAML manifests in the custom manifests folder
+func LoadManifests(spec *BootstrapInPlaceIsoSpec) ([]Manifest, map[string][]byte, error) {
+	log := logger.Get()
+
+	entries, err := os.ReadDir(spec.CustomManifestDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read the custom manifests folder: %w", err)
+	}
+
+	data := manifestTemplateData{
+		Values:           spec.CustomManifestValues,
+		OpenshiftVersion: spec.OpenshiftVersion,
+		OpenshiftArch:    spec.OpenshiftArch,
+		ReleaseImage:     spec.ReleaseImage,
+	}
+
+	manifests := []Manifest{}
+	contents := map[string][]byte{}
+	errs := []error{}
+
+	for _, entry := range entries {
+		source := filepath.Join(spec.CustomManifestDir, entry.Name())
+		name := strings.TrimSuffix(entry.Name(), MANIFEST_TEMPLATE_EXT)
+
+		if entry.IsDir() || !(strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")) {
+			log.Warnf("skipping '%s', only .yaml and .yml manifests are added", source)
+			continue
+		}
+
+		raw, err := os.ReadFile(source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to read manifest '%s': %w", source, err))
+			continue
+		}
+
+		if name != entry.Name() {
+			raw, err = renderManifest(entry.Name(), raw, data)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("unable to render manifest '%s': %w", source, err))
+				continue
+			}
+		}
+
+		objects, err := parseManifest(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid manifest '%s': %w", source, err))
+			continue
+		}
+
+		if _, ok := contents[name]; ok {
+			errs = append(errs, fmt.Errorf("manifest '%s' is provided more than once", name))
+			continue
+		}
+
+		manifests = append(manifests, Manifest{Name: name, Source: source, Objects: objects})
+		contents[name] = raw
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+
+	return manifests, contents, nil
+}
+
+func renderManifest(name string, raw []byte, data manifestTemplateData) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := new(bytes.Buffer)
+	if err := tmpl.Execute(rendered, data); err != nil {
+		return nil, err
+	}
+
+	return rendered.Bytes(), nil
+}
+
+// parseManifest will check every YAML document is a kubernetes object and describe each as kind/namespace/name
+func parseManifest(raw []byte) ([]string, error) {
+	objects := []string{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	for i := 1; ; i++ {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("document %d is not valid YAML: %w", i, err)
+		}
+
+		// Empty documents, ex: a trailing ---, are ignored
+		if doc == nil {
+			continue
+		}
+
+		docData, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d could not be read: %w", i, err)
+		}
+
+		obj := manifestObject{}
+		if err := yaml.Unmarshal(docData, &obj); err != nil {
+			return nil, fmt.Errorf("document %d is not a kubernetes object: %w", i, err)
+		}
+
+		switch {
+		case obj.APIVersion == "":
+			return nil, fmt.Errorf("document %d has no apiVersion", i)
+		case obj.Kind == "":
+			return nil, fmt.Errorf("document %d has no kind", i)
+		case obj.Metadata.Name == "":
+			return nil, fmt.Errorf("document %d has no metadata.name", i)
+		}
+
+		desc := fmt.Sprintf("%s/%s", obj.Kind, obj.Metadata.Name)
+		if obj.Metadata.Namespace != "" {
+			desc = fmt.Sprintf("%s/%s/%s", obj.Kind, obj.Metadata.Namespace, obj.Metadata.Name)
+		}
+		objects = append(objects, desc)
+	}
+
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("no kubernetes objects found")
+	}
+
+	return objects, nil
+}
+
+// writeManifests will add the custom manifests to the openshift folder of the installer workdir
+func writeManifests(spec *BootstrapInPlaceIsoSpec, workdir string) ([]Manifest, error) {
+	if spec.CustomManifestDir == "" {
+		return nil, nil
+	}
+
+	manifests, contents, err := LoadManifests(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestsDir := filepath.Join(workdir, MANIFESTS_SUBFOLDER)
+	if err := os.MkdirAll(manifestsDir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create the installer manifests folder: %w", err)
+	}
+
+	for _, manifest := range manifests {
+		path := filepath.Join(manifestsDir, manifest.Name)
+		if err := os.WriteFile(path, contents[manifest.Name], 0644); err != nil {
+			return nil, fmt.Errorf("could not write %s: %w", path, err)
+		}
+	}
+
+	return manifests, nil
+}
@@ -1,8 +1,11 @@
 package secrets
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 )
 
 func GetPullSecret(fname string) (string, error) {
@@ -32,3 +35,68 @@ func GetPullSecret(fname string) (string, error) {
 
 	return pullSecret, nil
 }
+
+// PullSecret is a docker auth config, the credentials of each registry host are base64 user:password
+type PullSecret struct {
+	Auths map[string]RegistryAuth `json:"auths"`
+}
+
+type RegistryAuth struct {
+	Auth  string `json:"auth"`
+	Email string `json:"email,omitempty"`
+}
+
+func ParsePullSecret(pullSecret string) (*PullSecret, error) {
+	ps := &PullSecret{}
+	if err := json.Unmarshal([]byte(pullSecret), ps); err != nil {
+		return nil, fmt.Errorf("unable to parse pull secret: %w", err)
+	}
+
+	if ps.Auths == nil {
+		ps.Auths = map[string]RegistryAuth{}
+	}
+
+	return ps, nil
+}
+
+// Credentials will return the user and password for the registry host, ex: quay.io or mirror.lab:5000
+func (ps *PullSecret) Credentials(host string) (string, string, bool) {
+	auth, ok := ps.Auths[host]
+	if !ok {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+	if err != nil {
+		return "", "", false
+	}
+
+	user, password, found := strings.Cut(string(decoded), ":")
+	return user, password, found
+}
+
+// MergePullSecrets will add the registry credentials of the others to base, later secrets win for the same host
+func MergePullSecrets(base string, others ...string) (string, error) {
+	merged, err := ParsePullSecret(base)
+	if err != nil {
+		return "", err
+	}
+
+	for _, other := range others {
+		ps, err := ParsePullSecret(other)
+		if err != nil {
+			return "", err
+		}
+
+		for host, auth := range ps.Auths {
+			merged.Auths[host] = auth
+		}
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal pull secret: %w", err)
+	}
+
+	return string(data), nil
+}
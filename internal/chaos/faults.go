@@ -0,0 +1,583 @@
+package chaos
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"slices"
+	"snoman/internal/logger"
+	"snoman/internal/vms/hypervisor"
+	"strings"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	FAULTS_METADATA_URI    = "https://github.com/jeff-roche/ib-orchestrator/xmlns/faults/1.0"
+	FAULTS_METADATA_PREFIX = "snomanfaults"
+
+	// Isolated interfaces are moved onto this copy of their network, which has no address, DHCP or forwarding
+	isolatedNetworkSuffix = "-isolated"
+)
+
+// faultRecord is stored in the domain metadata so faults can be undone by a later command
+type faultRecord struct {
+	XMLName    xml.Name            `xml:"faults"`
+	Disks      []detachedDisk      `xml:"disk"`
+	Interfaces []isolatedInterface `xml:"interface"`
+	Forwards   []removedForward    `xml:"network"`
+}
+
+type detachedDisk struct {
+	Target string `xml:"target,attr"`
+	XML    string `xml:",chardata"`
+}
+
+type isolatedInterface struct {
+	MAC     string `xml:"mac,attr"`
+	Network string `xml:"network,attr"`
+}
+
+type removedForward struct {
+	Network string                     `xml:"name,attr"`
+	Forward *libvirtxml.NetworkForward `xml:"forward"`
+}
+
+// Inject will apply the fault to its VM through the hypervisor
+func Inject(ctx context.Context, fault FaultSpec) error {
+	hv := hypervisor.Get()
+
+	var err error
+	switch fault.Action {
+	case ACTION_LINK_DOWN:
+		err = setLinkState(ctx, fault.VM, fault.Interface, "down")
+	case ACTION_LINK_UP:
+		err = setLinkState(ctx, fault.VM, fault.Interface, "up")
+	case ACTION_PAUSE:
+		err = hv.SuspendDomain(ctx, fault.VM)
+	case ACTION_RESUME:
+		err = hv.ResumeDomain(ctx, fault.VM)
+	case ACTION_RESET:
+		err = hv.ResetDomain(ctx, fault.VM)
+	case ACTION_DETACH_DISK:
+		err = detachDisk(ctx, fault.VM, fault.Disk)
+	case ACTION_ATTACH_DISK:
+		err = attachDisk(ctx, fault.VM, fault.Disk)
+	case ACTION_ISOLATE_NIC:
+		err = isolateInterfaces(ctx, fault.VM, fault.Interface)
+	case ACTION_RESTORE_NIC:
+		err = restoreInterfaces(ctx, fault.VM, fault.Interface)
+	case ACTION_CUT_FORWARD:
+		err = cutForward(ctx, fault.VM, fault.Interface)
+	case ACTION_RESTORE_FORWARD:
+		err = restoreForward(ctx, fault.VM, fault.Interface)
+	default:
+		err = fmt.Errorf("unknown action '%s'", fault.Action)
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to inject %s: %w", fault, err)
+	}
+
+	logger.Get().Infow("injected fault", "action", fault.Action, "vm", fault.VM, "interface", fault.Interface, "disk", fault.Disk)
+
+	return nil
+}
+
+// Undo will inject the fault that reverses this one
+func Undo(ctx context.Context, fault FaultSpec) error {
+	inverse, ok := inverses[fault.Action]
+	if !ok {
+		return fmt.Errorf("%s can not be undone", fault.Action)
+	}
+
+	fault.Action = inverse
+
+	return Inject(ctx, fault)
+}
+
+// matchInterface will select interfaces by MAC address or guest interface name, an empty selector matches every interface
+func matchInterface(iface libvirtxml.DomainInterface, selector string) bool {
+	if selector == "" {
+		return true
+	}
+
+	if iface.MAC != nil && strings.EqualFold(iface.MAC.Address, selector) {
+		return true
+	}
+
+	return iface.Alias != nil && (iface.Alias.Name == selector || iface.Alias.Name == "ua-"+selector)
+}
+
+// updateInterfaces will apply fn to every matching interface of the domain
+func updateInterfaces(ctx context.Context, vm string, selector string, fn func(*libvirtxml.DomainInterface) (bool, error)) error {
+	hv := hypervisor.Get()
+
+	domcfg, err := hv.LookupDomain(ctx, vm)
+	if err != nil {
+		return err
+	}
+
+	if domcfg.Devices == nil {
+		return fmt.Errorf("domain '%s' has no interfaces", vm)
+	}
+
+	updated := 0
+	for _, iface := range domcfg.Devices.Interfaces {
+		if iface.MAC == nil || !matchInterface(iface, selector) {
+			continue
+		}
+
+		changed, err := fn(&iface)
+		if err != nil {
+			return err
+		}
+
+		if !changed {
+			continue
+		}
+
+		if err := hv.UpdateDomainInterface(ctx, vm, &iface, true); err != nil {
+			return err
+		}
+		updated++
+	}
+
+	if updated == 0 {
+		if selector != "" {
+			return fmt.Errorf("no interface '%s' to update on domain '%s'", selector, vm)
+		}
+
+		return fmt.Errorf("no interfaces to update on domain '%s'", vm)
+	}
+
+	return nil
+}
+
+func setLinkState(ctx context.Context, vm string, selector string, state string) error {
+	return updateInterfaces(ctx, vm, selector, func(iface *libvirtxml.DomainInterface) (bool, error) {
+		iface.Link = &libvirtxml.DomainInterfaceLink{State: state}
+		return true, nil
+	})
+}
+
+func getFaultRecord(ctx context.Context, vm string) (*faultRecord, error) {
+	record := &faultRecord{}
+
+	data, err := hypervisor.Get().GetDomainMetadata(ctx, vm, FAULTS_METADATA_URI)
+	if errors.Is(err, hypervisor.ErrNotFound) {
+		return record, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := xml.Unmarshal([]byte(data), record); err != nil {
+		return nil, fmt.Errorf("unable to parse the fault record of '%s': %w", vm, err)
+	}
+
+	return record, nil
+}
+
+func setFaultRecord(ctx context.Context, vm string, record *faultRecord) error {
+	data, err := xml.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to generate fault record: %w", err)
+	}
+
+	return hypervisor.Get().SetDomainMetadata(ctx, vm, FAULTS_METADATA_PREFIX, FAULTS_METADATA_URI, string(data))
+}
+
+func detachDisk(ctx context.Context, vm string, target string) error {
+	hv := hypervisor.Get()
+
+	domcfg, err := hv.LookupDomain(ctx, vm)
+	if err != nil {
+		return err
+	}
+
+	var disk *libvirtxml.DomainDisk
+	if domcfg.Devices != nil {
+		for i := range domcfg.Devices.Disks {
+			if domcfg.Devices.Disks[i].Target != nil && domcfg.Devices.Disks[i].Target.Dev == target {
+				disk = &domcfg.Devices.Disks[i]
+				break
+			}
+		}
+	}
+
+	if disk == nil {
+		return fmt.Errorf("%w: disk '%s' of domain '%s'", hypervisor.ErrNotFound, target, vm)
+	}
+
+	diskxml, err := disk.Marshal()
+	if err != nil {
+		return fmt.Errorf("unable to generate disk xml: %w", err)
+	}
+
+	// Keep the disk config before detaching it, otherwise it can not be attached again
+	record, err := getFaultRecord(ctx, vm)
+	if err != nil {
+		return err
+	}
+
+	record.Disks = append(record.Disks, detachedDisk{Target: target, XML: diskxml})
+	if err := setFaultRecord(ctx, vm, record); err != nil {
+		return err
+	}
+
+	return hv.DetachDomainDisk(ctx, vm, disk, true)
+}
+
+func attachDisk(ctx context.Context, vm string, target string) error {
+	record, err := getFaultRecord(ctx, vm)
+	if err != nil {
+		return err
+	}
+
+	for i, detached := range record.Disks {
+		if detached.Target != target {
+			continue
+		}
+
+		disk := &libvirtxml.DomainDisk{}
+		if err := disk.Unmarshal(detached.XML); err != nil {
+			return fmt.Errorf("unable to parse the recorded disk '%s': %w", target, err)
+		}
+
+		if err := hypervisor.Get().AttachDomainDisk(ctx, vm, disk, true); err != nil {
+			return err
+		}
+
+		record.Disks = append(record.Disks[:i], record.Disks[i+1:]...)
+
+		return setFaultRecord(ctx, vm, record)
+	}
+
+	return fmt.Errorf("disk '%s' was not detached from '%s' by snoman", target, vm)
+}
+
+// isolateInterfaces will move the interfaces onto an isolated copy of their network. The guest keeps its link
+// and address but nothing answers, not even the host. Only this VM is cut off, cut-forward takes the uplink of
+// the whole network instead
+func isolateInterfaces(ctx context.Context, vm string, selector string) error {
+	record, err := getFaultRecord(ctx, vm)
+	if err != nil {
+		return err
+	}
+
+	err = updateInterfaces(ctx, vm, selector, func(iface *libvirtxml.DomainInterface) (bool, error) {
+		if iface.Source == nil || iface.Source.Network == nil || strings.HasSuffix(iface.Source.Network.Network, isolatedNetworkSuffix) {
+			return false, nil
+		}
+
+		isolated, err := createIsolatedNetwork(ctx, iface.Source.Network.Network)
+		if err != nil {
+			return false, err
+		}
+
+		record.Interfaces = append(record.Interfaces, isolatedInterface{MAC: iface.MAC.Address, Network: iface.Source.Network.Network})
+		if err := setFaultRecord(ctx, vm, record); err != nil {
+			return false, err
+		}
+
+		iface.Source.Network.Network = isolated
+		iface.Source.Network.Bridge = ""
+
+		return true, nil
+	})
+
+	return err
+}
+
+// restoreInterfaces will move isolated interfaces back onto the network they were isolated from
+func restoreInterfaces(ctx context.Context, vm string, selector string) error {
+	record, err := getFaultRecord(ctx, vm)
+	if err != nil {
+		return err
+	}
+
+	isolated := map[string]bool{}
+	err = updateInterfaces(ctx, vm, selector, func(iface *libvirtxml.DomainInterface) (bool, error) {
+		for i, isolatedIface := range record.Interfaces {
+			if !strings.EqualFold(isolatedIface.MAC, iface.MAC.Address) {
+				continue
+			}
+
+			if iface.Source != nil && iface.Source.Network != nil {
+				isolated[iface.Source.Network.Network] = true
+			}
+
+			iface.Source = &libvirtxml.DomainInterfaceSource{
+				Network: &libvirtxml.DomainInterfaceSourceNetwork{Network: isolatedIface.Network},
+			}
+
+			record.Interfaces = append(record.Interfaces[:i], record.Interfaces[i+1:]...)
+			if err := setFaultRecord(ctx, vm, record); err != nil {
+				return false, err
+			}
+
+			return true, nil
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	removeIsolatedNetworks(ctx, isolated)
+
+	return nil
+}
+
+// createIsolatedNetwork will return the isolated copy of network, creating it when it does not exist yet
+func createIsolatedNetwork(ctx context.Context, network string) (string, error) {
+	hv := hypervisor.Get()
+
+	isolated := network + isolatedNetworkSuffix
+	if _, err := hv.LookupNetwork(ctx, isolated); errors.Is(err, hypervisor.ErrNotFound) {
+		// Without a bridge name libvirt picks a free one
+		if err := hv.CreateNetwork(ctx, &libvirtxml.Network{Name: isolated, Bridge: &libvirtxml.NetworkBridge{STP: "on", Delay: "0"}}); err != nil {
+			return "", fmt.Errorf("unable to create isolated network '%s': %w", isolated, err)
+		}
+	} else if err != nil {
+		return "", err
+	}
+
+	return isolated, nil
+}
+
+// removeIsolatedNetworks will remove the isolated networks, they are only kept for as long as an interface,
+// of any VM, is isolated on them
+func removeIsolatedNetworks(ctx context.Context, isolated map[string]bool) {
+	inUse, err := networksInUse(ctx)
+	if err != nil {
+		logger.Get().Warnf("unable to check which isolated networks are still in use, keeping them: %v", err)
+		return
+	}
+
+	for name := range isolated {
+		if inUse[name] {
+			logger.Get().Debugf("keeping isolated network '%s', other interfaces are still isolated on it", name)
+			continue
+		}
+
+		if err := hypervisor.Get().DestroyNetwork(ctx, name); err != nil {
+			logger.Get().Warnf("unable to remove isolated network '%s': %v", name, err)
+		}
+	}
+}
+
+// networksInUse will return the networks any domain interface is attached to
+func networksInUse(ctx context.Context) (map[string]bool, error) {
+	domcfgs, err := hypervisor.Get().ListDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inUse := map[string]bool{}
+	for _, domcfg := range domcfgs {
+		if domcfg.Devices == nil {
+			continue
+		}
+
+		for _, iface := range domcfg.Devices.Interfaces {
+			if iface.Source != nil && iface.Source.Network != nil {
+				inUse[iface.Source.Network.Network] = true
+			}
+		}
+	}
+
+	return inUse, nil
+}
+
+// interfaceNetworks will return the networks the matching interfaces of the domain are attached to
+func interfaceNetworks(ctx context.Context, vm string, selector string) ([]string, error) {
+	domcfg, err := hypervisor.Get().LookupDomain(ctx, vm)
+	if err != nil {
+		return nil, err
+	}
+
+	if domcfg.Devices == nil {
+		return nil, fmt.Errorf("domain '%s' has no interfaces", vm)
+	}
+
+	networks := []string{}
+	for _, iface := range domcfg.Devices.Interfaces {
+		if iface.Source == nil || iface.Source.Network == nil || !matchInterface(iface, selector) {
+			continue
+		}
+
+		if !slices.Contains(networks, iface.Source.Network.Network) {
+			networks = append(networks, iface.Source.Network.Network)
+		}
+	}
+
+	if len(networks) == 0 {
+		if selector != "" {
+			return nil, fmt.Errorf("interface '%s' of domain '%s' is not on a network", selector, vm)
+		}
+
+		return nil, fmt.Errorf("no interface of domain '%s' is on a network", vm)
+	}
+
+	return networks, nil
+}
+
+// cutForward will remove the forward of the networks the interfaces are on. The guests and the host keep
+// talking over the bridge, with its DHCP and DNS, but nothing is routed past the host. Every VM on the
+// network is cut off, isolate-nic only cuts off one VM
+func cutForward(ctx context.Context, vm string, selector string) error {
+	hv := hypervisor.Get()
+
+	networks, err := interfaceNetworks(ctx, vm, selector)
+	if err != nil {
+		return err
+	}
+
+	record, err := getFaultRecord(ctx, vm)
+	if err != nil {
+		return err
+	}
+
+	cut := 0
+	for _, network := range networks {
+		netcfg, err := hv.LookupNetwork(ctx, network)
+		if err != nil {
+			return err
+		}
+
+		if netcfg.Forward == nil {
+			logger.Get().Debugf("network '%s' does not forward, nothing to cut", network)
+			continue
+		}
+
+		// Keep the forward before removing it, otherwise it can not be put back
+		record.Forwards = append(record.Forwards, removedForward{Network: network, Forward: netcfg.Forward})
+		if err := setFaultRecord(ctx, vm, record); err != nil {
+			return err
+		}
+
+		netcfg.Forward = nil
+		if err := hv.RedefineNetwork(ctx, netcfg); err != nil {
+			return fmt.Errorf("unable to remove the forward of network '%s': %w", network, err)
+		}
+
+		if err := reattachInterfaces(ctx, network); err != nil {
+			return err
+		}
+		cut++
+	}
+
+	if cut == 0 {
+		return fmt.Errorf("no network of domain '%s' has a forward to cut", vm)
+	}
+
+	return nil
+}
+
+// restoreForward will put back the forward cut from the networks the interfaces are on
+func restoreForward(ctx context.Context, vm string, selector string) error {
+	hv := hypervisor.Get()
+
+	networks, err := interfaceNetworks(ctx, vm, selector)
+	if err != nil {
+		return err
+	}
+
+	record, err := getFaultRecord(ctx, vm)
+	if err != nil {
+		return err
+	}
+
+	restored := 0
+	for i := 0; i < len(record.Forwards); i++ {
+		removed := record.Forwards[i]
+		if !slices.Contains(networks, removed.Network) {
+			continue
+		}
+
+		netcfg, err := hv.LookupNetwork(ctx, removed.Network)
+		if err != nil {
+			return err
+		}
+
+		netcfg.Forward = removed.Forward
+		if err := hv.RedefineNetwork(ctx, netcfg); err != nil {
+			return fmt.Errorf("unable to restore the forward of network '%s': %w", removed.Network, err)
+		}
+
+		record.Forwards = append(record.Forwards[:i], record.Forwards[i+1:]...)
+		i--
+		if err := setFaultRecord(ctx, vm, record); err != nil {
+			return err
+		}
+
+		if err := reattachInterfaces(ctx, removed.Network); err != nil {
+			return err
+		}
+		restored++
+	}
+
+	if restored == 0 {
+		return fmt.Errorf("the forward of the networks of '%s' was not cut by snoman", vm)
+	}
+
+	return nil
+}
+
+// reattachInterfaces will plug the interfaces of running domains back into the bridge of the network, which
+// drops them when it restarts. libvirt only moves a running interface when its bridge changes, so each one
+// goes through the isolated copy of the network and back. Stopped domains attach when they start
+func reattachInterfaces(ctx context.Context, network string) error {
+	hv := hypervisor.Get()
+
+	domcfgs, err := hv.ListDomains(ctx)
+	if err != nil {
+		return err
+	}
+
+	isolated := ""
+	for _, domcfg := range domcfgs {
+		if domcfg.Devices == nil {
+			continue
+		}
+
+		if active, err := hv.DomainIsActive(ctx, domcfg.Name); err != nil {
+			return err
+		} else if !active {
+			continue
+		}
+
+		for _, iface := range domcfg.Devices.Interfaces {
+			if iface.MAC == nil || iface.Source == nil || iface.Source.Network == nil || iface.Source.Network.Network != network {
+				continue
+			}
+
+			if isolated == "" {
+				if isolated, err = createIsolatedNetwork(ctx, network); err != nil {
+					return err
+				}
+			}
+
+			for _, through := range []string{isolated, network} {
+				iface.Source = &libvirtxml.DomainInterfaceSource{
+					Network: &libvirtxml.DomainInterfaceSourceNetwork{Network: through},
+				}
+
+				if err := hv.UpdateDomainInterface(ctx, domcfg.Name, &iface, true); err != nil {
+					return fmt.Errorf("unable to reattach interface '%s' of '%s' to network '%s': %w", iface.MAC.Address, domcfg.Name, network, err)
+				}
+			}
+
+			logger.Get().Debugf("reattached interface '%s' of '%s' to network '%s'", iface.MAC.Address, domcfg.Name, network)
+		}
+	}
+
+	if isolated != "" {
+		removeIsolatedNetworks(ctx, map[string]bool{isolated: true})
+	}
+
+	return nil
+}
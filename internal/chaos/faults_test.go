@@ -0,0 +1,168 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"snoman/internal/vms/hypervisor"
+	"snoman/internal/vms/hypervisor/hypervisortest"
+	"testing"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+const testNetwork = "sno-network"
+
+// useFakeVMs will point the package at an in-memory hypervisor with the VMs defined on one network
+func useFakeVMs(t *testing.T, vms map[string]string) *hypervisor.Fake {
+	t.Helper()
+	ctx := context.Background()
+
+	fake := hypervisortest.UseFake(t)
+
+	if err := fake.CreateNetwork(ctx, &libvirtxml.Network{Name: testNetwork}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, mac := range vms {
+		domcfg := &libvirtxml.Domain{
+			Name: name,
+			Devices: &libvirtxml.DomainDeviceList{
+				Interfaces: []libvirtxml.DomainInterface{{
+					MAC:    &libvirtxml.DomainInterfaceMAC{Address: mac},
+					Source: &libvirtxml.DomainInterfaceSource{Network: &libvirtxml.DomainInterfaceSourceNetwork{Network: testNetwork}},
+					Alias:  &libvirtxml.DomainAlias{Name: "ua-eno1"},
+				}},
+			},
+		}
+		if err := fake.DefineDomain(ctx, domcfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return fake
+}
+
+// interfaceNetwork is the network the only interface of the VM is attached to
+func interfaceNetwork(t *testing.T, fake *hypervisor.Fake, vm string) string {
+	t.Helper()
+
+	domcfg, err := fake.LookupDomain(context.Background(), vm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return domcfg.Devices.Interfaces[0].Source.Network.Network
+}
+
+func TestIsolateInterfaces(t *testing.T) {
+	ctx := context.Background()
+	fake := useFakeVMs(t, map[string]string{"sno-a": "52:54:00:00:00:0a", "sno-b": "52:54:00:00:00:0b"})
+	isolated := testNetwork + isolatedNetworkSuffix
+
+	for _, vm := range []string{"sno-a", "sno-b"} {
+		if err := Inject(ctx, FaultSpec{Action: ACTION_ISOLATE_NIC, VM: vm, Interface: "eno1"}); err != nil {
+			t.Fatalf("isolating %s: %v", vm, err)
+		}
+		if got := interfaceNetwork(t, fake, vm); got != isolated {
+			t.Errorf("%s interface network = %s, want %s", vm, got, isolated)
+		}
+	}
+
+	// Isolating again leaves the interface where it is
+	if err := Inject(ctx, FaultSpec{Action: ACTION_ISOLATE_NIC, VM: "sno-a"}); err == nil {
+		t.Errorf("isolating an isolated interface again should have nothing to update")
+	}
+
+	// The isolated network is kept while the other VM is still on it
+	if err := Undo(ctx, FaultSpec{Action: ACTION_ISOLATE_NIC, VM: "sno-a", Interface: "eno1"}); err != nil {
+		t.Fatalf("restoring sno-a: %v", err)
+	}
+	if got := interfaceNetwork(t, fake, "sno-a"); got != testNetwork {
+		t.Errorf("sno-a interface network = %s, want %s", got, testNetwork)
+	}
+	if _, err := fake.LookupNetwork(ctx, isolated); err != nil {
+		t.Errorf("the isolated network was removed while sno-b still uses it: %v", err)
+	}
+
+	if err := Inject(ctx, FaultSpec{Action: ACTION_RESTORE_NIC, VM: "sno-b", Interface: "52:54:00:00:00:0b"}); err != nil {
+		t.Fatalf("restoring sno-b: %v", err)
+	}
+	if _, err := fake.LookupNetwork(ctx, isolated); !errors.Is(err, hypervisor.ErrNotFound) {
+		t.Errorf("isolated network lookup error = %v, want it removed", err)
+	}
+
+	// The original network was never touched
+	if _, err := fake.LookupNetwork(ctx, testNetwork); err != nil {
+		t.Errorf("the vm network is gone: %v", err)
+	}
+
+	record, err := getFaultRecord(ctx, "sno-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Interfaces) != 0 {
+		t.Errorf("fault record interfaces = %+v, want none", record.Interfaces)
+	}
+}
+
+func TestCutForward(t *testing.T) {
+	ctx := context.Background()
+	fake := useFakeVMs(t, map[string]string{"sno-a": "52:54:00:00:00:0a", "sno-b": "52:54:00:00:00:0b"})
+	forward := &libvirtxml.NetworkForward{Mode: "nat"}
+
+	netcfg, err := fake.LookupNetwork(ctx, testNetwork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	netcfg.Forward = forward
+	if err := fake.RedefineNetwork(ctx, netcfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only running VMs have interfaces to reattach
+	if err := fake.StartDomain(ctx, "sno-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Inject(ctx, FaultSpec{Action: ACTION_CUT_FORWARD, VM: "sno-a"}); err != nil {
+		t.Fatalf("cutting the forward: %v", err)
+	}
+	if netcfg, err := fake.LookupNetwork(ctx, testNetwork); err != nil || netcfg.Forward != nil {
+		t.Fatalf("network forward = %+v, %v, want it removed", netcfg.Forward, err)
+	}
+
+	// The network has no forward left to cut
+	if err := Inject(ctx, FaultSpec{Action: ACTION_CUT_FORWARD, VM: "sno-b"}); err == nil {
+		t.Errorf("cutting the forward again should have nothing to cut")
+	}
+
+	// Only the VM that cut the forward has it recorded
+	if err := Undo(ctx, FaultSpec{Action: ACTION_CUT_FORWARD, VM: "sno-b"}); err == nil {
+		t.Errorf("restoring from a VM that did not cut the forward should fail")
+	}
+
+	if err := Undo(ctx, FaultSpec{Action: ACTION_CUT_FORWARD, VM: "sno-a", Interface: "eno1"}); err != nil {
+		t.Fatalf("restoring the forward: %v", err)
+	}
+	if netcfg, err := fake.LookupNetwork(ctx, testNetwork); err != nil || netcfg.Forward == nil || netcfg.Forward.Mode != forward.Mode {
+		t.Fatalf("network forward = %+v, %v, want %+v", netcfg.Forward, err, forward)
+	}
+
+	// The interfaces went back onto the network, and the isolated copy used to reattach them is gone
+	for _, vm := range []string{"sno-a", "sno-b"} {
+		if got := interfaceNetwork(t, fake, vm); got != testNetwork {
+			t.Errorf("%s interface network = %s, want %s", vm, got, testNetwork)
+		}
+	}
+	if _, err := fake.LookupNetwork(ctx, testNetwork+isolatedNetworkSuffix); !errors.Is(err, hypervisor.ErrNotFound) {
+		t.Errorf("isolated network lookup error = %v, want it removed", err)
+	}
+
+	record, err := getFaultRecord(ctx, "sno-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(record.Forwards) != 0 {
+		t.Errorf("fault record forwards = %+v, want none", record.Forwards)
+	}
+}
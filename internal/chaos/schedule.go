@@ -0,0 +1,157 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"snoman/internal/logger"
+	"snoman/internal/vms/hypervisor"
+	"sync"
+	"time"
+)
+
+// Scheduler injects the faults of a schedule in the background. Faults wait on their phase, which is
+// signalled by the workflow through Phase or reached when the VM starts
+type Scheduler struct {
+	spec   *ScheduleSpec
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mux    sync.Mutex
+	phases map[string]chan struct{}
+}
+
+func NewScheduler(spec *ScheduleSpec) *Scheduler {
+	return &Scheduler{
+		spec:   spec,
+		phases: map[string]chan struct{}{},
+	}
+}
+
+// Start will schedule every fault. The schedule ends when ctx is done or Stop is called
+func (s *Scheduler) Start(ctx context.Context) error {
+	// Faults inherit the schedule VM
+	for i := range s.spec.Faults {
+		if s.spec.Faults[i].VM == "" {
+			s.spec.Faults[i].VM = s.spec.VM
+		}
+
+		if s.spec.Faults[i].VM == "" {
+			return fmt.Errorf("fault %d (%s) has no vm and the schedule has no default vm", i+1, s.spec.Faults[i].Action)
+		}
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	// A VM that is not defined yet still reaches the phase once it starts
+	err := hypervisor.Get().WatchDomainEvents(ctx, func(event hypervisor.DomainEvent) {
+		if event.Type != hypervisor.DOMAIN_EVENT_STARTED {
+			return
+		}
+
+		for _, fault := range s.spec.Faults {
+			if fault.Phase == PHASE_VM_STARTED && fault.VM == event.Domain {
+				s.Phase(PHASE_VM_STARTED)
+				return
+			}
+		}
+	})
+	if err != nil {
+		s.cancel()
+		return err
+	}
+
+	for _, fault := range s.spec.Faults {
+		s.wg.Add(1)
+		go func(fault FaultSpec) {
+			defer s.wg.Done()
+			s.run(ctx, fault)
+		}(fault)
+	}
+
+	return nil
+}
+
+// Phase will mark the workflow phase as reached. Faults waiting on it are injected after their delay
+func (s *Scheduler) Phase(name string) {
+	ch := s.phase(name)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	select {
+	case <-ch:
+	default:
+		logger.Get().Debugf("chaos schedule reached phase '%s'", name)
+		close(ch)
+	}
+}
+
+// Wait will block until every fault has been injected and undone
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Stop will cancel the faults that have not been injected yet and undo the ones that are still active
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) phase(name string) chan struct{} {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.phases[name]; !ok {
+		s.phases[name] = make(chan struct{})
+	}
+
+	return s.phases[name]
+}
+
+func (s *Scheduler) run(ctx context.Context, fault FaultSpec) {
+	log := logger.Get()
+
+	if fault.Phase != "" {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.phase(fault.Phase):
+		}
+	}
+
+	if !sleep(ctx, fault.At) {
+		return
+	}
+
+	if err := Inject(ctx, fault); err != nil {
+		log.Errorf("chaos: %v", err)
+		return
+	}
+
+	if fault.Duration == 0 {
+		return
+	}
+
+	// Active faults are always undone, even when the schedule is stopped early
+	sleep(ctx, fault.Duration)
+	if err := Undo(context.WithoutCancel(ctx), fault); err != nil {
+		log.Errorf("chaos: %v", err)
+	}
+}
+
+// sleep will return false if the context was done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,95 @@
+package chaos
+
+import (
+	"fmt"
+	vmutils "snoman/internal/vms/utils"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ScheduleSpec is a scripted list of faults to inject into a VM
+type ScheduleSpec struct {
+	VM     string      `yaml:"vm,omitempty" validate:"omitempty"` // Workflows default this to the VM they create
+	Faults []FaultSpec `yaml:"faults" validate:"required,dive"`
+}
+
+// FaultSpec is a single fault. It is injected At after the schedule starts, or after Phase is reached when
+// set, and undone after Duration when the action can be undone
+type FaultSpec struct {
+	Action    string        `yaml:"action" validate:"required,oneof=link-down link-up pause resume reset detach-disk attach-disk isolate-nic restore-nic cut-forward restore-forward"`
+	VM        string        `yaml:"vm,omitempty" validate:"omitempty"`
+	Interface string        `yaml:"interface,omitempty" validate:"omitempty"`
+	Disk      string        `yaml:"disk,omitempty" validate:"required_if=Action detach-disk,required_if=Action attach-disk"`
+	Phase     string        `yaml:"phase,omitempty" validate:"omitempty"`
+	At        time.Duration `yaml:"at,omitempty" validate:"omitempty"`
+	Duration  time.Duration `yaml:"duration,omitempty" validate:"omitempty"`
+}
+
+const (
+	ACTION_LINK_DOWN   = "link-down"
+	ACTION_LINK_UP     = "link-up"
+	ACTION_PAUSE       = "pause"
+	ACTION_RESUME      = "resume"
+	ACTION_RESET       = "reset"
+	ACTION_DETACH_DISK = "detach-disk"
+	ACTION_ATTACH_DISK = "attach-disk"
+	ACTION_ISOLATE_NIC = "isolate-nic"
+	ACTION_RESTORE_NIC = "restore-nic"
+	// The forward faults act on the networks of the VM interfaces, so every VM on them loses its uplink
+	ACTION_CUT_FORWARD     = "cut-forward"
+	ACTION_RESTORE_FORWARD = "restore-forward"
+
+	// PHASE_VM_STARTED is reached every time the VM is started, workflows signal their own phases
+	PHASE_VM_STARTED = "vm-started"
+)
+
+// inverses are the actions that undo a fault
+var inverses = map[string]string{
+	ACTION_LINK_DOWN:   ACTION_LINK_UP,
+	ACTION_PAUSE:       ACTION_RESUME,
+	ACTION_DETACH_DISK: ACTION_ATTACH_DISK,
+	ACTION_ISOLATE_NIC: ACTION_RESTORE_NIC,
+	ACTION_CUT_FORWARD: ACTION_RESTORE_FORWARD,
+}
+
+func (spec ScheduleSpec) Validate() error {
+	if err := vmutils.SpecValidator.Struct(spec); err != nil {
+		return fmt.Errorf("unable to validate ScheduleSpec: %w", err)
+	}
+
+	for i, fault := range spec.Faults {
+		if fault.Duration != 0 && inverses[fault.Action] == "" {
+			return fmt.Errorf("unable to validate ScheduleSpec: fault %d (%s) can not be undone so it can not have a duration", i+1, fault.Action)
+		}
+	}
+
+	return nil
+}
+
+func (spec *ScheduleSpec) UnmarshalYAML(yamlData []byte) error {
+	err := yaml.Unmarshal(yamlData, spec)
+	if err != nil {
+		return fmt.Errorf("unable to parse the spec: %w", err)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// String describes the fault for logs
+func (fault FaultSpec) String() string {
+	desc := fmt.Sprintf("%s on '%s'", fault.Action, fault.VM)
+
+	switch {
+	case fault.Disk != "":
+		desc += fmt.Sprintf(" disk %s", fault.Disk)
+	case fault.Interface != "":
+		desc += fmt.Sprintf(" interface %s", fault.Interface)
+	}
+
+	return desc
+}
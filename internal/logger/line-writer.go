@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// lineWriter will split the output of external commands into lines and write each one to the logger.
+// The zap std log writer reports short writes, which breaks io.Copy and io.MultiWriter, so this always
+// reports the full length
+type lineWriter struct {
+	mux sync.Mutex
+	out io.Writer
+	buf []byte
+}
+
+func newLineWriter(out io.Writer) *lineWriter {
+	return &lineWriter{out: out}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		if line := bytes.TrimSpace(w.buf[:i]); len(line) > 0 {
+			w.out.Write(line)
+		}
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
@@ -29,6 +29,26 @@ func WriteLogFile(contents []byte, workdir string, logFile string) error {
 	return nil
 }
 
+// OpenLogFile will create (or truncate) a file in the logs folder for content that is written over time
+// The caller must close the file
+func OpenLogFile(workdir string, logFile string) (*os.File, error) {
+	path := filepath.Join(workdir, DEFAULT_LOG_FOLDER_NAME)
+
+	if err := ensureLogDir(path); err != nil {
+		return nil, fmt.Errorf("unable to ensure the log directory exists: %w", err)
+	}
+
+	fpath := filepath.Join(path, logFile)
+	file, err := os.Create(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create log file: %w", err)
+	}
+
+	Get().Infof("writing log contents to %s", fpath)
+
+	return file, nil
+}
+
 func ensureLogDir(logdir string) error {
 	if _, err := os.Stat(logdir); errors.Is(err, os.ErrNotExist) {
 		err := os.Mkdir(logdir, os.ModePerm)
@@ -43,8 +43,8 @@ func Set(beVerbose bool, useJson bool) *zap.SugaredLogger {
 
 	// Create the stderr and stdout writers for os/exec
 	errl, _ := zap.NewStdLogAt(log, zapcore.ErrorLevel)
-	stderrw = errl.Writer()
-	stdoutw = zap.NewStdLog(log).Writer()
+	stderrw = newLineWriter(errl.Writer())
+	stdoutw = newLineWriter(zap.NewStdLog(log).Writer())
 
 	return log.Sugar()
 }
@@ -0,0 +1,58 @@
+package preflight
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	KVM_DEVICE = "/dev/kvm"
+)
+
+var nestedParams = []string{
+	"/sys/module/kvm_intel/parameters/nested",
+	"/sys/module/kvm_amd/parameters/nested",
+}
+
+// CheckKVM will fail if the KVM device is not available on the host
+func CheckKVM(r *Report) {
+	if _, err := os.Stat(KVM_DEVICE); err != nil {
+		r.Fail("kvm", "%s is not available: %v", KVM_DEVICE, err)
+		return
+	}
+
+	r.Pass("kvm", "%s is available", KVM_DEVICE)
+}
+
+// CheckNestedVirt will warn if nested virtualization is not enabled in the kvm module
+func CheckNestedVirt(r *Report) {
+	for _, param := range nestedParams {
+		data, err := os.ReadFile(param)
+		if err != nil {
+			continue
+		}
+
+		value := strings.TrimSpace(string(data))
+		if value == "Y" || value == "1" {
+			r.Pass("nested-virt", "enabled (%s)", param)
+		} else {
+			r.Warn("nested-virt", "disabled (%s=%s)", param, value)
+		}
+
+		return
+	}
+
+	r.Warn("nested-virt", "unable to determine, no kvm_intel or kvm_amd module loaded")
+}
+
+// CheckBinary will fail if the binary can not be found in the PATH or at the given path
+func CheckBinary(r *Report, name string, path string) {
+	found, err := exec.LookPath(path)
+	if err != nil {
+		r.Fail(name, "not found: %v", err)
+		return
+	}
+
+	r.Pass(name, "found at %s", found)
+}
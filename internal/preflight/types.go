@@ -0,0 +1,94 @@
+package preflight
+
+import (
+	"fmt"
+	"io"
+	"snoman/internal/logger"
+	"strings"
+	"text/tabwriter"
+)
+
+type Status string
+
+const (
+	STATUS_PASS Status = "PASS"
+	STATUS_WARN Status = "WARN"
+	STATUS_FAIL Status = "FAIL"
+)
+
+var ErrPreflightFailed = fmt.Errorf("one or more pre-flight checks failed")
+
+type Result struct {
+	Name    string
+	Status  Status
+	Message string
+}
+
+// Report is the collection of results from a pre-flight run
+type Report struct {
+	Results []Result
+}
+
+func (r *Report) Pass(name string, format string, args ...interface{}) {
+	r.add(name, STATUS_PASS, format, args...)
+}
+
+func (r *Report) Warn(name string, format string, args ...interface{}) {
+	r.add(name, STATUS_WARN, format, args...)
+}
+
+func (r *Report) Fail(name string, format string, args ...interface{}) {
+	r.add(name, STATUS_FAIL, format, args...)
+}
+
+func (r *Report) add(name string, status Status, format string, args ...interface{}) {
+	r.Results = append(r.Results, Result{
+		Name:    name,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Err will return ErrPreflightFailed listing the failed checks, or nil if nothing failed
+func (r *Report) Err() error {
+	failed := []string{}
+	for _, res := range r.Results {
+		if res.Status == STATUS_FAIL {
+			failed = append(failed, fmt.Sprintf("%s: %s", res.Name, res.Message))
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrPreflightFailed, strings.Join(failed, "; "))
+}
+
+// Log will write each result to the logger at a level matching its status
+func (r *Report) Log() {
+	log := logger.Get()
+
+	for _, res := range r.Results {
+		switch res.Status {
+		case STATUS_PASS:
+			log.Infow("pre-flight check passed", "check", res.Name, "result", res.Message)
+		case STATUS_WARN:
+			log.Warnw("pre-flight check warning", "check", res.Name, "result", res.Message)
+		case STATUS_FAIL:
+			log.Errorw("pre-flight check failed", "check", res.Name, "result", res.Message)
+		}
+	}
+}
+
+// Print will write the report as a table
+func (r *Report) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "STATUS\tCHECK\tRESULT")
+	for _, res := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", res.Status, res.Name, res.Message)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Rules decide which destinations the proxy forwards to. A destination is denied when it matches a deny
+// pattern, or when allow patterns are set and it matches none of them. Destinations are matched as the client
+// named them and are never resolved, so a CIDR only matches a destination given as an IP
+type Rules struct {
+	allow []pattern
+	deny  []pattern
+}
+
+// pattern matches a host exactly, a domain and its subdomains (.example.com or *.example.com), or an IP in a CIDR
+type pattern struct {
+	raw    string
+	host   string
+	domain string
+	cidr   *net.IPNet
+}
+
+// NewRules will parse the allow and deny host patterns. CIDRs can not be denied, a client naming the destination
+// by host would get past them
+func NewRules(allow []string, deny []string) (*Rules, error) {
+	rules := &Rules{}
+
+	var err error
+	if rules.allow, err = parsePatterns(allow); err != nil {
+		return nil, fmt.Errorf("invalid allow pattern: %w", err)
+	}
+
+	if rules.deny, err = parsePatterns(deny); err != nil {
+		return nil, fmt.Errorf("invalid deny pattern: %w", err)
+	}
+
+	for _, p := range rules.deny {
+		if p.cidr != nil {
+			return nil, fmt.Errorf("invalid deny pattern: '%s' is a CIDR, which a destination named by host would get past, only allow the CIDR instead", p.raw)
+		}
+	}
+
+	return rules, nil
+}
+
+func parsePatterns(raw []string) ([]pattern, error) {
+	patterns := []pattern{}
+	for _, entry := range raw {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		p := pattern{raw: entry}
+		switch {
+		case strings.Contains(entry, "/"):
+			_, cidr, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("'%s' is not a valid CIDR: %w", entry, err)
+			}
+			p.cidr = cidr
+		case strings.HasPrefix(entry, "*."):
+			p.domain = strings.TrimPrefix(entry, "*")
+		case strings.HasPrefix(entry, "."):
+			p.domain = entry
+		case strings.Contains(entry, "*"):
+			return nil, fmt.Errorf("'%s' can only use a wildcard as the first label, ex: *.example.com", entry)
+		default:
+			p.host = entry
+		}
+
+		patterns = append(patterns, p)
+	}
+
+	return patterns, nil
+}
+
+func (p pattern) matches(host string) bool {
+	switch {
+	case p.cidr != nil:
+		ip := net.ParseIP(host)
+		return ip != nil && p.cidr.Contains(ip)
+	case p.domain != "":
+		return host == strings.TrimPrefix(p.domain, ".") || strings.HasSuffix(host, p.domain)
+	default:
+		return host == p.host
+	}
+}
+
+// Allowed will return whether the host (without a port) can be reached and the pattern that decided it
+func (r *Rules) Allowed(host string) (bool, string) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	for _, p := range r.deny {
+		if p.matches(host) {
+			return false, p.raw
+		}
+	}
+
+	if len(r.allow) == 0 {
+		return true, ""
+	}
+
+	for _, p := range r.allow {
+		if p.matches(host) {
+			return true, p.raw
+		}
+	}
+
+	return false, ""
+}
@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   []string
+		deny    []string
+		wantErr string
+	}{
+		{
+			name:  "every kind of pattern",
+			allow: []string{"quay.io", ".redhat.com", "*.example.com", "10.0.0.0/8", " "},
+			deny:  []string{"bad.quay.io", ".internal"},
+		},
+		{
+			name:    "invalid CIDR",
+			allow:   []string{"10.0.0.0/33"},
+			wantErr: "invalid allow pattern: '10.0.0.0/33' is not a valid CIDR",
+		},
+		{
+			name:    "wildcard after the first label",
+			allow:   []string{"registry.*.example.com"},
+			wantErr: "can only use a wildcard as the first label",
+		},
+		{
+			name:    "denied CIDR",
+			deny:    []string{"169.254.0.0/16"},
+			wantErr: "invalid deny pattern: '169.254.0.0/16' is a CIDR",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRules(tt.allow, tt.deny)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewRules() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRules() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		name        string
+		allow       []string
+		deny        []string
+		host        string
+		wantAllowed bool
+		wantRule    string
+	}{
+		{
+			name:        "no rules",
+			host:        "quay.io",
+			wantAllowed: true,
+		},
+		{
+			name:        "exact host",
+			allow:       []string{"quay.io"},
+			host:        "quay.io",
+			wantAllowed: true,
+			wantRule:    "quay.io",
+		},
+		{
+			name:  "exact host does not match subdomains",
+			allow: []string{"quay.io"},
+			host:  "cdn.quay.io",
+		},
+		{
+			name:        "case and trailing dot",
+			allow:       []string{"Quay.IO"},
+			host:        "QUAY.io.",
+			wantAllowed: true,
+			wantRule:    "quay.io",
+		},
+		{
+			name:        "dot domain matches the domain",
+			allow:       []string{".redhat.com"},
+			host:        "redhat.com",
+			wantAllowed: true,
+			wantRule:    ".redhat.com",
+		},
+		{
+			name:        "dot domain matches subdomains",
+			allow:       []string{".redhat.com"},
+			host:        "registry.access.redhat.com",
+			wantAllowed: true,
+			wantRule:    ".redhat.com",
+		},
+		{
+			name:  "dot domain does not match a longer label",
+			allow: []string{".redhat.com"},
+			host:  "notredhat.com",
+		},
+		{
+			name:        "wildcard domain matches subdomains",
+			allow:       []string{"*.example.com"},
+			host:        "mirror.example.com",
+			wantAllowed: true,
+			wantRule:    "*.example.com",
+		},
+		{
+			name:        "wildcard domain matches the domain",
+			allow:       []string{"*.example.com"},
+			host:        "example.com",
+			wantAllowed: true,
+			wantRule:    "*.example.com",
+		},
+		{
+			name:  "wildcard domain does not match a longer label",
+			allow: []string{"*.example.com"},
+			host:  "badexample.com",
+		},
+		{
+			name:        "CIDR matches an IP in it",
+			allow:       []string{"192.168.122.0/24"},
+			host:        "192.168.122.10",
+			wantAllowed: true,
+			wantRule:    "192.168.122.0/24",
+		},
+		{
+			name:        "CIDR matches an IPv6 in it",
+			allow:       []string{"fd00::/8"},
+			host:        "fd00::10",
+			wantAllowed: true,
+			wantRule:    "fd00::/8",
+		},
+		{
+			name:  "CIDR does not match an IP outside it",
+			allow: []string{"192.168.122.0/24"},
+			host:  "192.168.123.10",
+		},
+		{
+			name:  "CIDR does not match a host name",
+			allow: []string{"192.168.122.0/24"},
+			host:  "hv.example.com",
+		},
+		{
+			name:     "deny without allow",
+			deny:     []string{".internal"},
+			host:     "metadata.internal",
+			wantRule: ".internal",
+		},
+		{
+			name:        "not denied without allow",
+			deny:        []string{".internal"},
+			host:        "quay.io",
+			wantAllowed: true,
+		},
+		{
+			name:     "deny wins over allow",
+			allow:    []string{".quay.io"},
+			deny:     []string{"bad.quay.io"},
+			host:     "bad.quay.io",
+			wantRule: "bad.quay.io",
+		},
+		{
+			name:        "allowed next to a denied host",
+			allow:       []string{".quay.io"},
+			deny:        []string{"bad.quay.io"},
+			host:        "cdn.quay.io",
+			wantAllowed: true,
+			wantRule:    ".quay.io",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := NewRules(tt.allow, tt.deny)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			allowed, rule := rules.Allowed(tt.host)
+			if allowed != tt.wantAllowed || rule != tt.wantRule {
+				t.Errorf("Allowed(%s) = %v, %q, want %v, %q", tt.host, allowed, rule, tt.wantAllowed, tt.wantRule)
+			}
+		})
+	}
+}
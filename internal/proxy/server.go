@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"snoman/internal/logger"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_PORT            = 3128
+	DEFAULT_ACCESS_LOG_FILE = "proxy-access.log"
+	DEFAULT_DIAL_TIMEOUT    = 30 * time.Second
+)
+
+// Spec configures the built-in forward proxy
+type Spec struct {
+	Port  uint     `yaml:"port,omitempty"`
+	Allow []string `yaml:"allow,omitempty"` // When set, only these destinations can be reached
+	Deny  []string `yaml:"deny,omitempty"`
+}
+
+// Server is an HTTP forward proxy that tunnels HTTPS with CONNECT, so a cluster can be installed through it
+type Server struct {
+	rules     *Rules
+	accessLog io.Writer
+	logMutex  sync.Mutex
+	http      *http.Server
+	forward   *httputil.ReverseProxy
+}
+
+// NewServer will proxy on host:port, every request is written to accessLog when it is not nil
+func NewServer(host string, spec *Spec, accessLog io.Writer) (*Server, error) {
+	rules, err := NewRules(spec.Allow, spec.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	port := spec.Port
+	if port == 0 {
+		port = DEFAULT_PORT
+	}
+
+	s := &Server{
+		rules:     rules,
+		accessLog: accessLog,
+	}
+
+	// Plain HTTP requests already carry the absolute URL of the destination, the proxy must not follow
+	// another proxy from its own environment
+	s.forward = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {},
+		Transport: &http.Transport{
+			DialContext:         (&net.Dialer{Timeout: DEFAULT_DIAL_TIMEOUT}).DialContext,
+			TLSHandshakeTimeout: DEFAULT_DIAL_TIMEOUT,
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logger.Get().Debugf("unable to proxy %s %s: %v", r.Method, r.URL, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		},
+	}
+
+	s.http = &http.Server{
+		Addr:    net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10)),
+		Handler: http.HandlerFunc(s.serve),
+	}
+
+	return s, nil
+}
+
+// URL is the address a proxy on host and port can be reached on. This is needed before the server can be
+// started, as the install config points the cluster at it
+func URL(host string, port uint) string {
+	return fmt.Sprintf("http://%s", net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10)))
+}
+
+// URL is the address the proxy is listening on
+func (s *Server) URL() string {
+	return fmt.Sprintf("http://%s", s.http.Addr)
+}
+
+// Start will bind the listen address and proxy in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("unable to listen on '%s': %w", s.http.Addr, err)
+	}
+
+	go func() {
+		if err := s.http.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Get().Errorf("proxy server stopped unexpectedly: %v", err)
+		}
+	}()
+
+	logger.Get().Infof("serving the forward proxy on %s", s.URL())
+
+	return nil
+}
+
+// Shutdown will stop accepting requests, CONNECT tunnels are not tracked by the server and are closed
+// when either side hangs up
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.http.Shutdown(ctx)
+}
+
+// access is one access log entry
+type access struct {
+	start  time.Time
+	remote string
+	method string
+	target string
+	status int
+	bytes  int64
+	rule   string
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	entry := &access{start: time.Now(), remote: r.RemoteAddr, method: r.Method}
+	defer s.log(entry)
+
+	host := r.URL.Hostname()
+	if r.Method == http.MethodConnect {
+		entry.target = r.Host
+		host, _, _ = net.SplitHostPort(r.Host)
+	} else {
+		entry.target = r.URL.String()
+		if !r.URL.IsAbs() {
+			entry.status = http.StatusBadRequest
+			http.Error(w, "only proxy requests with an absolute URL are served", entry.status)
+			return
+		}
+	}
+
+	allowed, rule := s.rules.Allowed(host)
+	entry.rule = rule
+	if !allowed {
+		entry.status = http.StatusForbidden
+		http.Error(w, fmt.Sprintf("destination '%s' is not allowed by the proxy", host), entry.status)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		s.tunnel(w, r, entry)
+		return
+	}
+
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.forward.ServeHTTP(rec, r)
+	entry.status = rec.status
+	entry.bytes = rec.bytes
+}
+
+// tunnel will connect the client to the destination and copy the bytes both ways until one side is done
+func (s *Server) tunnel(w http.ResponseWriter, r *http.Request, entry *access) {
+	upstream, err := net.DialTimeout("tcp", r.Host, DEFAULT_DIAL_TIMEOUT)
+	if err != nil {
+		entry.status = http.StatusBadGateway
+		http.Error(w, fmt.Sprintf("unable to connect to '%s': %v", r.Host, err), entry.status)
+		return
+	}
+	defer upstream.Close()
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		entry.status = http.StatusInternalServerError
+		http.Error(w, "the connection can not be tunneled", entry.status)
+		return
+	}
+
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		entry.status = http.StatusInternalServerError
+		logger.Get().Debugf("unable to hijack the proxy connection of %s: %v", r.RemoteAddr, err)
+		return
+	}
+	defer client.Close()
+
+	entry.status = http.StatusOK
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return
+	}
+
+	done := make(chan int64, 1)
+	go func() {
+		// The client may have sent the start of the TLS handshake along with the CONNECT
+		n, _ := io.Copy(upstream, buffered.Reader)
+		if tcp, ok := upstream.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		done <- n
+	}()
+
+	n, _ := io.Copy(client, upstream)
+	if tcp, ok := client.(*net.TCPConn); ok {
+		tcp.CloseWrite()
+	}
+
+	entry.bytes = n + <-done
+}
+
+// log will write the access log line and a debug log entry of a request
+func (s *Server) log(entry *access) {
+	decision := "allowed"
+	if entry.status == http.StatusForbidden {
+		decision = "denied"
+	}
+
+	duration := time.Since(entry.start)
+	logger.Get().Debugw("proxy request", "remote", entry.remote, "method", entry.method, "target", entry.target, "status", entry.status, "bytes", entry.bytes, "duration", duration, "decision", decision, "rule", entry.rule)
+
+	if s.accessLog == nil {
+		return
+	}
+
+	rule := entry.rule
+	if rule == "" {
+		rule = "-"
+	}
+
+	s.logMutex.Lock()
+	defer s.logMutex.Unlock()
+	fmt.Fprintf(s.accessLog, "%s %s %s %s %d %d %dms %s %s\n", entry.start.Format(time.RFC3339), entry.remote, entry.method, entry.target, entry.status, entry.bytes, duration.Milliseconds(), decision, rule)
+}
+
+// responseRecorder keeps the status and size of a forwarded response for the access log
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,103 @@
+package pxe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"snoman/internal/logger"
+	"strconv"
+)
+
+const (
+	DEFAULT_HTTP_PORT = 8080
+	DEFAULT_TFTP_PORT = 69
+)
+
+// Server serves the PXE artifacts over HTTP, and over TFTP for clients that can not boot from HTTP
+type Server struct {
+	dir      string
+	bootFile string
+	host     string
+	httpPort uint
+	tftpPort uint
+	http     *http.Server
+	tftp     *tftpServer
+}
+
+// NewServer will serve dir on host. TFTP only serves bootFile, the iPXE script that fetches the other
+// artifacts over HTTP. A tftpPort of 0 disables TFTP
+func NewServer(dir string, bootFile string, host string, httpPort uint, tftpPort uint) *Server {
+	s := &Server{
+		dir:      dir,
+		bootFile: bootFile,
+		host:     host,
+		httpPort: httpPort,
+		tftpPort: tftpPort,
+	}
+
+	files := http.FileServer(http.Dir(dir))
+	s.http = &http.Server{
+		Addr: net.JoinHostPort(host, strconv.FormatUint(uint64(httpPort), 10)),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger.Get().Debugw("pxe artifact requested", "remote", r.RemoteAddr, "method", r.Method, "path", r.URL.Path, "range", r.Header.Get("Range"))
+			files.ServeHTTP(w, r)
+		}),
+	}
+
+	return s
+}
+
+// Start will bind the listen addresses and serve in the background
+func (s *Server) Start() error {
+	log := logger.Get()
+
+	listener, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("unable to listen on '%s': %w", s.http.Addr, err)
+	}
+
+	go func() {
+		if err := s.http.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("pxe http server stopped unexpectedly: %v", err)
+		}
+	}()
+
+	log.Infof("serving pxe artifacts from %s on http://%s", s.dir, listener.Addr())
+
+	if s.tftpPort != 0 {
+		s.tftp = newTftpServer(s.dir, s.bootFile)
+		if err := s.tftp.Start(net.JoinHostPort(s.host, strconv.FormatUint(uint64(s.tftpPort), 10))); err != nil {
+			s.http.Close()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BaseURL is the HTTP address the artifacts are served on
+func (s *Server) BaseURL() string {
+	return fmt.Sprintf("http://%s", s.http.Addr)
+}
+
+// HttpBaseURL is the address a server on host and port will serve the artifacts on. This is needed before
+// the server can be started, as the installer bakes it into the iPXE script
+func HttpBaseURL(host string, port uint) string {
+	return fmt.Sprintf("http://%s", net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10)))
+}
+
+// URL returns the HTTP URL of an artifact
+func (s *Server) URL(name string) string {
+	return fmt.Sprintf("%s/%s", s.BaseURL(), name)
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	var tftpErr error
+	if s.tftp != nil {
+		tftpErr = s.tftp.Close()
+	}
+
+	return errors.Join(s.http.Shutdown(ctx), tftpErr)
+}
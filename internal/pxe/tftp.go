@@ -0,0 +1,243 @@
+package pxe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"snoman/internal/logger"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A read only TFTP server (RFC 1350) with the blksize and tsize options (RFC 2348, RFC 2349)
+// that firmware PXE clients use
+
+const (
+	tftpOpRRQ   = 1
+	tftpOpData  = 3
+	tftpOpAck   = 4
+	tftpOpError = 5
+	tftpOpOack  = 6
+
+	tftpErrUndefined = 0
+	tftpErrNotFound  = 1
+	tftpErrAccess    = 2
+	tftpErrIllegal   = 4
+
+	tftpDefaultBlockSize = 512
+	tftpMaxBlockSize     = 65464
+	tftpTimeout          = 2 * time.Second
+	tftpRetries          = 5
+)
+
+type tftpServer struct {
+	dir string
+	// files are the only names in dir that are served, firmware only loads the boot file over TFTP
+	files map[string]bool
+	conn  net.PacketConn
+}
+
+func newTftpServer(dir string, files ...string) *tftpServer {
+	s := &tftpServer{dir: dir, files: map[string]bool{}}
+	for _, name := range files {
+		s.files[name] = true
+	}
+
+	return s
+}
+
+func (s *tftpServer) Start(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to listen for tftp on '%s': %w", addr, err)
+	}
+	s.conn = conn
+
+	go s.serve()
+
+	logger.Get().Infof("serving pxe artifacts from %s on tftp://%s", s.dir, conn.LocalAddr())
+
+	return nil
+}
+
+func (s *tftpServer) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	return s.conn.Close()
+}
+
+func (s *tftpServer) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Get().Errorf("tftp server stopped unexpectedly: %v", err)
+			}
+			return
+		}
+
+		packet := append([]byte{}, buf[:n]...)
+		go s.handle(addr, packet)
+	}
+}
+
+// handle will answer a single request from its own port, as the protocol requires
+func (s *tftpServer) handle(addr net.Addr, packet []byte) {
+	log := logger.Get()
+
+	host, _, _ := net.SplitHostPort(s.conn.LocalAddr().String())
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		log.Warnf("unable to open tftp transfer port: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if len(packet) < 2 || binary.BigEndian.Uint16(packet) != tftpOpRRQ {
+		sendTftpError(conn, addr, tftpErrIllegal, "only read requests are supported")
+		return
+	}
+
+	fields := strings.Split(string(packet[2:]), "\x00")
+	if len(fields) < 2 {
+		sendTftpError(conn, addr, tftpErrIllegal, "malformed request")
+		return
+	}
+
+	name := fields[0]
+	log.Debugw("pxe artifact requested", "remote", addr, "protocol", "tftp", "path", name)
+
+	// Clean against the root so requests can not escape the artifacts folder
+	clean := strings.TrimPrefix(filepath.Clean("/"+name), "/")
+	if !s.files[clean] {
+		sendTftpError(conn, addr, tftpErrNotFound, "file not found")
+		return
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, clean))
+	if err != nil {
+		sendTftpError(conn, addr, tftpErrNotFound, "file not found")
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		sendTftpError(conn, addr, tftpErrAccess, "not a file")
+		return
+	}
+
+	// Options come in name/value pairs after the mode
+	blockSize := tftpDefaultBlockSize
+	oack := []string{}
+	for i := 2; i+1 < len(fields); i += 2 {
+		switch strings.ToLower(fields[i]) {
+		case "blksize":
+			if size, err := strconv.Atoi(fields[i+1]); err == nil && size >= 8 {
+				blockSize = min(size, tftpMaxBlockSize)
+				oack = append(oack, "blksize", strconv.Itoa(blockSize))
+			}
+		case "tsize":
+			oack = append(oack, "tsize", strconv.FormatInt(stat.Size(), 10))
+		}
+	}
+
+	// The block number is 16 bits and clients disagree on what comes after block 65535, so larger transfers
+	// are refused instead of wrapping around
+	if stat.Size()/int64(blockSize) >= math.MaxUint16 {
+		sendTftpError(conn, addr, tftpErrUndefined, fmt.Sprintf("file is too large for block size %d", blockSize))
+		return
+	}
+
+	if len(oack) > 0 {
+		packet := binary.BigEndian.AppendUint16(nil, tftpOpOack)
+		packet = append(packet, []byte(strings.Join(oack, "\x00")+"\x00")...)
+
+		// The client acknowledges the options with block 0
+		if err := sendTftpPacket(conn, addr, packet, 0); err != nil {
+			log.Debugf("tftp transfer of %s to %s failed: %v", name, addr, err)
+			return
+		}
+	}
+
+	data := make([]byte, blockSize)
+	for block := uint16(1); ; block++ {
+		n, err := io.ReadFull(file, data)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			sendTftpError(conn, addr, tftpErrAccess, "read error")
+			return
+		}
+
+		packet := binary.BigEndian.AppendUint16(nil, tftpOpData)
+		packet = binary.BigEndian.AppendUint16(packet, block)
+		packet = append(packet, data[:n]...)
+
+		if err := sendTftpPacket(conn, addr, packet, block); err != nil {
+			log.Debugf("tftp transfer of %s to %s failed: %v", name, addr, err)
+			return
+		}
+
+		// A short block ends the transfer
+		if n < blockSize {
+			return
+		}
+
+		// The file grew while it was sent
+		if block == math.MaxUint16 {
+			sendTftpError(conn, addr, tftpErrUndefined, "file is too large for the block size")
+			return
+		}
+	}
+}
+
+// sendTftpPacket will send the packet until the client acknowledges the block
+func sendTftpPacket(conn net.PacketConn, addr net.Addr, packet []byte, block uint16) error {
+	ack := make([]byte, 4)
+
+	for attempt := 0; attempt < tftpRetries; attempt++ {
+		if _, err := conn.WriteTo(packet, addr); err != nil {
+			return err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(tftpTimeout))
+		for {
+			n, from, err := conn.ReadFrom(ack)
+			if err != nil {
+				break // Timed out, send again
+			}
+
+			if from.String() != addr.String() || n < 4 {
+				continue
+			}
+
+			switch binary.BigEndian.Uint16(ack) {
+			case tftpOpAck:
+				if binary.BigEndian.Uint16(ack[2:]) == block {
+					return nil
+				}
+			case tftpOpError:
+				return fmt.Errorf("client aborted the transfer")
+			}
+		}
+	}
+
+	return fmt.Errorf("no acknowledgement for block %d", block)
+}
+
+func sendTftpError(conn net.PacketConn, addr net.Addr, code uint16, msg string) {
+	packet := binary.BigEndian.AppendUint16(nil, tftpOpError)
+	packet = binary.BigEndian.AppendUint16(packet, code)
+	packet = append(packet, []byte(msg)...)
+	packet = append(packet, 0)
+
+	conn.WriteTo(packet, addr)
+}
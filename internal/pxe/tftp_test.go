@@ -0,0 +1,207 @@
+package pxe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBootFile = "agent.x86_64.ipxe"
+
+// tftpResult is what a client got for its read request
+type tftpResult struct {
+	data    []byte
+	oack    map[string]string
+	errCode int // -1 when the transfer succeeded
+}
+
+// tftpGet will read name from the server like a firmware client, acknowledging every block
+func tftpGet(t *testing.T, server net.Addr, name string, options ...string) tftpResult {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	request := binary.BigEndian.AppendUint16(nil, tftpOpRRQ)
+	request = append(request, []byte(strings.Join(append([]string{name, "octet"}, options...), "\x00")+"\x00")...)
+	if _, err := conn.WriteTo(request, server); err != nil {
+		t.Fatal(err)
+	}
+
+	ack := func(to net.Addr, block uint16) {
+		packet := binary.BigEndian.AppendUint16(nil, tftpOpAck)
+		if _, err := conn.WriteTo(binary.BigEndian.AppendUint16(packet, block), to); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := tftpResult{errCode: -1}
+	blockSize := tftpDefaultBlockSize
+	next := uint16(1)
+	buf := make([]byte, tftpMaxBlockSize+4)
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("no answer from the tftp server: %v", err)
+		}
+
+		switch binary.BigEndian.Uint16(buf) {
+		case tftpOpError:
+			result.errCode = int(binary.BigEndian.Uint16(buf[2:]))
+			return result
+		case tftpOpOack:
+			result.oack = map[string]string{}
+			fields := strings.Split(strings.TrimSuffix(string(buf[2:n]), "\x00"), "\x00")
+			for i := 0; i+1 < len(fields); i += 2 {
+				result.oack[fields[i]] = fields[i+1]
+			}
+			if size, ok := result.oack["blksize"]; ok {
+				if blockSize, err = strconv.Atoi(size); err != nil {
+					t.Fatalf("invalid blksize %q acknowledged", size)
+				}
+			}
+			ack(from, 0)
+		case tftpOpData:
+			block := binary.BigEndian.Uint16(buf[2:])
+			if block != next {
+				t.Fatalf("got block %d, want %d", block, next)
+			}
+			result.data = append(result.data, buf[4:n]...)
+			ack(from, block)
+			next++
+
+			if n-4 < blockSize {
+				return result
+			}
+		default:
+			t.Fatalf("unexpected tftp opcode %d", binary.BigEndian.Uint16(buf))
+		}
+	}
+}
+
+func TestTftpServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		request  string
+		options  []string
+		wantOack map[string]string
+		wantErr  int
+	}{
+		{
+			name:    "default block size with a short final block",
+			size:    1100,
+			request: testBootFile,
+			wantErr: -1,
+		},
+		{
+			name:    "empty final block",
+			size:    2 * tftpDefaultBlockSize,
+			request: testBootFile,
+			wantErr: -1,
+		},
+		{
+			name:    "leading slash",
+			size:    10,
+			request: "/" + testBootFile,
+			wantErr: -1,
+		},
+		{
+			name:     "blksize and tsize acknowledged",
+			size:     3000,
+			request:  testBootFile,
+			options:  []string{"blksize", "1024", "tsize", "0"},
+			wantOack: map[string]string{"blksize": "1024", "tsize": "3000"},
+			wantErr:  -1,
+		},
+		{
+			name:     "tsize only",
+			size:     700,
+			request:  testBootFile,
+			options:  []string{"tsize", "0"},
+			wantOack: map[string]string{"tsize": "700"},
+			wantErr:  -1,
+		},
+		{
+			name:    "missing file",
+			request: "missing.ipxe",
+			wantErr: tftpErrNotFound,
+		},
+		{
+			name:    "artifacts other than the boot file",
+			request: "agent.x86_64-initrd.img",
+			wantErr: tftpErrNotFound,
+		},
+		{
+			name:    "path traversal",
+			request: "../secret",
+			wantErr: tftpErrNotFound,
+		},
+		{
+			name:    "more blocks than the block number holds",
+			size:    8 * 65535,
+			request: testBootFile,
+			options: []string{"blksize", "8"},
+			wantErr: tftpErrUndefined,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "pxe")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			content := bytes.Repeat([]byte("#!ipxe\n"), tt.size/7+1)[:tt.size]
+			files := map[string][]byte{
+				filepath.Join(dir, testBootFile):              content,
+				filepath.Join(dir, "agent.x86_64-initrd.img"): []byte("initrd"),
+				filepath.Join(root, "secret"):                 []byte("secret"),
+			}
+			for path, data := range files {
+				if err := os.WriteFile(path, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			server := newTftpServer(dir, testBootFile)
+			if err := server.Start("127.0.0.1:0"); err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			got := tftpGet(t, server.conn.LocalAddr(), tt.request, tt.options...)
+			if got.errCode != tt.wantErr {
+				t.Fatalf("error code = %d, want %d", got.errCode, tt.wantErr)
+			}
+			if tt.wantErr != -1 {
+				return
+			}
+
+			if !bytes.Equal(got.data, content) {
+				t.Errorf("got %d bytes, want %d", len(got.data), len(content))
+			}
+
+			if len(got.oack) != len(tt.wantOack) {
+				t.Errorf("oack = %v, want %v", got.oack, tt.wantOack)
+			}
+			for name, value := range tt.wantOack {
+				if got.oack[name] != value {
+					t.Errorf("oack %s = %s, want %s", name, got.oack[name], value)
+				}
+			}
+		})
+	}
+}
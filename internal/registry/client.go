@@ -0,0 +1,221 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"snoman/internal/biputils/secrets"
+	"strings"
+	"time"
+)
+
+const DEFAULT_REQUEST_TIMEOUT = 30 * time.Second
+
+// manifestMediaTypes are the manifests a digest can be resolved for, release images are manifest lists on
+// multi-arch payloads and single manifests otherwise
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Client is a minimal registry v2 client that only knows what is needed to resolve image digests
+type Client struct {
+	pullSecret *secrets.PullSecret
+	http       *http.Client
+}
+
+// NewClient will use the credentials of the pull secret, which can be empty, and trust the registries signed by
+// the PEM bundle as well as the system CAs
+func NewClient(pullSecret string, trustBundle string) (*Client, error) {
+	ps := &secrets.PullSecret{}
+	if pullSecret != "" {
+		var err error
+		if ps, err = secrets.ParsePullSecret(pullSecret); err != nil {
+			return nil, err
+		}
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if trustBundle != "" && !pool.AppendCertsFromPEM([]byte(trustBundle)) {
+		return nil, fmt.Errorf("the trust bundle does not contain any PEM certificates")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+	return &Client{
+		pullSecret: ps,
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   DEFAULT_REQUEST_TIMEOUT,
+		},
+	}, nil
+}
+
+// ResolveDigest will return the digest the tag of the image points at, images that have a digest return it
+func (c *Client) ResolveDigest(ctx context.Context, ref *Reference) (string, error) {
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", ref.Registry, ref.Repository, ref.Tag)
+
+	resp, err := c.get(ctx, manifestURL, ref)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to get the manifest of %s: %s", ref, resp.Status)
+	}
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); strings.HasPrefix(digest, "sha256:") {
+		return digest, nil
+	}
+
+	// Registries do not have to send the digest header, the digest is the hash of the manifest
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", fmt.Errorf("unable to read the manifest of %s: %w", ref, err)
+	}
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// get will fetch the manifest, answering a basic or bearer token auth challenge with the pull secret credentials
+func (c *Client) get(ctx context.Context, manifestURL string, ref *Reference) (*http.Response, error) {
+	resp, err := c.do(ctx, manifestURL, "")
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	user, password, hasCredentials := c.pullSecret.Credentials(ref.Registry)
+	challenge := resp.Header.Get("WWW-Authenticate")
+
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !hasCredentials {
+			return nil, fmt.Errorf("%s requires credentials, add them to the pull secret", ref.Registry)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create request: %w", err)
+		}
+		req.SetBasicAuth(user, password)
+
+		return c.send(req)
+	case "bearer":
+		token, err := c.token(ctx, parseChallenge(params), ref, user, password, hasCredentials)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.do(ctx, manifestURL, "Bearer "+token)
+	}
+
+	return nil, fmt.Errorf("%s asked for unsupported authentication '%s'", ref.Registry, challenge)
+}
+
+// token will get a pull token from the realm of a bearer challenge
+func (c *Client) token(ctx context.Context, challenge map[string]string, ref *Reference, user string, password string, hasCredentials bool) (string, error) {
+	realm, err := url.Parse(challenge["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("%s sent an invalid token realm '%s'", ref.Registry, challenge["realm"])
+	}
+
+	query := realm.Query()
+	if service := challenge["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", ref.Repository))
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to create request: %w", err)
+	}
+
+	if hasCredentials {
+		req.SetBasicAuth(user, password)
+	}
+
+	resp, err := c.send(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to get a pull token from %s: %s", realm.Host, resp.Status)
+	}
+
+	body := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("unable to decode the pull token from %s: %w", realm.Host, err)
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+
+	return body.AccessToken, nil
+}
+
+func (c *Client) do(ctx context.Context, manifestURL string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	return c.send(req)
+}
+
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	if strings.Contains(req.URL.Path, "/manifests/") {
+		req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GET %s failed: %w", req.URL.Redacted(), err)
+	}
+
+	return resp, nil
+}
+
+// parseChallenge will read the key="value" parameters of a WWW-Authenticate header
+func parseChallenge(params string) map[string]string {
+	parsed := map[string]string{}
+	for _, param := range strings.Split(params, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+		if found {
+			parsed[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+
+	return parsed
+}
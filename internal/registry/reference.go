@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	DEFAULT_REGISTRY = "docker.io"
+	DEFAULT_TAG      = "latest"
+)
+
+// digestRegex matches the only digest registries serve releases by, a hex encoded sha256
+var digestRegex = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// Reference is a parsed image pull spec, ex: quay.io/openshift-release-dev/ocp-release:4.15.1-x86_64
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference will split an image pull spec into its parts. Images without a registry are on docker.io
+func ParseReference(image string) (*Reference, error) {
+	if image == "" || strings.ContainsAny(image, " \t") {
+		return nil, fmt.Errorf("invalid image reference '%s'", image)
+	}
+
+	ref := &Reference{}
+	name := image
+
+	if before, digest, found := strings.Cut(name, "@"); found {
+		if !strings.HasPrefix(digest, "sha256:") {
+			return nil, fmt.Errorf("invalid image reference '%s': unsupported digest", image)
+		}
+		if !digestRegex.MatchString(digest) {
+			return nil, fmt.Errorf("invalid image reference '%s': digest is not 64 lowercase hex characters", image)
+		}
+		name, ref.Digest = before, digest
+	}
+
+	// A colon after the last slash is the tag, one before it is the registry port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+	}
+
+	first, rest, found := strings.Cut(name, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry, ref.Repository = first, rest
+	} else {
+		ref.Registry, ref.Repository = DEFAULT_REGISTRY, name
+		if !found {
+			ref.Repository = "library/" + name
+		}
+	}
+
+	if ref.Repository == "" {
+		return nil, fmt.Errorf("invalid image reference '%s': no repository", image)
+	}
+
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = DEFAULT_TAG
+	}
+
+	return ref, nil
+}
+
+// Name is the registry and repository, without the tag or digest
+func (r *Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+func (r *Reference) String() string {
+	if r.Digest != "" {
+		return r.Name() + "@" + r.Digest
+	}
+
+	return r.Name() + ":" + r.Tag
+}
+
+// WithDigest will return the reference pinned to the digest, without its tag
+func (r *Reference) WithDigest(digest string) *Reference {
+	return &Reference{Registry: r.Registry, Repository: r.Repository, Digest: digest}
+}
@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("0f", 32)
+
+	tests := []struct {
+		name       string
+		image      string
+		want       Reference
+		wantString string
+		wantErr    string
+	}{
+		{
+			name:       "registry with a port and a tag",
+			image:      "host:5000/repo:tag",
+			want:       Reference{Registry: "host:5000", Repository: "repo", Tag: "tag"},
+			wantString: "host:5000/repo:tag",
+		},
+		{
+			name:       "registry with a port and no tag",
+			image:      "host:5000/ocp/release",
+			want:       Reference{Registry: "host:5000", Repository: "ocp/release", Tag: DEFAULT_TAG},
+			wantString: "host:5000/ocp/release:latest",
+		},
+		{
+			name:       "nested repository",
+			image:      "quay.io/openshift-release-dev/ocp-release:4.15.1-x86_64",
+			want:       Reference{Registry: "quay.io", Repository: "openshift-release-dev/ocp-release", Tag: "4.15.1-x86_64"},
+			wantString: "quay.io/openshift-release-dev/ocp-release:4.15.1-x86_64",
+		},
+		{
+			name:       "digest",
+			image:      "repo@" + digest,
+			want:       Reference{Registry: DEFAULT_REGISTRY, Repository: "library/repo", Digest: digest},
+			wantString: "docker.io/library/repo@" + digest,
+		},
+		{
+			name:       "tag and digest",
+			image:      "quay.io/ocp/release:4.15.1@" + digest,
+			want:       Reference{Registry: "quay.io", Repository: "ocp/release", Tag: "4.15.1", Digest: digest},
+			wantString: "quay.io/ocp/release@" + digest,
+		},
+		{
+			name:       "localhost",
+			image:      "localhost/repo",
+			want:       Reference{Registry: "localhost", Repository: "repo", Tag: DEFAULT_TAG},
+			wantString: "localhost/repo:latest",
+		},
+		{
+			name:       "bare repository",
+			image:      "repo",
+			want:       Reference{Registry: DEFAULT_REGISTRY, Repository: "library/repo", Tag: DEFAULT_TAG},
+			wantString: "docker.io/library/repo:latest",
+		},
+		{
+			name:       "docker hub user repository",
+			image:      "user/repo:1.0",
+			want:       Reference{Registry: DEFAULT_REGISTRY, Repository: "user/repo", Tag: "1.0"},
+			wantString: "docker.io/user/repo:1.0",
+		},
+		{
+			name:    "unsupported digest algorithm",
+			image:   "repo@sha512:" + strings.Repeat("0f", 64),
+			wantErr: "unsupported digest",
+		},
+		{
+			name:    "short digest",
+			image:   "repo@sha256:1234",
+			wantErr: "digest is not 64 lowercase hex characters",
+		},
+		{
+			name:    "digest that is not hex",
+			image:   "repo@sha256:" + strings.Repeat("zz", 32),
+			wantErr: "digest is not 64 lowercase hex characters",
+		},
+		{
+			name:    "uppercase digest",
+			image:   "repo@" + strings.ToUpper(digest),
+			wantErr: "unsupported digest",
+		},
+		{
+			name:    "no repository",
+			image:   "quay.io/",
+			wantErr: "no repository",
+		},
+		{
+			name:    "empty",
+			image:   "",
+			wantErr: "invalid image reference",
+		},
+		{
+			name:    "whitespace",
+			image:   "quay.io/ocp release",
+			wantErr: "invalid image reference",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReference(tt.image)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseReference() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseReference() error = %v", err)
+			}
+
+			if *got != tt.want {
+				t.Errorf("ParseReference(%s) = %+v, want %+v", tt.image, *got, tt.want)
+			}
+			if got.String() != tt.wantString {
+				t.Errorf("String() = %s, want %s", got, tt.wantString)
+			}
+		})
+	}
+}
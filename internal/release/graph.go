@@ -0,0 +1,94 @@
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CHANNELS_METADATA is the node metadata listing the channels a release is in
+const CHANNELS_METADATA = "io.openshift.upgrades.graph.release.channels"
+
+// channelRegex matches the update channels, ex: stable-4.15
+var channelRegex = regexp.MustCompile(`^(stable|fast|candidate|eus)-(\d+)\.(\d+)$`)
+
+// Graph is an upgrade graph as the update service returns it, ex:
+// https://api.openshift.com/api/upgrades_info/v1/graph?channel=stable-4.15&arch=amd64
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+}
+
+// Node is a release in the upgrade graph, the payload is usually pinned by digest
+type Node struct {
+	Version  string            `json:"version"`
+	Payload  string            `json:"payload"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// IsChannel will return true if the name is an update channel, ex: stable-4.15
+func IsChannel(name string) bool {
+	return channelRegex.MatchString(name)
+}
+
+// LoadGraph will read an upgrade graph JSON file
+func LoadGraph(path string) (*Graph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read upgrade graph '%s': %w", path, err)
+	}
+
+	graph := &Graph{}
+	if err := json.Unmarshal(data, graph); err != nil {
+		return nil, fmt.Errorf("unable to parse upgrade graph '%s': %w", path, err)
+	}
+
+	return graph, nil
+}
+
+// Latest will return the newest release of the channel minor version. Nodes without channel metadata are
+// assumed to be in the channel, as the graph of a single channel does not always carry it
+func (g *Graph) Latest(channel string) (*Node, error) {
+	matches := channelRegex.FindStringSubmatch(channel)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid channel '%s', expected stable, fast, candidate or eus and a minor version, ex: stable-4.15", channel)
+	}
+	major, _ := strconv.ParseUint(matches[2], 10, 64)
+	minor, _ := strconv.ParseUint(matches[3], 10, 64)
+
+	var latest *Node
+	var latestVersion *Version
+	for i := range g.Nodes {
+		node := &g.Nodes[i]
+		version, err := ParseVersion(node.Version)
+		if err != nil || version.Major != major || version.Minor != minor {
+			continue
+		}
+
+		if channels, ok := node.Metadata[CHANNELS_METADATA]; ok && !inList(channels, channel) {
+			continue
+		}
+
+		if latestVersion == nil || version.Compare(latestVersion) > 0 {
+			latest, latestVersion = node, version
+		}
+	}
+
+	if latest == nil {
+		return nil, fmt.Errorf("no %d.%d release found in channel '%s' of the upgrade graph", major, minor, channel)
+	}
+
+	return latest, nil
+}
+
+func inList(list string, name string) bool {
+	for _, entry := range strings.Split(list, ",") {
+		if strings.TrimSpace(entry) == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package release
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphLatest(t *testing.T) {
+	inChannels := func(channels string) map[string]string {
+		return map[string]string{CHANNELS_METADATA: channels}
+	}
+
+	tests := []struct {
+		name    string
+		nodes   []Node
+		channel string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "newest of the minor version",
+			nodes: []Node{
+				{Version: "4.15.2"},
+				{Version: "4.15.10"},
+				{Version: "4.16.1"},
+				{Version: "4.14.30"},
+			},
+			channel: "stable-4.15",
+			want:    "4.15.10",
+		},
+		{
+			name: "nodes outside the channel are skipped",
+			nodes: []Node{
+				{Version: "4.15.2", Metadata: inChannels("candidate-4.15,fast-4.15,stable-4.15")},
+				{Version: "4.15.3", Metadata: inChannels("candidate-4.15, fast-4.15")},
+			},
+			channel: "stable-4.15",
+			want:    "4.15.2",
+		},
+		{
+			name: "channel names are matched whole",
+			nodes: []Node{
+				{Version: "4.15.2", Metadata: inChannels("stable-4.15")},
+				{Version: "4.15.3", Metadata: inChannels("stable-4.150")},
+			},
+			channel: "stable-4.15",
+			want:    "4.15.2",
+		},
+		{
+			name: "nodes without metadata are in the channel",
+			nodes: []Node{
+				{Version: "4.15.2", Metadata: inChannels("stable-4.15")},
+				{Version: "4.15.3"},
+				{Version: "4.15.4", Metadata: map[string]string{"url": "https://example.com"}},
+			},
+			channel: "stable-4.15",
+			want:    "4.15.4",
+		},
+		{
+			name: "release is newer than its candidates",
+			nodes: []Node{
+				{Version: "4.16.0-rc.3"},
+				{Version: "4.16.0"},
+				{Version: "4.16.0-rc.2"},
+			},
+			channel: "candidate-4.16",
+			want:    "4.16.0",
+		},
+		{
+			name: "invalid versions are skipped",
+			nodes: []Node{
+				{Version: "4.15"},
+				{Version: "4.15.1"},
+			},
+			channel: "eus-4.15",
+			want:    "4.15.1",
+		},
+		{
+			name: "no release in the channel",
+			nodes: []Node{
+				{Version: "4.15.2", Metadata: inChannels("fast-4.15")},
+				{Version: "4.14.2"},
+			},
+			channel: "stable-4.15",
+			wantErr: "no 4.15 release found in channel 'stable-4.15'",
+		},
+		{
+			name:    "invalid channel",
+			nodes:   []Node{{Version: "4.15.2"}},
+			channel: "latest-4.15",
+			wantErr: "invalid channel 'latest-4.15'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := &Graph{Nodes: tt.nodes}
+
+			got, err := graph.Latest(tt.channel)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Latest() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Latest() error = %v", err)
+			}
+
+			if got.Version != tt.want {
+				t.Errorf("Latest(%s) = %s, want %s", tt.channel, got.Version, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package release
+
+import (
+	"fmt"
+	"snoman/internal/arch"
+	"snoman/internal/registry"
+	"strings"
+)
+
+const (
+	DEFAULT_RELEASE_REGISTRY = "quay.io/openshift-release-dev/ocp-release"
+	// DEFAULT_NIGHTLY_REGISTRY has a repository per architecture, ex: ocp-arm64/release-arm64
+	DEFAULT_NIGHTLY_REGISTRY = "registry.ci.openshift.org"
+)
+
+// The kinds of release requests
+const (
+	KIND_VERSION   = "version"
+	KIND_CHANNEL   = "channel"
+	KIND_NIGHTLY   = "nightly"
+	KIND_PULL_SPEC = "pull-spec"
+)
+
+// Resolver turns a release request into the pull spec of its release image
+type Resolver struct {
+	// Arch is the release architecture, either name is accepted
+	Arch string
+	// Registry is the repository GA versions are pulled from, ex: mirror.lab:5000/ocp/release
+	Registry string
+	// NightlyRegistry is the repository nightly payloads are pulled from, the CI registry repository of
+	// the architecture is used when empty
+	NightlyRegistry string
+	// GraphPath is the upgrade graph JSON file channels are resolved from
+	GraphPath string
+}
+
+// Release is a resolved release request
+type Release struct {
+	Request string `yaml:"request"`
+	Kind    string `yaml:"kind"`
+	Channel string `yaml:"channel,omitempty"`
+	// Version is empty when a pull spec was requested, the image has to be inspected to know it
+	Version string `yaml:"version,omitempty"`
+	Image   string `yaml:"image"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+// Resolve will find the release image of a version (4.15.1), a channel (stable-4.15), a nightly tag
+// (4.15.0-0.nightly-2024-03-01-123456) or a pull spec, which may be pinned by digest. Channels need the
+// upgrade graph, nothing else is looked up so the image may still have to be pinned
+func (r *Resolver) Resolve(request string) (*Release, error) {
+	request = strings.TrimSpace(request)
+	if request == "" {
+		return nil, fmt.Errorf("a release version, channel or pull spec is required")
+	}
+
+	releaseArch, err := arch.Normalize(r.Arch)
+	if err != nil {
+		return nil, err
+	}
+	if releaseArch == "" {
+		releaseArch = arch.X86_64
+	}
+
+	release := &Release{Request: request}
+	switch {
+	case strings.Contains(request, "/"):
+		release.Kind = KIND_PULL_SPEC
+		release.Image = request
+	case IsChannel(request):
+		if r.GraphPath == "" {
+			return nil, fmt.Errorf("an upgrade graph file is required to resolve channel '%s'", request)
+		}
+
+		graph, err := LoadGraph(r.GraphPath)
+		if err != nil {
+			return nil, err
+		}
+
+		node, err := graph.Latest(request)
+		if err != nil {
+			return nil, err
+		}
+
+		release.Kind = KIND_CHANNEL
+		release.Channel = request
+		release.Version = node.Version
+		release.Image = node.Payload
+	default:
+		version, err := ParseVersion(request)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve release '%s', expected a version, a channel like stable-4.15 or a pull spec: %w", request, err)
+		}
+
+		release.Version = version.String()
+		if version.IsNightly() {
+			release.Kind = KIND_NIGHTLY
+			release.Image = fmt.Sprintf("%s:%s", r.nightlyRegistry(releaseArch), release.Version)
+		} else {
+			release.Kind = KIND_VERSION
+			release.Image = fmt.Sprintf("%s:%s-%s", r.registry(), release.Version, releaseArch)
+		}
+	}
+
+	ref, err := registry.ParseReference(release.Image)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve release '%s': %w", request, err)
+	}
+	release.Digest = ref.Digest
+
+	return release, nil
+}
+
+func (r *Resolver) registry() string {
+	if r.Registry != "" {
+		return strings.TrimSuffix(r.Registry, "/")
+	}
+
+	return DEFAULT_RELEASE_REGISTRY
+}
+
+// nightlyRegistry is the CI repository of the architecture, ex: registry.ci.openshift.org/ocp-arm64/release-arm64
+func (r *Resolver) nightlyRegistry(releaseArch string) string {
+	if r.NightlyRegistry != "" {
+		return strings.TrimSuffix(r.NightlyRegistry, "/")
+	}
+
+	if releaseArch == arch.X86_64 {
+		return DEFAULT_NIGHTLY_REGISTRY + "/ocp/release"
+	}
+
+	goarch := arch.GoArch(releaseArch)
+	return fmt.Sprintf("%s/ocp-%s/release-%s", DEFAULT_NIGHTLY_REGISTRY, goarch, goarch)
+}
+
+// Pin will pin the release image to the digest
+func (release *Release) Pin(digest string) error {
+	ref, err := registry.ParseReference(release.Image)
+	if err != nil {
+		return err
+	}
+
+	release.Image = ref.WithDigest(digest).String()
+	release.Digest = digest
+
+	return nil
+}
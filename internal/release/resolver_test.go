@@ -0,0 +1,186 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("ab", 32)
+
+	graphPath := filepath.Join(t.TempDir(), "graph.json")
+	graph := `{"nodes": [
+		{"version": "4.15.2", "payload": "quay.io/openshift-release-dev/ocp-release@` + digest + `"},
+		{"version": "4.15.1", "payload": "quay.io/openshift-release-dev/ocp-release:4.15.1-x86_64"}
+	]}`
+	if err := os.WriteFile(graphPath, []byte(graph), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		resolver Resolver
+		request  string
+		want     Release
+		wantErr  string
+	}{
+		{
+			name:    "version",
+			request: "4.15.1",
+			want: Release{
+				Request: "4.15.1",
+				Kind:    KIND_VERSION,
+				Version: "4.15.1",
+				Image:   "quay.io/openshift-release-dev/ocp-release:4.15.1-x86_64",
+			},
+		},
+		{
+			name:     "version of another architecture",
+			resolver: Resolver{Arch: "arm64"},
+			request:  " 4.15.1 ",
+			want: Release{
+				Request: "4.15.1",
+				Kind:    KIND_VERSION,
+				Version: "4.15.1",
+				Image:   "quay.io/openshift-release-dev/ocp-release:4.15.1-aarch64",
+			},
+		},
+		{
+			name:     "registry override",
+			resolver: Resolver{Registry: "mirror.lab:5000/ocp/release/"},
+			request:  "4.15.1",
+			want: Release{
+				Request: "4.15.1",
+				Kind:    KIND_VERSION,
+				Version: "4.15.1",
+				Image:   "mirror.lab:5000/ocp/release:4.15.1-x86_64",
+			},
+		},
+		{
+			name:    "nightly",
+			request: "4.15.0-0.nightly-2024-03-01-123456",
+			want: Release{
+				Request: "4.15.0-0.nightly-2024-03-01-123456",
+				Kind:    KIND_NIGHTLY,
+				Version: "4.15.0-0.nightly-2024-03-01-123456",
+				Image:   "registry.ci.openshift.org/ocp/release:4.15.0-0.nightly-2024-03-01-123456",
+			},
+		},
+		{
+			name:     "nightly of another architecture",
+			resolver: Resolver{Arch: "aarch64"},
+			request:  "4.15.0-0.nightly-2024-03-01-123456",
+			want: Release{
+				Request: "4.15.0-0.nightly-2024-03-01-123456",
+				Kind:    KIND_NIGHTLY,
+				Version: "4.15.0-0.nightly-2024-03-01-123456",
+				Image:   "registry.ci.openshift.org/ocp-arm64/release-arm64:4.15.0-0.nightly-2024-03-01-123456",
+			},
+		},
+		{
+			name:     "nightly registry override",
+			resolver: Resolver{Registry: "mirror.lab:5000/ocp/release", NightlyRegistry: "mirror.lab:5000/ocp/nightly"},
+			request:  "4.15.0-0.nightly-2024-03-01-123456",
+			want: Release{
+				Request: "4.15.0-0.nightly-2024-03-01-123456",
+				Kind:    KIND_NIGHTLY,
+				Version: "4.15.0-0.nightly-2024-03-01-123456",
+				Image:   "mirror.lab:5000/ocp/nightly:4.15.0-0.nightly-2024-03-01-123456",
+			},
+		},
+		{
+			name:     "pull spec is used as is",
+			resolver: Resolver{Registry: "mirror.lab:5000/ocp/release"},
+			request:  "quay.io/openshift-release-dev/ocp-release:4.15.1-multi",
+			want: Release{
+				Request: "quay.io/openshift-release-dev/ocp-release:4.15.1-multi",
+				Kind:    KIND_PULL_SPEC,
+				Image:   "quay.io/openshift-release-dev/ocp-release:4.15.1-multi",
+			},
+		},
+		{
+			name:    "pull spec pinned by digest",
+			request: "quay.io/openshift-release-dev/ocp-release@" + digest,
+			want: Release{
+				Request: "quay.io/openshift-release-dev/ocp-release@" + digest,
+				Kind:    KIND_PULL_SPEC,
+				Image:   "quay.io/openshift-release-dev/ocp-release@" + digest,
+				Digest:  digest,
+			},
+		},
+		{
+			name:     "channel",
+			resolver: Resolver{GraphPath: graphPath},
+			request:  "stable-4.15",
+			want: Release{
+				Request: "stable-4.15",
+				Kind:    KIND_CHANNEL,
+				Channel: "stable-4.15",
+				Version: "4.15.2",
+				Image:   "quay.io/openshift-release-dev/ocp-release@" + digest,
+				Digest:  digest,
+			},
+		},
+		{
+			name:    "channel without an upgrade graph",
+			request: "stable-4.15",
+			wantErr: "an upgrade graph file is required",
+		},
+		{
+			name:    "pull spec with an unsupported digest",
+			request: "quay.io/openshift-release-dev/ocp-release@md5:1234",
+			wantErr: "unable to resolve release",
+		},
+		{
+			name:    "empty request",
+			request: " ",
+			wantErr: "a release version, channel or pull spec is required",
+		},
+		{
+			name:    "not a release",
+			request: "latest",
+			wantErr: "unable to resolve release 'latest'",
+		},
+		{
+			name:     "unknown architecture",
+			resolver: Resolver{Arch: "mips"},
+			request:  "4.15.1",
+			wantErr:  "mips",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.resolver.Resolve(tt.request)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			if *got != tt.want {
+				t.Errorf("Resolve(%s) = %+v, want %+v", tt.request, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPin(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("cd", 32)
+
+	release := &Release{Kind: KIND_VERSION, Version: "4.15.1", Image: "quay.io/openshift-release-dev/ocp-release:4.15.1-x86_64"}
+	if err := release.Pin(digest); err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+
+	want := "quay.io/openshift-release-dev/ocp-release@" + digest
+	if release.Image != want || release.Digest != digest {
+		t.Errorf("Pin() = %s, %s, want %s, %s", release.Image, release.Digest, want, digest)
+	}
+}
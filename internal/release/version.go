@@ -0,0 +1,74 @@
+package release
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is an OpenShift release version, ex: 4.15.1 or the nightly 4.15.0-0.nightly-2024-03-01-123456
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+}
+
+// ParseVersion will parse a semantic version, build metadata is ignored
+func ParseVersion(version string) (*Version, error) {
+	core, _, _ := strings.Cut(version, "+")
+	core, pre, _ := strings.Cut(core, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid version '%s', expected major.minor.patch", version)
+	}
+
+	numbers := make([]uint64, len(parts))
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version '%s': %w", version, err)
+		}
+		numbers[i] = n
+	}
+
+	return &Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2], Prerelease: pre}, nil
+}
+
+func (v *Version) String() string {
+	if v.Prerelease != "" {
+		return fmt.Sprintf("%d.%d.%d-%s", v.Major, v.Minor, v.Patch, v.Prerelease)
+	}
+
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// IsNightly will return true for the nightly and CI payloads, which are not published with the releases
+func (v *Version) IsNightly() bool {
+	return strings.Contains(v.Prerelease, "nightly") || strings.HasPrefix(v.Prerelease, "0.ci")
+}
+
+// Compare will return -1, 0 or 1 if v is older, the same or newer than other. Prereleases are older than
+// the release and are compared as strings, which orders the dated nightlies
+func (v *Version) Compare(other *Version) int {
+	for _, pair := range [][2]uint64{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case v.Prerelease == other.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case other.Prerelease == "":
+		return -1
+	}
+
+	return strings.Compare(v.Prerelease, other.Prerelease)
+}
@@ -0,0 +1,174 @@
+package release
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		want        Version
+		wantNightly bool
+		wantErr     string
+	}{
+		{
+			name:    "release",
+			version: "4.15.1",
+			want:    Version{Major: 4, Minor: 15, Patch: 1},
+		},
+		{
+			name:    "release candidate",
+			version: "4.16.0-rc.2",
+			want:    Version{Major: 4, Minor: 16, Patch: 0, Prerelease: "rc.2"},
+		},
+		{
+			name:        "nightly",
+			version:     "4.15.0-0.nightly-2024-03-01-123456",
+			want:        Version{Major: 4, Minor: 15, Patch: 0, Prerelease: "0.nightly-2024-03-01-123456"},
+			wantNightly: true,
+		},
+		{
+			name:        "ci payload",
+			version:     "4.15.0-0.ci-2024-03-01-123456",
+			want:        Version{Major: 4, Minor: 15, Patch: 0, Prerelease: "0.ci-2024-03-01-123456"},
+			wantNightly: true,
+		},
+		{
+			name:    "build metadata is ignored",
+			version: "4.15.1+build.5",
+			want:    Version{Major: 4, Minor: 15, Patch: 1},
+		},
+		{
+			name:    "missing patch",
+			version: "4.15",
+			wantErr: "expected major.minor.patch",
+		},
+		{
+			name:    "not a number",
+			version: "4.x.1",
+			wantErr: "invalid version '4.x.1'",
+		},
+		{
+			name:    "channel",
+			version: "stable-4.15",
+			wantErr: "expected major.minor.patch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVersion(tt.version)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseVersion() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVersion() error = %v", err)
+			}
+
+			if *got != tt.want {
+				t.Errorf("ParseVersion() = %+v, want %+v", *got, tt.want)
+			}
+			if got.IsNightly() != tt.wantNightly {
+				t.Errorf("IsNightly() = %v, want %v", got.IsNightly(), tt.wantNightly)
+			}
+			if got.String() != strings.Split(tt.version, "+")[0] {
+				t.Errorf("String() = %s, want %s", got, tt.version)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name  string
+		v     string
+		other string
+		want  int
+	}{
+		{
+			name:  "same release",
+			v:     "4.15.1",
+			other: "4.15.1",
+			want:  0,
+		},
+		{
+			name:  "older patch",
+			v:     "4.15.1",
+			other: "4.15.10",
+			want:  -1,
+		},
+		{
+			name:  "newer minor",
+			v:     "4.16.0",
+			other: "4.15.10",
+			want:  1,
+		},
+		{
+			name:  "newer major",
+			v:     "5.0.0",
+			other: "4.99.99",
+			want:  1,
+		},
+		{
+			name:  "prerelease is older than the release",
+			v:     "4.16.0-rc.2",
+			other: "4.16.0",
+			want:  -1,
+		},
+		{
+			name:  "release is newer than the prerelease",
+			v:     "4.16.0",
+			other: "4.16.0-ec.1",
+			want:  1,
+		},
+		{
+			name:  "release candidates",
+			v:     "4.16.0-rc.3",
+			other: "4.16.0-rc.2",
+			want:  1,
+		},
+		{
+			name:  "nightlies are ordered by date",
+			v:     "4.15.0-0.nightly-2024-03-01-123456",
+			other: "4.15.0-0.nightly-2024-02-28-235959",
+			want:  1,
+		},
+		{
+			name:  "nightly of the same day",
+			v:     "4.15.0-0.nightly-2024-03-01-010101",
+			other: "4.15.0-0.nightly-2024-03-01-123456",
+			want:  -1,
+		},
+		{
+			name:  "nightly is older than the release",
+			v:     "4.15.0-0.nightly-2024-03-01-123456",
+			other: "4.15.0",
+			want:  -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := ParseVersion(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := ParseVersion(tt.other)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := v.Compare(other); got != tt.want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", tt.v, tt.other, got, tt.want)
+			}
+			if got := other.Compare(v); got != -tt.want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", tt.other, tt.v, got, -tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"snoman/internal/logger"
+)
+
+// ExecRunner runs the commands on this host
+type ExecRunner struct{}
+
+func (r *ExecRunner) Run(ctx context.Context, cmd *Command) error {
+	log := logger.Get()
+	log.Infof("running %s", cmd)
+
+	execCmd := exec.CommandContext(ctx, cmd.Name, cmd.Args...)
+	execCmd.Env = append(os.Environ(), cmd.Env...)
+	execCmd.Stdout = outputWriter(cmd, logger.GetStdOutWriter(), cmd.Stdout)
+	execCmd.Stderr = outputWriter(cmd, logger.GetStdErrWriter(), cmd.Stderr)
+
+	err := execCmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Name: cmd.Name, Code: exitErr.ExitCode()}
+	} else if err != nil {
+		return fmt.Errorf("unable to run %s: %w", cmd.Name, err)
+	}
+
+	log.Debugf("%s exited successfully", cmd.Name)
+
+	return nil
+}
+
+// outputWriter will return where an output stream of the command goes, the logger is skipped for quiet commands
+func outputWriter(cmd *Command, logw io.Writer, extra io.Writer) io.Writer {
+	if !cmd.Quiet {
+		return teeWriter(logw, extra)
+	}
+
+	if extra == nil {
+		return io.Discard
+	}
+
+	return extra
+}
+
+// teeWriter will write to the logger and the optional extra writer
+func teeWriter(logw io.Writer, extra io.Writer) io.Writer {
+	if extra == nil {
+		return logw
+	}
+
+	return io.MultiWriter(logw, extra)
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"snoman/internal/logger"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Invocation is one recorded command. Arguments and env are stored redacted
+type Invocation struct {
+	Name     string   `yaml:"name"`
+	Args     []string `yaml:"args,omitempty"`
+	Env      []string `yaml:"env,omitempty"`
+	Stdout   string   `yaml:"stdout,omitempty"`
+	Stderr   string   `yaml:"stderr,omitempty"`
+	ExitCode int      `yaml:"exit_code"`
+	// Error is set when the command could not be run at all
+	Error string `yaml:"error,omitempty"`
+}
+
+type Recording struct {
+	Invocations []Invocation `yaml:"invocations"`
+}
+
+func newInvocation(cmd *Command) Invocation {
+	return Invocation{
+		Name: cmd.Name,
+		Args: RedactArgs(cmd.Args),
+		Env:  RedactEnv(cmd.Env),
+	}
+}
+
+// matches will return true if the recorded invocation is for the same command
+func (inv Invocation) matches(cmd *Command) bool {
+	other := newInvocation(cmd)
+
+	return inv.Name == other.Name && slices.Equal(inv.Args, other.Args) && slices.Equal(inv.Env, other.Env)
+}
+
+// LoadRecording will read a recording written by a Recorder
+func LoadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read command recording '%s': %w", path, err)
+	}
+
+	rec := &Recording{}
+	if err := yaml.Unmarshal(data, rec); err != nil {
+		return nil, fmt.Errorf("unable to parse command recording '%s': %w", path, err)
+	}
+
+	return rec, nil
+}
+
+// Recorder runs every command with the wrapped runner and saves the invocations and their output to a file
+type Recorder struct {
+	runner Runner
+	path   string
+	mux    sync.Mutex
+	rec    Recording
+}
+
+func NewRecorder(runner Runner, path string) *Recorder {
+	return &Recorder{runner: runner, path: path}
+}
+
+func (r *Recorder) Run(ctx context.Context, cmd *Command) error {
+	var stdout, stderr bytes.Buffer
+
+	// Capture the output without hiding it from the caller
+	captured := *cmd
+	captured.Stdout = teeWriter(&stdout, cmd.Stdout)
+	captured.Stderr = teeWriter(&stderr, cmd.Stderr)
+
+	err := r.runner.Run(ctx, &captured)
+
+	inv := newInvocation(cmd)
+	inv.Stdout = stdout.String()
+	inv.Stderr = stderr.String()
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		inv.ExitCode = exitErr.Code
+	} else if err != nil {
+		inv.Error = err.Error()
+	}
+
+	// Save after every command so the recording survives snoman exiting early
+	if saveErr := r.append(inv); saveErr != nil {
+		logger.Get().Warnf("unable to save command recording: %v", saveErr)
+	}
+
+	return err
+}
+
+func (r *Recorder) append(inv Invocation) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.rec.Invocations = append(r.rec.Invocations, inv)
+
+	data, err := yaml.Marshal(r.rec)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(r.path, data, 0600)
+}
+
+// Replayer answers commands from a recording instead of running them. Commands must be replayed in the
+// order they were recorded
+type Replayer struct {
+	mux  sync.Mutex
+	rec  *Recording
+	next int
+}
+
+func NewReplayer(rec *Recording) *Replayer {
+	return &Replayer{rec: rec}
+}
+
+func (r *Replayer) Run(ctx context.Context, cmd *Command) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	log := logger.Get()
+	log.Infof("replaying %s", cmd)
+
+	if r.next >= len(r.rec.Invocations) {
+		return fmt.Errorf("unexpected command %s: the recording has no more invocations", cmd)
+	}
+
+	inv := r.rec.Invocations[r.next]
+	if !inv.matches(cmd) {
+		expected := &Command{Name: inv.Name, Args: inv.Args, Env: inv.Env}
+		return fmt.Errorf("unexpected command %s: the recording expected %s", cmd, expected)
+	}
+	r.next++
+
+	if inv.Stdout != "" {
+		outputWriter(cmd, logger.GetStdOutWriter(), cmd.Stdout).Write([]byte(inv.Stdout))
+	}
+
+	if inv.Stderr != "" {
+		outputWriter(cmd, logger.GetStdErrWriter(), cmd.Stderr).Write([]byte(inv.Stderr))
+	}
+
+	if inv.Error != "" {
+		return fmt.Errorf("unable to run %s: %s", cmd.Name, inv.Error)
+	}
+
+	if inv.ExitCode != 0 {
+		return &ExitError{Name: cmd.Name, Code: inv.ExitCode}
+	}
+
+	return nil
+}
+
+// Remaining will return how many recorded invocations were not replayed
+func (r *Replayer) Remaining() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return len(r.rec.Invocations) - r.next
+}
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const REDACTED = "<redacted>"
+
+// sensitiveName matches flag and env variable names whose values must never be logged or recorded
+var sensitiveName = regexp.MustCompile(`(?i)(password|passwd|secret|token|api[-_]?key|credential|auth)`)
+
+// RedactArgs will hide the values of sensitive flags, both --flag=value and --flag value forms
+func RedactArgs(args []string) []string {
+	redacted := make([]string, 0, len(args))
+
+	hideNext := false
+	for _, arg := range args {
+		switch {
+		case hideNext:
+			redacted = append(redacted, REDACTED)
+			hideNext = false
+		case strings.HasPrefix(arg, "-") && strings.Contains(arg, "="):
+			name, _, _ := strings.Cut(arg, "=")
+			if sensitiveName.MatchString(name) {
+				arg = name + "=" + REDACTED
+			}
+			redacted = append(redacted, arg)
+		case strings.HasPrefix(arg, "-"):
+			hideNext = sensitiveName.MatchString(arg)
+			redacted = append(redacted, arg)
+		default:
+			redacted = append(redacted, arg)
+		}
+	}
+
+	return redacted
+}
+
+// RedactEnv will hide the values of sensitive KEY=VALUE entries and the passwords of URL values, ex: proxies
+func RedactEnv(env []string) []string {
+	redacted := make([]string, 0, len(env))
+
+	for _, entry := range env {
+		name, value, _ := strings.Cut(entry, "=")
+		if sensitiveName.MatchString(name) {
+			entry = name + "=" + REDACTED
+		} else if u, err := url.Parse(value); err == nil && u.User != nil {
+			entry = name + "=" + u.Redacted()
+		}
+
+		redacted = append(redacted, entry)
+	}
+
+	return redacted
+}
@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Command is a single invocation of an external tool
+type Command struct {
+	Name string
+	Args []string
+	// Env is added to the environment of snoman, entries are KEY=VALUE
+	Env []string
+	// Stdout and Stderr receive the output in addition to the logger, they are optional
+	Stdout io.Writer
+	Stderr io.Writer
+	// Quiet stops the output being logged line by line, for callers that log it themselves from Stdout and Stderr
+	Quiet bool
+}
+
+func NewCommand(name string, args ...string) *Command {
+	return &Command{Name: name, Args: args}
+}
+
+// String will render the command with secrets redacted so it is safe to log
+func (c *Command) String() string {
+	parts := append(RedactEnv(c.Env), c.Name)
+	parts = append(parts, RedactArgs(c.Args)...)
+
+	return strings.Join(parts, " ")
+}
+
+// Runner runs external tools (openshift-install, systemctl, virt-install, ...) for snoman
+type Runner interface {
+	// Run will block until the command exits. A non zero exit is returned as an *ExitError
+	Run(ctx context.Context, cmd *Command) error
+}
+
+// ExitError is returned when the command ran but exited with a non zero code
+type ExitError struct {
+	Name string
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("%s exited with code %d", e.Name, e.Code)
+}
+
+var (
+	defaultRunner Runner
+	rmux          sync.Mutex
+)
+
+// Get will return the shared runner, running commands on this host unless another runner was Set
+// This is thread safe
+func Get() Runner {
+	rmux.Lock()
+	defer rmux.Unlock()
+
+	if defaultRunner == nil {
+		defaultRunner = &ExecRunner{}
+	}
+
+	return defaultRunner
+}
+
+// Set will replace the shared runner, e.g. with a Recorder or Replayer
+// This is thread safe
+func Set(r Runner) {
+	rmux.Lock()
+	defer rmux.Unlock()
+
+	defaultRunner = r
+}
+
+// Run will run the command with the shared runner
+func Run(ctx context.Context, cmd *Command) error {
+	return Get().Run(ctx, cmd)
+}
@@ -0,0 +1,61 @@
+package targets
+
+import (
+	"context"
+	"fmt"
+	"snoman/internal/pxe"
+	"snoman/internal/vms/machines"
+	"snoman/internal/vms/network"
+	vmutils "snoman/internal/vms/utils"
+)
+
+// VirtualMachinePxeProvider network boots a local libvirt virtual machine from the agent PXE artifacts,
+// which are served from the network gateway for as long as the target is in use
+type VirtualMachinePxeProvider struct {
+	*VirtualMachineProvider
+	ipxeScript string
+	httpPort   uint
+	tftpPort   uint
+}
+
+// NewVirtualMachinePxeProvider will boot the VM from ipxeScript. A tftpPort of 0 points DHCP at the script
+// over HTTP, otherwise the script is served over TFTP as well and DHCP points at that
+func NewVirtualMachinePxeProvider(spec *machines.VirtualMachineSpec, ipxeScript string, httpPort uint, tftpPort uint) *VirtualMachinePxeProvider {
+	return &VirtualMachinePxeProvider{
+		VirtualMachineProvider: NewVirtualMachineProvider(spec),
+		ipxeScript:             ipxeScript,
+		httpPort:               httpPort,
+		tftpPort:               tftpPort,
+	}
+}
+
+func (p *VirtualMachinePxeProvider) Name() string {
+	return fmt.Sprintf("libvirt vm '%s' over pxe", p.spec.Name)
+}
+
+// Boot will create the virtual machine network, serve the artifacts in artifactsDir on it and network boot the VM
+func (p *VirtualMachinePxeProvider) Boot(ctx context.Context, artifactsDir string) error {
+	if p.spec.Network == nil {
+		return fmt.Errorf("a network is required to pxe boot the virtual machine")
+	}
+
+	// The artifacts are served from this host, which is only on the VM network when libvirt is local
+	if vmutils.IsRemoteLibvirt() {
+		return fmt.Errorf("pxe boot is not supported on remote hypervisor %s", vmutils.GetLibvirtURI())
+	}
+
+	gateway := p.spec.Network.GatewayIP()
+	p.spec.PxeBoot = true
+	if p.tftpPort != 0 {
+		p.spec.Network.BootFile = p.ipxeScript
+		p.spec.Network.BootServer = gateway
+	} else {
+		p.spec.Network.BootFile = fmt.Sprintf("%s/%s", pxe.HttpBaseURL(gateway, p.httpPort), p.ipxeScript)
+	}
+
+	// The server binds the gateway address, which only exists once the network has been created
+	host := network.ServiceAddress(p.spec.Network)
+	p.AddNetworkService(pxe.NewServer(artifactsDir, p.ipxeScript, host, p.httpPort, p.tftpPort))
+
+	return p.boot(ctx)
+}
@@ -0,0 +1,265 @@
+package redfish
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const redfishRoot = "/redfish/v1"
+
+var ErrNoVirtualMedia = fmt.Errorf("no CD/DVD virtual media device found on the BMC")
+
+// Client is a minimal Redfish client that only knows what is needed to boot a system from virtual media
+type Client struct {
+	base     *url.URL
+	username string
+	password string
+	http     *http.Client
+}
+
+func NewClient(spec *RedfishSpec) (*Client, error) {
+	base, err := url.Parse(spec.BmcAddress)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse bmc address '%s': %w", spec.BmcAddress, err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: spec.InsecureSkipVerify}
+
+	return &Client{
+		base:     base,
+		username: spec.Username,
+		password: spec.Password,
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   time.Duration(spec.RequestTimeoutSecs) * time.Second,
+		},
+	}, nil
+}
+
+// FindSystem will return the system with the given id, or the only system on the BMC if id is empty
+func (c *Client) FindSystem(ctx context.Context, id string) (*computerSystem, string, error) {
+	systems := &collection{}
+	if err := c.get(ctx, redfishRoot+"/Systems", systems); err != nil {
+		return nil, "", fmt.Errorf("unable to list systems: %w", err)
+	}
+
+	if len(systems.Members) == 0 {
+		return nil, "", fmt.Errorf("the BMC did not report any systems")
+	}
+
+	if id == "" && len(systems.Members) > 1 {
+		return nil, "", fmt.Errorf("the BMC manages %d systems, a system_id is required", len(systems.Members))
+	}
+
+	for _, member := range systems.Members {
+		system := &computerSystem{}
+		if err := c.get(ctx, member.ID, system); err != nil {
+			return nil, "", fmt.Errorf("unable to get system '%s': %w", member.ID, err)
+		}
+
+		if id == "" || system.ID == id {
+			return system, member.ID, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("could not find system with id '%s'", id)
+}
+
+// FindVirtualCD will look for a virtual media device that can hold a CD or DVD for the system
+func (c *Client) FindVirtualCD(ctx context.Context, system *computerSystem) (*virtualMedia, string, error) {
+	collections := []string{}
+	if system.VirtualMedia.ID != "" {
+		collections = append(collections, system.VirtualMedia.ID)
+	}
+
+	for _, m := range system.Links.ManagedBy {
+		mgr := &manager{}
+		if err := c.get(ctx, m.ID, mgr); err != nil {
+			return nil, "", fmt.Errorf("unable to get manager '%s': %w", m.ID, err)
+		}
+
+		if mgr.VirtualMedia.ID != "" {
+			collections = append(collections, mgr.VirtualMedia.ID)
+		}
+	}
+
+	for _, path := range collections {
+		media := &collection{}
+		if err := c.get(ctx, path, media); err != nil {
+			return nil, "", fmt.Errorf("unable to list virtual media: %w", err)
+		}
+
+		for _, member := range media.Members {
+			vm := &virtualMedia{}
+			if err := c.get(ctx, member.ID, vm); err != nil {
+				return nil, "", fmt.Errorf("unable to get virtual media '%s': %w", member.ID, err)
+			}
+
+			if slices.Contains(vm.MediaTypes, "CD") || slices.Contains(vm.MediaTypes, "DVD") {
+				return vm, member.ID, nil
+			}
+		}
+	}
+
+	return nil, "", ErrNoVirtualMedia
+}
+
+// InsertMedia will eject anything in the virtual media device and insert the image at imageURL
+func (c *Client) InsertMedia(ctx context.Context, vm *virtualMedia, path string, imageURL string) error {
+	if vm.Inserted {
+		if err := c.EjectMedia(ctx, vm, path); err != nil {
+			return err
+		}
+	}
+
+	target := vm.Actions.Insert.Target
+	if target == "" {
+		target = path + "/Actions/VirtualMedia.InsertMedia"
+	}
+
+	body := map[string]interface{}{
+		"Image":          imageURL,
+		"Inserted":       true,
+		"WriteProtected": true,
+	}
+
+	if err := c.post(ctx, target, body); err != nil {
+		return fmt.Errorf("unable to insert virtual media: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) EjectMedia(ctx context.Context, vm *virtualMedia, path string) error {
+	target := vm.Actions.Eject.Target
+	if target == "" {
+		target = path + "/Actions/VirtualMedia.EjectMedia"
+	}
+
+	if err := c.post(ctx, target, map[string]interface{}{}); err != nil {
+		return fmt.Errorf("unable to eject virtual media: %w", err)
+	}
+
+	return nil
+}
+
+// SetOneTimeBoot will make the system boot from the target device on its next boot only
+func (c *Client) SetOneTimeBoot(ctx context.Context, systemPath string, target string) error {
+	body := map[string]interface{}{
+		"Boot": map[string]string{
+			"BootSourceOverrideTarget":  target,
+			"BootSourceOverrideEnabled": "Once",
+		},
+	}
+
+	if err := c.patch(ctx, systemPath, body); err != nil {
+		return fmt.Errorf("unable to set boot override to '%s': %w", target, err)
+	}
+
+	return nil
+}
+
+// Reset will run the ComputerSystem.Reset action (On, ForceOff, ForceRestart, ...)
+func (c *Client) Reset(ctx context.Context, system *computerSystem, systemPath string, resetType string) error {
+	target := system.Actions.Reset.Target
+	if target == "" {
+		target = systemPath + "/Actions/ComputerSystem.Reset"
+	}
+
+	if err := c.post(ctx, target, map[string]string{"ResetType": resetType}); err != nil {
+		return fmt.Errorf("unable to reset system with type '%s': %w", resetType, err)
+	}
+
+	return nil
+}
+
+// GetSystem will return the current state of the system, ex: its power state and boot override
+func (c *Client) GetSystem(ctx context.Context, systemPath string) (*computerSystem, error) {
+	system := &computerSystem{}
+	if err := c.get(ctx, systemPath, system); err != nil {
+		return nil, fmt.Errorf("unable to get system '%s': %w", systemPath, err)
+	}
+
+	return system, nil
+}
+
+func (c *Client) GetPowerState(ctx context.Context, systemPath string) (string, error) {
+	system, err := c.GetSystem(ctx, systemPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to get system power state: %w", err)
+	}
+
+	return system.PowerState, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+func (c *Client) post(ctx context.Context, path string, body interface{}) error {
+	return c.do(ctx, http.MethodPost, path, body, nil)
+}
+
+func (c *Client) patch(ctx context.Context, path string, body interface{}) error {
+	return c.do(ctx, http.MethodPatch, path, body, nil)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return fmt.Errorf("invalid redfish path '%s': %w", path, err)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("unable to encode request body: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base.ResolveReference(ref).String(), reqBody)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("unable to decode response from %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
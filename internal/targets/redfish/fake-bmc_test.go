@@ -0,0 +1,175 @@
+package redfish
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+const (
+	fakeUsername   = "admin"
+	fakePassword   = "secret"
+	fakeSystemPath = redfishRoot + "/Systems/1"
+	fakeManager    = redfishRoot + "/Managers/1"
+	fakeMediaPath  = fakeManager + "/VirtualMedia"
+	fakeCdPath     = fakeMediaPath + "/Cd"
+	fakeFloppyPath = fakeMediaPath + "/Floppy"
+)
+
+// fakeBMC is a Redfish stand-in with one system, managed by one manager that has a floppy and a CD
+// virtual media device
+type fakeBMC struct {
+	mux sync.Mutex
+
+	powerState   string
+	extraSystems int
+	noCd         bool
+	inserted     bool
+	image        string
+	bootTarget   string
+	bootEnabled  string
+	resets       []string
+	ejects       int
+	// polls change the system before it is returned, one for each time it is read
+	polls []func(b *fakeBMC)
+	// hang will block every request until the client gives up
+	hang bool
+}
+
+func newFakeBMC(t *testing.T, bmc *fakeBMC) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(bmc.serve))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (b *fakeBMC) setPolls(polls ...func(b *fakeBMC)) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.polls = polls
+}
+
+func (b *fakeBMC) remainingPolls() int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return len(b.polls)
+}
+
+// powerState is a poll that sets the power state of the system
+func powerState(state string) func(b *fakeBMC) {
+	return func(b *fakeBMC) { b.powerState = state }
+}
+
+// bootedOverride is a poll of the system booting from its one time boot override, which uses it up
+func bootedOverride(b *fakeBMC) {
+	b.bootEnabled = "Disabled"
+}
+
+func (b *fakeBMC) serve(w http.ResponseWriter, r *http.Request) {
+	if b.hang {
+		<-r.Context().Done()
+		return
+	}
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != fakeUsername || pass != fakePassword {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET " + redfishRoot + "/Systems":
+		members := []map[string]string{{"@odata.id": fakeSystemPath}}
+		for i := 0; i < b.extraSystems; i++ {
+			members = append(members, map[string]string{"@odata.id": fakeSystemPath})
+		}
+		writeJSON(w, map[string]interface{}{"Members": members})
+	case "GET " + fakeSystemPath:
+		if len(b.polls) != 0 {
+			b.polls[0](b)
+			b.polls = b.polls[1:]
+		}
+		writeJSON(w, map[string]interface{}{
+			"Id":         "1",
+			"PowerState": b.powerState,
+			"Boot":       map[string]string{"BootSourceOverrideEnabled": b.bootEnabled},
+			"Links":      map[string]interface{}{"ManagedBy": []map[string]string{{"@odata.id": fakeManager}}},
+			"Actions": map[string]interface{}{
+				"#ComputerSystem.Reset": map[string]string{"target": fakeSystemPath + "/Actions/ComputerSystem.Reset"},
+			},
+		})
+	case "PATCH " + fakeSystemPath:
+		body := struct {
+			Boot struct {
+				BootSourceOverrideTarget  string
+				BootSourceOverrideEnabled string
+			}
+		}{}
+		if !readJSON(w, r, &body) {
+			return
+		}
+		b.bootTarget = body.Boot.BootSourceOverrideTarget
+		b.bootEnabled = body.Boot.BootSourceOverrideEnabled
+		w.WriteHeader(http.StatusNoContent)
+	case "POST " + fakeSystemPath + "/Actions/ComputerSystem.Reset":
+		body := struct{ ResetType string }{}
+		if !readJSON(w, r, &body) {
+			return
+		}
+		b.resets = append(b.resets, body.ResetType)
+		b.powerState = POWER_STATE_ON
+		w.WriteHeader(http.StatusNoContent)
+	case "GET " + fakeManager:
+		writeJSON(w, map[string]interface{}{"Id": "1", "VirtualMedia": map[string]string{"@odata.id": fakeMediaPath}})
+	case "GET " + fakeMediaPath:
+		members := []map[string]string{{"@odata.id": fakeFloppyPath}}
+		if !b.noCd {
+			members = append(members, map[string]string{"@odata.id": fakeCdPath})
+		}
+		writeJSON(w, map[string]interface{}{"Members": members})
+	case "GET " + fakeFloppyPath:
+		writeJSON(w, map[string]interface{}{"Id": "Floppy", "MediaTypes": []string{"Floppy", "USBStick"}})
+	case "GET " + fakeCdPath:
+		writeJSON(w, map[string]interface{}{"Id": "Cd", "MediaTypes": []string{"CD", "DVD"}, "Inserted": b.inserted, "Image": b.image})
+	case "POST " + fakeCdPath + "/Actions/VirtualMedia.InsertMedia":
+		body := struct {
+			Image    string
+			Inserted bool
+		}{}
+		if !readJSON(w, r, &body) {
+			return
+		}
+		if b.inserted {
+			http.Error(w, "media already inserted", http.StatusConflict)
+			return
+		}
+		b.inserted, b.image = body.Inserted, body.Image
+		w.WriteHeader(http.StatusNoContent)
+	case "POST " + fakeCdPath + "/Actions/VirtualMedia.EjectMedia":
+		b.inserted, b.image = false, ""
+		b.ejects++
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,72 @@
+package redfish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"snoman/internal/logger"
+	"strings"
+)
+
+// isoServer serves a single ISO file over HTTP so the BMC can mount it as virtual media
+type isoServer struct {
+	isoPath string
+	isoName string
+	server  *http.Server
+}
+
+func newIsoServer(addr string, isoPath string) *isoServer {
+	s := &isoServer{
+		isoPath: isoPath,
+		isoName: filepath.Base(isoPath),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+s.isoName, s.serveIso)
+
+	s.server = &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	return s
+}
+
+// Start will bind the listen address and serve in the background
+func (s *isoServer) Start() error {
+	log := logger.Get()
+
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("unable to listen on '%s': %w", s.server.Addr, err)
+	}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("iso server stopped unexpectedly: %v", err)
+		}
+	}()
+
+	log.Infof("serving %s on %s", s.isoPath, listener.Addr())
+
+	return nil
+}
+
+// URL returns the URL of the ISO relative to the address the BMC reaches us on
+func (s *isoServer) URL(baseURL string) string {
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(baseURL, "/"), s.isoName)
+}
+
+func (s *isoServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+func (s *isoServer) serveIso(w http.ResponseWriter, r *http.Request) {
+	logger.Get().Debugw("bmc requested iso", "remote", r.RemoteAddr, "method", r.Method, "range", r.Header.Get("Range"))
+
+	// ServeFile handles the HEAD and range requests BMCs use to stream the image
+	http.ServeFile(w, r, s.isoPath)
+}
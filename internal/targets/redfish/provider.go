@@ -0,0 +1,185 @@
+package redfish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"snoman/internal/logger"
+	"time"
+)
+
+const (
+	POWER_STATE_ON  = "On"
+	POWER_STATE_OFF = "Off"
+)
+
+// Provider installs onto a physical server by booting it from virtual media through its BMC
+type Provider struct {
+	spec       *RedfishSpec
+	client     *Client
+	server     *isoServer
+	system     *computerSystem
+	systemPath string
+	media      *virtualMedia
+	mediaPath  string
+}
+
+func NewProvider(spec *RedfishSpec) (*Provider, error) {
+	if err := spec.FillAndValidate(); err != nil {
+		return nil, err
+	}
+
+	client, err := NewClient(spec)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create redfish client: %w", err)
+	}
+
+	return &Provider{spec: spec, client: client}, nil
+}
+
+func (p *Provider) Name() string {
+	return fmt.Sprintf("redfish bmc '%s'", p.spec.BmcAddress)
+}
+
+// HostAddress is the configured address of the server, the BMC does not know it
+func (p *Provider) HostAddress() string {
+	return p.spec.HostIP
+}
+
+// Boot will serve the ISO, insert it as virtual media and boot the system from it once
+func (p *Provider) Boot(ctx context.Context, isoPath string) error {
+	log := logger.Get()
+
+	var err error
+	p.system, p.systemPath, err = p.client.FindSystem(ctx, p.spec.SystemID)
+	if err != nil {
+		return fmt.Errorf("unable to find the system on the BMC: %w", err)
+	}
+	log.Infow("found redfish system", "id", p.system.ID, "power", p.system.PowerState)
+
+	p.media, p.mediaPath, err = p.client.FindVirtualCD(ctx, p.system)
+	if err != nil {
+		return fmt.Errorf("unable to find virtual media for system '%s': %w", p.system.ID, err)
+	}
+
+	p.server = newIsoServer(p.spec.IsoServeAddress, isoPath)
+	if err := p.server.Start(); err != nil {
+		return fmt.Errorf("unable to start the iso server: %w", err)
+	}
+
+	imageURL := p.server.URL(p.spec.IsoBaseURL)
+	log.Infof("inserting %s into virtual media '%s'", imageURL, p.media.ID)
+	if err := p.client.InsertMedia(ctx, p.media, p.mediaPath, imageURL); err != nil {
+		return err
+	}
+
+	if err := p.client.SetOneTimeBoot(ctx, p.systemPath, "Cd"); err != nil {
+		return err
+	}
+
+	// A running system needs a restart to pick up the boot override
+	resetType := "On"
+	if p.system.PowerState != POWER_STATE_OFF {
+		resetType = "ForceRestart"
+	}
+
+	log.Infof("powering system '%s' with reset type '%s'", p.system.ID, resetType)
+	if err := p.client.Reset(ctx, p.system, p.systemPath, resetType); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Wait will keep the ISO served until the system has booted the installer and rebooted into the installed disk.
+// Booting the installer uses up the one time boot override, the reboot after it is seen as the power going off
+// and on again. A server that reboots without reporting it is only waited on for wait_timeout_seconds
+func (p *Provider) Wait(ctx context.Context) error {
+	log := logger.Get()
+	log.Info("waiting for the system to boot the installer and reboot into the installed disk")
+
+	waitCtx := ctx
+	if p.spec.WaitTimeoutSeconds != 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, time.Duration(p.spec.WaitTimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	err := p.waitForInstallReboot(waitCtx, time.Duration(p.spec.PowerPollSeconds)*time.Second)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		log.Warnf("no install reboot was seen within %ds, the installer iso is no longer served", p.spec.WaitTimeoutSeconds)
+		return nil
+	}
+
+	return err
+}
+
+// waitForInstallReboot will poll the system every interval until the one time boot override is used up and the
+// power then goes off and on again, or the context is done
+func (p *Provider) waitForInstallReboot(ctx context.Context, interval time.Duration) error {
+	log := logger.Get()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := p.system.PowerState
+	booted, poweredOff := false, false
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		system, err := p.client.GetSystem(ctx, p.systemPath)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warnf("unable to poll the system: %v", err)
+			}
+			continue
+		}
+
+		if system.PowerState != last {
+			log.Infow("system power state changed", "system", p.system.ID, "from", last, "to", system.PowerState)
+			last = system.PowerState
+		}
+
+		switch {
+		case !booted:
+			// Restarting the system to boot the installer can also turn the power off, only the power cycles
+			// after the installer was booted count
+			if system.PowerState == POWER_STATE_ON && system.Boot.BootSourceOverrideEnabled != "Once" {
+				log.Infow("system booted the installer", "system", p.system.ID)
+				booted = true
+			}
+		case system.PowerState != POWER_STATE_ON:
+			poweredOff = true
+		case poweredOff:
+			log.Infow("system rebooted into the installed disk", "system", p.system.ID)
+			return nil
+		}
+	}
+}
+
+// Close will stop the ISO server and eject the virtual media
+func (p *Provider) Close() error {
+	log := logger.Get()
+
+	// Close runs after the workflow context is done, so it gets its own deadline
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.spec.RequestTimeoutSecs)*time.Second)
+	defer cancel()
+
+	if p.media != nil {
+		if err := p.client.EjectMedia(ctx, p.media, p.mediaPath); err != nil {
+			log.Warnf("unable to eject virtual media: %v", err)
+		}
+	}
+
+	if p.server != nil {
+		if err := p.server.Shutdown(ctx); err != nil {
+			return fmt.Errorf("unable to stop the iso server: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package redfish
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"snoman/internal/vms/hypervisor/hypervisortest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIsoBaseURL = "http://192.0.2.10:8080"
+
+func newTestProvider(t *testing.T, bmcURL string) *Provider {
+	t.Helper()
+
+	provider, err := NewProvider(&RedfishSpec{
+		BmcAddress:      bmcURL,
+		Username:        fakeUsername,
+		Password:        fakePassword,
+		IsoServeAddress: freeAddress(t),
+		IsoBaseURL:      testIsoBaseURL,
+	})
+	if err != nil {
+		t.Fatalf("unable to create the provider: %v", err)
+	}
+	t.Cleanup(func() { provider.Close() })
+
+	return provider
+}
+
+// freeAddress is a loopback address with a port nothing listens on, as the spec does not accept port 0
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
+
+func TestProviderBoot(t *testing.T) {
+	tests := []struct {
+		name       string
+		bmc        *fakeBMC
+		wantErr    string
+		wantResets []string
+		wantEjects int
+	}{
+		{
+			name:       "powered off system is turned on",
+			bmc:        &fakeBMC{powerState: POWER_STATE_OFF},
+			wantResets: []string{"On"},
+		},
+		{
+			name:       "running system is restarted",
+			bmc:        &fakeBMC{powerState: POWER_STATE_ON},
+			wantResets: []string{"ForceRestart"},
+		},
+		{
+			name:       "inserted media is ejected first",
+			bmc:        &fakeBMC{powerState: POWER_STATE_OFF, inserted: true, image: "http://old/old.iso"},
+			wantResets: []string{"On"},
+			wantEjects: 1,
+		},
+		{
+			name:    "no cd virtual media",
+			bmc:     &fakeBMC{powerState: POWER_STATE_OFF, noCd: true},
+			wantErr: ErrNoVirtualMedia.Error(),
+		},
+		{
+			name:    "several systems need a system id",
+			bmc:     &fakeBMC{powerState: POWER_STATE_OFF, extraSystems: 1},
+			wantErr: "a system_id is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeBMC(t, tt.bmc)
+			provider := newTestProvider(t, server.URL)
+
+			err := provider.Boot(context.Background(), hypervisortest.WriteIso(t, "agent.x86_64.iso"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Boot() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Boot() error = %v", err)
+			}
+
+			tt.bmc.mux.Lock()
+			defer tt.bmc.mux.Unlock()
+
+			if want := testIsoBaseURL + "/agent.x86_64.iso"; !tt.bmc.inserted || tt.bmc.image != want {
+				t.Errorf("inserted media = %v %q, want %q", tt.bmc.inserted, tt.bmc.image, want)
+			}
+			if tt.bmc.bootTarget != "Cd" || tt.bmc.bootEnabled != "Once" {
+				t.Errorf("boot override = %s %s, want Cd Once", tt.bmc.bootTarget, tt.bmc.bootEnabled)
+			}
+			if !reflect.DeepEqual(tt.bmc.resets, tt.wantResets) {
+				t.Errorf("resets = %v, want %v", tt.bmc.resets, tt.wantResets)
+			}
+			if tt.bmc.ejects != tt.wantEjects {
+				t.Errorf("ejects = %d, want %d", tt.bmc.ejects, tt.wantEjects)
+			}
+		})
+	}
+}
+
+func TestProviderCloseEjectsMedia(t *testing.T) {
+	bmc := &fakeBMC{powerState: POWER_STATE_OFF}
+	provider := newTestProvider(t, newFakeBMC(t, bmc).URL)
+
+	if err := provider.Boot(context.Background(), hypervisortest.WriteIso(t, "agent.x86_64.iso")); err != nil {
+		t.Fatalf("Boot() error = %v", err)
+	}
+
+	if err := provider.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	bmc.mux.Lock()
+	defer bmc.mux.Unlock()
+	if bmc.inserted || bmc.ejects != 1 {
+		t.Errorf("media inserted = %v with %d ejects, want it ejected once", bmc.inserted, bmc.ejects)
+	}
+}
+
+func TestProviderWaitForInstallReboot(t *testing.T) {
+	tests := []struct {
+		name  string
+		polls []func(b *fakeBMC)
+		// wantWaiting is true when the polls are not enough to see the install reboot
+		wantWaiting bool
+	}{
+		{
+			name:  "installer booted then power cycled",
+			polls: []func(b *fakeBMC){bootedOverride, powerState(POWER_STATE_OFF), powerState(POWER_STATE_ON)},
+		},
+		{
+			name: "power cycle of the restart into the installer",
+			polls: []func(b *fakeBMC){
+				powerState(POWER_STATE_OFF), powerState(POWER_STATE_ON),
+				bootedOverride, powerState(POWER_STATE_OFF), powerState(POWER_STATE_ON),
+			},
+		},
+		{
+			name:        "installer booted without a reboot",
+			polls:       []func(b *fakeBMC){bootedOverride},
+			wantWaiting: true,
+		},
+		{
+			name:        "power cycled before the installer booted",
+			polls:       []func(b *fakeBMC){powerState(POWER_STATE_OFF), powerState(POWER_STATE_ON)},
+			wantWaiting: true,
+		},
+		{
+			name:        "still off after the installer",
+			polls:       []func(b *fakeBMC){bootedOverride, powerState(POWER_STATE_OFF)},
+			wantWaiting: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bmc := &fakeBMC{powerState: POWER_STATE_OFF}
+			provider := newTestProvider(t, newFakeBMC(t, bmc).URL)
+
+			if err := provider.Boot(context.Background(), hypervisortest.WriteIso(t, "agent.x86_64.iso")); err != nil {
+				t.Fatalf("Boot() error = %v", err)
+			}
+			bmc.setPolls(tt.polls...)
+
+			timeout := 5 * time.Second
+			if tt.wantWaiting {
+				timeout = 500 * time.Millisecond
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			err := provider.waitForInstallReboot(ctx, 10*time.Millisecond)
+			if tt.wantWaiting {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("waitForInstallReboot() error = %v, want it still waiting", err)
+				}
+			} else if err != nil {
+				t.Fatalf("waitForInstallReboot() error = %v", err)
+			}
+
+			if remaining := bmc.remainingPolls(); remaining != 0 {
+				t.Errorf("%d polls were not made", remaining)
+			}
+		})
+	}
+}
+
+func TestProviderWaitTimeout(t *testing.T) {
+	bmc := &fakeBMC{powerState: POWER_STATE_OFF}
+	provider := newTestProvider(t, newFakeBMC(t, bmc).URL)
+	provider.spec.WaitTimeoutSeconds = 1
+
+	if err := provider.Boot(context.Background(), hypervisortest.WriteIso(t, "agent.x86_64.iso")); err != nil {
+		t.Fatalf("Boot() error = %v", err)
+	}
+
+	// Running out of wait time ends the wait, an interrupted run does not
+	if err := provider.Wait(context.Background()); err != nil {
+		t.Errorf("Wait() error = %v, want the timeout to end the wait", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := provider.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestClientCancelsHungRequests(t *testing.T) {
+	bmc := &fakeBMC{hang: true}
+	client, err := NewClient(&RedfishSpec{BmcAddress: newFakeBMC(t, bmc).URL, RequestTimeoutSecs: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := client.GetPowerState(ctx, fakeSystemPath); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetPowerState() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("GetPowerState() took %s after the context was done", elapsed)
+	}
+}
@@ -0,0 +1,139 @@
+package redfish
+
+import (
+	"fmt"
+	"net"
+	"os"
+	vmutils "snoman/internal/vms/utils"
+
+	"gopkg.in/yaml.v2"
+)
+
+type RedfishSpec struct {
+	BmcAddress string `yaml:"bmc_address" validate:"required,url"`
+	Username   string `yaml:"username" validate:"required"`
+	Password   string `yaml:"password,omitempty" validate:"omitempty"`
+	SystemID   string `yaml:"system_id,omitempty" validate:"omitempty"`
+	// HostIP is the address the server gets on the machine network, the cluster API is resolved to it
+	HostIP             string `yaml:"host_ip,omitempty" validate:"omitempty,ip"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty" validate:"omitempty"`
+	IsoServeAddress    string `yaml:"iso_serve_address,omitempty" validate:"omitempty,hostname_port"`
+	IsoBaseURL         string `yaml:"iso_base_url,omitempty" validate:"omitempty,url"`
+	PowerPollSeconds   uint   `yaml:"power_poll_interval_seconds,omitempty" validate:"omitempty"`
+	RequestTimeoutSecs uint   `yaml:"request_timeout_seconds,omitempty" validate:"omitempty"`
+	// WaitTimeoutSeconds stops waiting for the install reboot, for servers that reboot without reporting a power change
+	WaitTimeoutSeconds uint `yaml:"wait_timeout_seconds,omitempty" validate:"omitempty"`
+}
+
+const (
+	DEFAULT_ISO_SERVE_ADDRESS  = "0.0.0.0:8080"
+	DEFAULT_POWER_POLL_SECONDS = 10
+	DEFAULT_REQUEST_TIMEOUT    = 30
+	BMC_PASSWORD_ENV           = "REDFISH_PASSWORD"
+)
+
+// FillAndValidate will populate any needed empty fields with defaults and then validate the struct
+func (spec *RedfishSpec) FillAndValidate() error {
+	if spec.Password == "" {
+		spec.Password = os.Getenv(BMC_PASSWORD_ENV)
+	}
+
+	if spec.IsoServeAddress == "" {
+		spec.IsoServeAddress = DEFAULT_ISO_SERVE_ADDRESS
+	}
+
+	if spec.PowerPollSeconds == 0 {
+		spec.PowerPollSeconds = DEFAULT_POWER_POLL_SECONDS
+	}
+
+	if spec.RequestTimeoutSecs == 0 {
+		spec.RequestTimeoutSecs = DEFAULT_REQUEST_TIMEOUT
+	}
+
+	if err := vmutils.SpecValidator.Struct(spec); err != nil {
+		return fmt.Errorf("unable to validate RedfishSpec: %w", err)
+	}
+
+	// The BMC needs an address it can reach us on, we can only guess it if we are bound to a specific host
+	if spec.IsoBaseURL == "" {
+		host, port, _ := net.SplitHostPort(spec.IsoServeAddress)
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			return fmt.Errorf("iso_base_url is required when iso_serve_address '%s' does not bind a specific host", spec.IsoServeAddress)
+		}
+
+		spec.IsoBaseURL = fmt.Sprintf("http://%s", net.JoinHostPort(host, port))
+	}
+
+	return nil
+}
+
+func (spec *RedfishSpec) MarshalYAML() (string, error) {
+	if err := spec.FillAndValidate(); err != nil {
+		return "", err
+	}
+
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (spec *RedfishSpec) UnmarshalYAML(yamlData []byte) error {
+	err := yaml.Unmarshal(yamlData, spec)
+	if err != nil {
+		return fmt.Errorf("unable to parse the spec: %w", err)
+	}
+
+	if err := spec.FillAndValidate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Redfish resources, only the fields snoman needs are modeled
+
+type odataID struct {
+	ID string `json:"@odata.id"`
+}
+
+type collection struct {
+	Members []odataID `json:"Members"`
+}
+
+type actionTarget struct {
+	Target string `json:"target"`
+}
+
+type computerSystem struct {
+	ID         string `json:"Id"`
+	PowerState string `json:"PowerState"`
+	Links      struct {
+		ManagedBy []odataID `json:"ManagedBy"`
+	} `json:"Links"`
+	VirtualMedia odataID `json:"VirtualMedia"`
+	Boot         struct {
+		BootSourceOverrideEnabled string `json:"BootSourceOverrideEnabled"`
+	} `json:"Boot"`
+	Actions struct {
+		Reset actionTarget `json:"#ComputerSystem.Reset"`
+	} `json:"Actions"`
+}
+
+type manager struct {
+	ID           string  `json:"Id"`
+	VirtualMedia odataID `json:"VirtualMedia"`
+}
+
+type virtualMedia struct {
+	ID         string   `json:"Id"`
+	MediaTypes []string `json:"MediaTypes"`
+	Image      string   `json:"Image"`
+	Inserted   bool     `json:"Inserted"`
+	Actions    struct {
+		Insert actionTarget `json:"#VirtualMedia.InsertMedia"`
+		Eject  actionTarget `json:"#VirtualMedia.EjectMedia"`
+	} `json:"Actions"`
+}
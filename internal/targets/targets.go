@@ -0,0 +1,82 @@
+package targets
+
+import (
+	"context"
+	"errors"
+	"snoman/internal/vms/machines"
+)
+
+// Provider is a machine that bootstrap in place can install a cluster onto using the generated installer ISO
+type Provider interface {
+	// Name is a short description of the provider used in logs
+	Name() string
+
+	// Boot will attach the installer ISO at isoPath to the target and power it on
+	Boot(ctx context.Context, isoPath string) error
+
+	// Wait will block until the target no longer needs anything from snoman or the context is done
+	Wait(ctx context.Context) error
+
+	// Close will release any resources held by the provider
+	Close() error
+}
+
+// HostAddressProvider is implemented by the targets that know the IP address the machine will have, the
+// cluster API is resolved to it
+type HostAddressProvider interface {
+	// HostAddress is empty when the address is not known
+	HostAddress() string
+}
+
+// NetworkService listens on the network of a virtual machine target, so it can only be started once the
+// network exists and has to be running before the machine boots, ex: the built-in proxy on the gateway
+type NetworkService interface {
+	Start() error
+	Shutdown(ctx context.Context) error
+}
+
+// NetworkServiceHost is implemented by the targets that create the network the machine boots on
+type NetworkServiceHost interface {
+	// AddNetworkService will start the service on the target network before booting and stop it on Close
+	AddNetworkService(service NetworkService)
+}
+
+// networkServices are the services of a target, only the started ones are shut down
+type networkServices struct {
+	services []NetworkService
+	started  []NetworkService
+}
+
+func (n *networkServices) AddNetworkService(service NetworkService) {
+	n.services = append(n.services, service)
+}
+
+func (n *networkServices) start() error {
+	for _, service := range n.services {
+		if err := service.Start(); err != nil {
+			return err
+		}
+		n.started = append(n.started, service)
+	}
+
+	return nil
+}
+
+func (n *networkServices) shutdown() error {
+	var errs []error
+	for _, service := range n.started {
+		errs = append(errs, service.Shutdown(context.Background()))
+	}
+	n.started = nil
+
+	return errors.Join(errs...)
+}
+
+// machineHostAddress is the address DHCP reserves for a virtual machine on its network
+func machineHostAddress(spec *machines.VirtualMachineSpec) string {
+	if spec.Network == nil || len(spec.Network.Hosts) == 0 {
+		return ""
+	}
+
+	return spec.Network.Hosts[0].IpAddress
+}
@@ -0,0 +1,71 @@
+package targets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"snoman/internal/biputils"
+	"snoman/internal/vms/machines"
+)
+
+// VirtualMachineProvider installs onto a local libvirt virtual machine
+type VirtualMachineProvider struct {
+	spec  *machines.VirtualMachineSpec
+	stats *machines.StatsRecorder
+	networkServices
+}
+
+func NewVirtualMachineProvider(spec *machines.VirtualMachineSpec) *VirtualMachineProvider {
+	return &VirtualMachineProvider{spec: spec}
+}
+
+func (p *VirtualMachineProvider) Name() string {
+	return fmt.Sprintf("libvirt vm '%s'", p.spec.Name)
+}
+
+func (p *VirtualMachineProvider) HostAddress() string {
+	return machineHostAddress(p.spec)
+}
+
+// Boot will create the virtual machine (and its network) with the ISO attached as a cdrom
+func (p *VirtualMachineProvider) Boot(ctx context.Context, isoPath string) error {
+	if p.spec.BipSpec == nil {
+		p.spec.BipSpec = &biputils.BootstrapInPlaceIsoSpec{}
+	}
+	p.spec.BipSpec.IsoPath = isoPath
+
+	return p.boot(ctx)
+}
+
+// boot will create the virtual machine network, start the network services on it and then install the VM
+func (p *VirtualMachineProvider) boot(ctx context.Context) error {
+	// Record the resource usage for as long as the target is in use
+	var err error
+	p.stats, err = machines.StartStatsRecorder(ctx, p.spec)
+	if err != nil {
+		return fmt.Errorf("could not record the virtual machine stats: %w", err)
+	}
+
+	if err := machines.PrepareVirtualMachine(ctx, p.spec); err != nil {
+		return fmt.Errorf("could not prepare the virtual machine: %w", err)
+	}
+
+	if err := p.start(); err != nil {
+		return fmt.Errorf("could not start the network services: %w", err)
+	}
+
+	if err := machines.StartVirtualMachine(ctx, p.spec); err != nil {
+		return fmt.Errorf("could not create the virtual machine: %w", err)
+	}
+
+	return nil
+}
+
+// Wait returns immediately, virt-install already waits for the machine
+func (p *VirtualMachineProvider) Wait(ctx context.Context) error {
+	return nil
+}
+
+func (p *VirtualMachineProvider) Close() error {
+	return errors.Join(p.shutdown(), p.stats.Stop())
+}
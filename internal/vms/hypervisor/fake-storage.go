@@ -0,0 +1,257 @@
+package hypervisor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+type fakePool struct {
+	info    PoolInfo
+	volumes map[string]*VolumeInfo
+}
+
+// findPool will find the pool by name, the caller must hold the lock
+func (f *Fake) findPool(name string) (*fakePool, error) {
+	pool, ok := f.pools[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: storage pool '%s'", ErrNotFound, name)
+	}
+
+	return pool, nil
+}
+
+// used will return the bytes allocated to volumes in the pool
+func (p *fakePool) used() uint64 {
+	var used uint64
+	for _, vol := range p.volumes {
+		used += vol.Capacity
+	}
+
+	return used
+}
+
+func (p *fakePool) snapshot() PoolInfo {
+	info := p.info
+	info.Available = info.Capacity - min(p.used(), info.Capacity)
+
+	return info
+}
+
+func (f *Fake) ListPools(ctx context.Context) ([]PoolInfo, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	infos := make([]PoolInfo, 0, len(f.pools))
+	for _, pool := range f.pools {
+		infos = append(infos, pool.snapshot())
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return infos, nil
+}
+
+func (f *Fake) LookupPool(ctx context.Context, name string) (*PoolInfo, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	pool, err := f.findPool(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info := pool.snapshot()
+	return &info, nil
+}
+
+func (f *Fake) CreatePool(ctx context.Context, poolcfg *libvirtxml.StoragePool, autostart bool) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if _, ok := f.pools[poolcfg.Name]; ok {
+		return fmt.Errorf("%w: storage pool '%s'", ErrAlreadyExists, poolcfg.Name)
+	}
+
+	info := PoolInfo{
+		Name:      poolcfg.Name,
+		Active:    true,
+		Autostart: autostart,
+		Capacity:  FAKE_POOL_CAPACITY_BYTES,
+	}
+
+	if poolcfg.Target != nil {
+		info.Path = poolcfg.Target.Path
+	}
+
+	f.pools[poolcfg.Name] = &fakePool{info: info, volumes: map[string]*VolumeInfo{}}
+
+	return nil
+}
+
+func (f *Fake) DestroyPool(ctx context.Context, name string, deleteData bool) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	pool, err := f.findPool(name)
+	if err != nil {
+		return err
+	}
+
+	if !deleteData && len(pool.volumes) > 0 {
+		// libvirt leaves the volumes on disk, the fake has nowhere to keep them so refuse instead
+		return fmt.Errorf("could not undefine the storage pool: pool '%s' still has %d volumes", name, len(pool.volumes))
+	}
+
+	delete(f.pools, name)
+
+	return nil
+}
+
+func (f *Fake) ListVolumes(ctx context.Context, poolName string) ([]VolumeInfo, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	pool, err := f.findPool(poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]VolumeInfo, 0, len(pool.volumes))
+	for _, vol := range pool.volumes {
+		infos = append(infos, *vol)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+
+	return infos, nil
+}
+
+func (f *Fake) LookupVolume(ctx context.Context, poolName string, name string) (*VolumeInfo, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	pool, err := f.findPool(poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	vol, ok := pool.volumes[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: volume '%s' in pool '%s'", ErrNotFound, name, poolName)
+	}
+
+	info := *vol
+	return &info, nil
+}
+
+func (f *Fake) LookupVolumeByPath(ctx context.Context, path string) (*VolumeInfo, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	for _, pool := range f.pools {
+		for _, vol := range pool.volumes {
+			if vol.Path == path {
+				info := *vol
+				return &info, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: volume at '%s'", ErrNotFound, path)
+}
+
+// addVolume will add the volume to the pool, the caller must hold the lock
+func (f *Fake) addVolume(poolName string, volcfg *libvirtxml.StorageVolume, allocation uint64) (*VolumeInfo, error) {
+	pool, err := f.findPool(poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := pool.volumes[volcfg.Name]; ok {
+		return nil, fmt.Errorf("%w: volume '%s' in pool '%s'", ErrAlreadyExists, volcfg.Name, poolName)
+	}
+
+	vol := &VolumeInfo{
+		Name:       volcfg.Name,
+		Pool:       poolName,
+		Path:       filepath.Join(pool.info.Path, volcfg.Name),
+		Allocation: allocation,
+	}
+
+	if volcfg.Capacity != nil {
+		vol.Capacity = volcfg.Capacity.Value
+	}
+
+	if volcfg.Target != nil && volcfg.Target.Format != nil {
+		vol.Format = volcfg.Target.Format.Type
+	}
+
+	if vol.Capacity > pool.snapshot().Available {
+		return nil, fmt.Errorf("unable to create volume '%s' in pool '%s': not enough space", volcfg.Name, poolName)
+	}
+
+	pool.volumes[vol.Name] = vol
+
+	info := *vol
+	return &info, nil
+}
+
+func (f *Fake) CreateVolume(ctx context.Context, poolName string, volcfg *libvirtxml.StorageVolume) (*VolumeInfo, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return f.addVolume(poolName, volcfg, 0)
+}
+
+func (f *Fake) CloneVolume(ctx context.Context, poolName string, source string, volcfg *libvirtxml.StorageVolume) (*VolumeInfo, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	pool, err := f.findPool(poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	src, ok := pool.volumes[source]
+	if !ok {
+		return nil, fmt.Errorf("%w: volume '%s' in pool '%s'", ErrNotFound, source, poolName)
+	}
+
+	return f.addVolume(poolName, volcfg, src.Allocation)
+}
+
+// UploadVolume will create the volume and drain the data, the fake only keeps track of how much was written
+func (f *Fake) UploadVolume(ctx context.Context, poolName string, volcfg *libvirtxml.StorageVolume, data io.Reader) (*VolumeInfo, error) {
+	written, err := io.Copy(io.Discard, data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to upload volume data: %w", err)
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	return f.addVolume(poolName, volcfg, uint64(written))
+}
+
+func (f *Fake) DeleteVolume(ctx context.Context, poolName string, name string) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	pool, err := f.findPool(poolName)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := pool.volumes[name]; !ok {
+		return fmt.Errorf("%w: volume '%s' in pool '%s'", ErrNotFound, name, poolName)
+	}
+
+	delete(pool.volumes, name)
+
+	return nil
+}
@@ -0,0 +1,596 @@
+package hypervisor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	FAKE_HOST_ARCH         = "x86_64"
+	FAKE_HOST_CPUS         = 16
+	FAKE_HOST_MEMORY_BYTES = 64 * 1024 * 1024 * 1024
+	// Every fake pool reports this much space, volumes are only accounted for and never written
+	FAKE_POOL_CAPACITY_BYTES = 1024 * 1024 * 1024 * 1024
+)
+
+// Fake is an in-memory Hypervisor. It keeps just enough state for snoman's workflows to run end to end
+// without libvirt, which makes it useful for tests and dry runs
+type Fake struct {
+	mux      sync.Mutex
+	host     HostInfo
+	networks map[string]*fakeNetwork
+	domains  map[string]*fakeDomain
+	pools    map[string]*fakePool
+	watchers map[int]func(DomainEvent)
+	nextID   int
+}
+
+type fakeNetwork struct {
+	cfg *libvirtxml.Network
+}
+
+type fakeDomain struct {
+	cfg      *libvirtxml.Domain
+	active   bool
+	paused   bool
+	metadata map[string]string
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		host: HostInfo{
+			Arch:            FAKE_HOST_ARCH,
+			CPUs:            FAKE_HOST_CPUS,
+			MemoryBytes:     FAKE_HOST_MEMORY_BYTES,
+			FreeMemoryBytes: FAKE_HOST_MEMORY_BYTES,
+		},
+		networks: map[string]*fakeNetwork{},
+		domains:  map[string]*fakeDomain{},
+		pools:    map[string]*fakePool{},
+		watchers: map[int]func(DomainEvent){},
+	}
+}
+
+func (f *Fake) Close() error {
+	return nil
+}
+
+func (f *Fake) HostInfo(ctx context.Context) (*HostInfo, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	info := f.host
+	return &info, nil
+}
+
+// SetHostInfo will change what the fake reports about the host, e.g. to simulate a small machine
+func (f *Fake) SetHostInfo(info HostInfo) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	f.host = info
+}
+
+// copyNetwork will deep copy the config through its XML so callers never share state with the fake
+func copyNetwork(netcfg *libvirtxml.Network) (*libvirtxml.Network, error) {
+	netxml, err := netcfg.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate network xml: %w", err)
+	}
+
+	cp := &libvirtxml.Network{}
+	if err := cp.Unmarshal(netxml); err != nil {
+		return nil, fmt.Errorf("unable to parse network xml: %w", err)
+	}
+
+	return cp, nil
+}
+
+func copyDomain(domcfg *libvirtxml.Domain) (*libvirtxml.Domain, error) {
+	domxml, err := domcfg.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate domain xml: %w", err)
+	}
+
+	cp := &libvirtxml.Domain{}
+	if err := cp.Unmarshal(domxml); err != nil {
+		return nil, fmt.Errorf("unable to parse domain xml: %w", err)
+	}
+
+	return cp, nil
+}
+
+// Networks
+
+// findNetwork will find the network by name or UUID, the caller must hold the lock
+func (f *Fake) findNetwork(id string) (*fakeNetwork, error) {
+	if net, ok := f.networks[id]; ok {
+		return net, nil
+	}
+
+	for _, net := range f.networks {
+		if net.cfg.UUID == id {
+			return net, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: network '%s'", ErrNotFound, id)
+}
+
+func (f *Fake) LookupNetwork(ctx context.Context, id string) (*libvirtxml.Network, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	net, err := f.findNetwork(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return copyNetwork(net.cfg)
+}
+
+func (f *Fake) CreateNetwork(ctx context.Context, netcfg *libvirtxml.Network) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if _, ok := f.networks[netcfg.Name]; ok {
+		return fmt.Errorf("%w: network '%s'", ErrAlreadyExists, netcfg.Name)
+	}
+
+	cfg, err := copyNetwork(netcfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.UUID == "" {
+		cfg.UUID = uuid.NewString()
+	}
+
+	f.networks[cfg.Name] = &fakeNetwork{cfg: cfg}
+
+	return nil
+}
+
+func (f *Fake) DestroyNetwork(ctx context.Context, id string) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	net, err := f.findNetwork(id)
+	if err != nil {
+		return err
+	}
+
+	delete(f.networks, net.cfg.Name)
+
+	return nil
+}
+
+func (f *Fake) RedefineNetwork(ctx context.Context, netcfg *libvirtxml.Network) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	net, err := f.findNetwork(netcfg.Name)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := copyNetwork(netcfg)
+	if err != nil {
+		return err
+	}
+
+	cfg.UUID = net.cfg.UUID
+	net.cfg = cfg
+
+	return nil
+}
+
+func (f *Fake) AddNetworkDHCPHost(ctx context.Context, id string, host *libvirtxml.NetworkDHCPHost) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	net, err := f.findNetwork(id)
+	if err != nil {
+		return err
+	}
+
+	for i := range net.cfg.IPs {
+		ip := &net.cfg.IPs[i]
+		if ip.DHCP == nil {
+			continue
+		}
+
+		for _, existing := range ip.DHCP.Hosts {
+			if (host.MAC != "" && existing.MAC == host.MAC) || (host.IP != "" && existing.IP == host.IP) {
+				return fmt.Errorf("%w: dhcp host '%s' on network '%s'", ErrAlreadyExists, host.MAC, id)
+			}
+		}
+
+		ip.DHCP.Hosts = append(ip.DHCP.Hosts, *host)
+
+		return nil
+	}
+
+	return fmt.Errorf("could not update network '%s': it has no dhcp range", id)
+}
+
+// Domains
+
+// findDomain will find the domain by name or UUID, the caller must hold the lock
+func (f *Fake) findDomain(id string) (*fakeDomain, error) {
+	if dom, ok := f.domains[id]; ok {
+		return dom, nil
+	}
+
+	for _, dom := range f.domains {
+		if dom.cfg.UUID == id {
+			return dom, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: domain '%s'", ErrNotFound, id)
+}
+
+func (f *Fake) ListDomains(ctx context.Context) ([]*libvirtxml.Domain, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	domcfgs := make([]*libvirtxml.Domain, 0, len(f.domains))
+	for _, dom := range f.domains {
+		domcfg, err := copyDomain(dom.cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		domcfgs = append(domcfgs, domcfg)
+	}
+
+	sort.Slice(domcfgs, func(i, j int) bool { return domcfgs[i].Name < domcfgs[j].Name })
+
+	return domcfgs, nil
+}
+
+func (f *Fake) LookupDomain(ctx context.Context, id string) (*libvirtxml.Domain, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return copyDomain(dom.cfg)
+}
+
+// DefineDomain will add the domain or, like libvirt, replace the config of an existing domain with the same name
+func (f *Fake) DefineDomain(ctx context.Context, domcfg *libvirtxml.Domain) error {
+	cfg, err := copyDomain(domcfg)
+	if err != nil {
+		return err
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if dom, ok := f.domains[cfg.Name]; ok {
+		cfg.UUID = dom.cfg.UUID
+		dom.cfg = cfg
+	} else {
+		if cfg.UUID == "" {
+			cfg.UUID = uuid.NewString()
+		}
+
+		f.domains[cfg.Name] = &fakeDomain{cfg: cfg, metadata: map[string]string{}}
+	}
+
+	f.emit(cfg.Name, DOMAIN_EVENT_DEFINED)
+
+	return nil
+}
+
+func (f *Fake) StartDomain(ctx context.Context, id string) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return err
+	}
+
+	if dom.active {
+		return fmt.Errorf("unable to start domain '%s': domain is already running", id)
+	}
+
+	dom.active = true
+	f.emit(dom.cfg.Name, DOMAIN_EVENT_STARTED)
+
+	return nil
+}
+
+func (f *Fake) StopDomain(ctx context.Context, id string) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return err
+	}
+
+	if !dom.active {
+		return fmt.Errorf("unable to stop domain '%s': domain is not running", id)
+	}
+
+	dom.active = false
+	dom.paused = false
+	f.emit(dom.cfg.Name, DOMAIN_EVENT_STOPPED)
+
+	return nil
+}
+
+func (f *Fake) UndefineDomain(ctx context.Context, id string) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return err
+	}
+
+	delete(f.domains, dom.cfg.Name)
+	f.emit(dom.cfg.Name, DOMAIN_EVENT_UNDEFINED)
+
+	return nil
+}
+
+func (f *Fake) DomainIsActive(ctx context.Context, id string) (bool, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return false, err
+	}
+
+	return dom.active, nil
+}
+
+func (f *Fake) SuspendDomain(ctx context.Context, id string) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return err
+	}
+
+	if !dom.active || dom.paused {
+		return fmt.Errorf("unable to suspend domain '%s': domain is not running", id)
+	}
+
+	dom.paused = true
+	f.emit(dom.cfg.Name, DOMAIN_EVENT_SUSPENDED)
+
+	return nil
+}
+
+func (f *Fake) ResumeDomain(ctx context.Context, id string) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return err
+	}
+
+	if !dom.paused {
+		return fmt.Errorf("unable to resume domain '%s': domain is not paused", id)
+	}
+
+	dom.paused = false
+	f.emit(dom.cfg.Name, DOMAIN_EVENT_RESUMED)
+
+	return nil
+}
+
+// ResetDomain only checks the domain is running, the fake has no guest to reset
+func (f *Fake) ResetDomain(ctx context.Context, id string) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return err
+	}
+
+	if !dom.active {
+		return fmt.Errorf("unable to reset domain '%s': domain is not running", id)
+	}
+
+	return nil
+}
+
+func (f *Fake) GetDomainMetadata(ctx context.Context, id string, uri string) (string, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return "", err
+	}
+
+	metadata, ok := dom.metadata[uri]
+	if !ok {
+		return "", fmt.Errorf("%w: metadata '%s' on domain '%s'", ErrNotFound, uri, id)
+	}
+
+	return metadata, nil
+}
+
+func (f *Fake) SetDomainMetadata(ctx context.Context, id string, prefix string, uri string, metadata string) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return err
+	}
+
+	dom.metadata[uri] = metadata
+
+	return nil
+}
+
+// DomainStats will report the configured memory of a running domain, the fake does no work so counters stay at zero
+func (f *Fake) DomainStats(ctx context.Context, id string) (*DomainStats, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dom.active {
+		return nil, fmt.Errorf("unable to get stats for domain '%s': domain is not running", id)
+	}
+
+	stats := &DomainStats{Time: time.Now()}
+	if dom.cfg.Memory != nil {
+		stats.MemoryBytes = uint64(dom.cfg.Memory.Value) * 1024 * 1024
+		stats.RSSBytes = stats.MemoryBytes
+	}
+
+	return stats, nil
+}
+
+// findDisk will return the index of the disk with the target device, the caller must hold the lock
+func (dom *fakeDomain) findDisk(target string) int {
+	if dom.cfg.Devices == nil {
+		return -1
+	}
+
+	for i, disk := range dom.cfg.Devices.Disks {
+		if disk.Target != nil && disk.Target.Dev == target {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// UpdateDomainDisk will replace the disk config, the fake keeps a single config so live has no effect
+func (f *Fake) UpdateDomainDisk(ctx context.Context, id string, disk *libvirtxml.DomainDisk, live bool) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return err
+	}
+
+	i := dom.findDisk(disk.Target.Dev)
+	if i < 0 {
+		return fmt.Errorf("%w: disk '%s' of domain '%s'", ErrNotFound, disk.Target.Dev, id)
+	}
+
+	dom.cfg.Devices.Disks[i] = *disk
+
+	return nil
+}
+
+func (f *Fake) AttachDomainDisk(ctx context.Context, id string, disk *libvirtxml.DomainDisk, live bool) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return err
+	}
+
+	if dom.findDisk(disk.Target.Dev) >= 0 {
+		return fmt.Errorf("%w: disk '%s' of domain '%s'", ErrAlreadyExists, disk.Target.Dev, id)
+	}
+
+	if dom.cfg.Devices == nil {
+		dom.cfg.Devices = &libvirtxml.DomainDeviceList{}
+	}
+	dom.cfg.Devices.Disks = append(dom.cfg.Devices.Disks, *disk)
+
+	return nil
+}
+
+func (f *Fake) DetachDomainDisk(ctx context.Context, id string, disk *libvirtxml.DomainDisk, live bool) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return err
+	}
+
+	i := dom.findDisk(disk.Target.Dev)
+	if i < 0 {
+		return fmt.Errorf("%w: disk '%s' of domain '%s'", ErrNotFound, disk.Target.Dev, id)
+	}
+
+	dom.cfg.Devices.Disks = append(dom.cfg.Devices.Disks[:i], dom.cfg.Devices.Disks[i+1:]...)
+
+	return nil
+}
+
+// UpdateDomainInterface will replace the interface config, the fake keeps a single config so live has no effect
+func (f *Fake) UpdateDomainInterface(ctx context.Context, id string, iface *libvirtxml.DomainInterface, live bool) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	dom, err := f.findDomain(id)
+	if err != nil {
+		return err
+	}
+
+	if dom.cfg.Devices != nil && iface.MAC != nil {
+		for i, cur := range dom.cfg.Devices.Interfaces {
+			if cur.MAC != nil && strings.EqualFold(cur.MAC.Address, iface.MAC.Address) {
+				dom.cfg.Devices.Interfaces[i] = *iface
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: interface of domain '%s'", ErrNotFound, id)
+}
+
+func (f *Fake) WatchDomainEvents(ctx context.Context, fn func(DomainEvent)) error {
+	f.mux.Lock()
+	id := f.nextID
+	f.nextID++
+	f.watchers[id] = fn
+	f.mux.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		f.mux.Lock()
+		delete(f.watchers, id)
+		f.mux.Unlock()
+	}()
+
+	return nil
+}
+
+// emit will deliver the event to every watcher in its own goroutine so watchers can call back into the fake.
+// The caller must hold the lock
+func (f *Fake) emit(domain string, eventType DomainEventType) {
+	event := DomainEvent{Domain: domain, Type: eventType}
+
+	for _, fn := range f.watchers {
+		go fn(event)
+	}
+}
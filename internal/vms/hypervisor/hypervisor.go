@@ -0,0 +1,200 @@
+package hypervisor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	vmutils "snoman/internal/vms/utils"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+var (
+	ErrNotFound      = fmt.Errorf("the requested resource could not be found")
+	ErrAlreadyExists = fmt.Errorf("the resource already exists")
+)
+
+const (
+	// FAKE_URI selects the in-memory hypervisor. Nothing is persisted between commands
+	FAKE_URI = "fake:///default"
+	// TEST_URI is the libvirt test driver, a stateless libvirt hypervisor that needs no libvirtd
+	TEST_URI = "test:///default"
+)
+
+// Hypervisor is everything snoman needs from a virtualization host. Resources are described with libvirtxml
+// so every implementation shares the same data model
+type Hypervisor interface {
+	// Host
+	HostInfo(ctx context.Context) (*HostInfo, error)
+
+	// Networks, id can be a name or UUID
+	LookupNetwork(ctx context.Context, id string) (*libvirtxml.Network, error)
+	CreateNetwork(ctx context.Context, net *libvirtxml.Network) error
+	DestroyNetwork(ctx context.Context, id string) error
+	// RedefineNetwork will replace the network config and restart it when it is running. Interfaces of running
+	// domains are left off the restarted bridge
+	RedefineNetwork(ctx context.Context, net *libvirtxml.Network) error
+	AddNetworkDHCPHost(ctx context.Context, id string, host *libvirtxml.NetworkDHCPHost) error
+
+	// Domains, id can be a name or UUID
+	ListDomains(ctx context.Context) ([]*libvirtxml.Domain, error)
+	LookupDomain(ctx context.Context, id string) (*libvirtxml.Domain, error)
+	DefineDomain(ctx context.Context, dom *libvirtxml.Domain) error
+	StartDomain(ctx context.Context, id string) error
+	StopDomain(ctx context.Context, id string) error
+	UndefineDomain(ctx context.Context, id string) error
+	DomainIsActive(ctx context.Context, id string) (bool, error)
+	SuspendDomain(ctx context.Context, id string) error
+	ResumeDomain(ctx context.Context, id string) error
+	// ResetDomain will hard reset a running domain, like pressing its reset button
+	ResetDomain(ctx context.Context, id string) error
+	GetDomainMetadata(ctx context.Context, id string, uri string) (string, error)
+	SetDomainMetadata(ctx context.Context, id string, prefix string, uri string, metadata string) error
+	DomainStats(ctx context.Context, id string) (*DomainStats, error)
+	// UpdateDomainDisk will replace the disk with the same target (e.g. change the media of a cdrom)
+	// The persistent config is always updated, live also updates a running domain
+	UpdateDomainDisk(ctx context.Context, id string, disk *libvirtxml.DomainDisk, live bool) error
+	AttachDomainDisk(ctx context.Context, id string, disk *libvirtxml.DomainDisk, live bool) error
+	DetachDomainDisk(ctx context.Context, id string, disk *libvirtxml.DomainDisk, live bool) error
+	// UpdateDomainInterface will replace the interface with the same MAC address (e.g. change its link state)
+	UpdateDomainInterface(ctx context.Context, id string, iface *libvirtxml.DomainInterface, live bool) error
+
+	// WatchDomainEvents will call fn for each domain lifecycle event until ctx is done
+	WatchDomainEvents(ctx context.Context, fn func(DomainEvent)) error
+
+	// Storage
+	ListPools(ctx context.Context) ([]PoolInfo, error)
+	LookupPool(ctx context.Context, name string) (*PoolInfo, error)
+	CreatePool(ctx context.Context, pool *libvirtxml.StoragePool, autostart bool) error
+	DestroyPool(ctx context.Context, name string, deleteData bool) error
+	ListVolumes(ctx context.Context, pool string) ([]VolumeInfo, error)
+	LookupVolume(ctx context.Context, pool string, name string) (*VolumeInfo, error)
+	LookupVolumeByPath(ctx context.Context, path string) (*VolumeInfo, error)
+	CreateVolume(ctx context.Context, pool string, vol *libvirtxml.StorageVolume) (*VolumeInfo, error)
+	CloneVolume(ctx context.Context, pool string, source string, vol *libvirtxml.StorageVolume) (*VolumeInfo, error)
+	UploadVolume(ctx context.Context, pool string, vol *libvirtxml.StorageVolume, data io.Reader) (*VolumeInfo, error)
+	DeleteVolume(ctx context.Context, pool string, name string) error
+
+	Close() error
+}
+
+type HostInfo struct {
+	Arch            string // The cpu architecture, ex: x86_64
+	CPUs            uint
+	MemoryBytes     uint64
+	FreeMemoryBytes uint64
+}
+
+type PoolInfo struct {
+	Name      string
+	Active    bool
+	Autostart bool
+	Path      string
+	Capacity  uint64
+	Available uint64
+}
+
+type VolumeInfo struct {
+	Name       string
+	Pool       string
+	Path       string
+	Format     string
+	Capacity   uint64
+	Allocation uint64
+}
+
+// DomainStats is a sample of the resource usage of a running domain. Counters are totals since the domain started
+type DomainStats struct {
+	Time           time.Time
+	CPUTimeNs      uint64
+	MemoryBytes    uint64
+	RSSBytes       uint64
+	DiskReadBytes  uint64
+	DiskWriteBytes uint64
+	DiskReadReqs   uint64
+	DiskWriteReqs  uint64
+	NetRxBytes     uint64
+	NetTxBytes     uint64
+}
+
+type DomainEventType string
+
+const (
+	DOMAIN_EVENT_DEFINED   DomainEventType = "defined"
+	DOMAIN_EVENT_UNDEFINED DomainEventType = "undefined"
+	DOMAIN_EVENT_STARTED   DomainEventType = "started"
+	DOMAIN_EVENT_SUSPENDED DomainEventType = "suspended"
+	DOMAIN_EVENT_RESUMED   DomainEventType = "resumed"
+	DOMAIN_EVENT_STOPPED   DomainEventType = "stopped"
+	DOMAIN_EVENT_SHUTDOWN  DomainEventType = "shutdown"
+	DOMAIN_EVENT_CRASHED   DomainEventType = "crashed"
+	DOMAIN_EVENT_OTHER     DomainEventType = "other"
+)
+
+type DomainEvent struct {
+	Domain string
+	Type   DomainEventType
+}
+
+var (
+	defaultHypervisor Hypervisor
+	hvmux             sync.Mutex
+)
+
+// Get will return the shared hypervisor for the current command, creating it from the libvirt URI on first use
+// This is thread safe
+func Get() Hypervisor {
+	hvmux.Lock()
+	defer hvmux.Unlock()
+
+	if defaultHypervisor == nil {
+		uri := vmutils.GetLibvirtURI()
+		if uri == FAKE_URI {
+			defaultHypervisor = NewFake()
+		} else {
+			defaultHypervisor = NewLibvirt(vmutils.NewClient(uri))
+		}
+	}
+
+	return defaultHypervisor
+}
+
+// Set will replace the shared hypervisor, this is mostly useful to inject a fake
+// This is thread safe
+func Set(hv Hypervisor) {
+	hvmux.Lock()
+	defer hvmux.Unlock()
+
+	defaultHypervisor = hv
+}
+
+// Close will close the shared hypervisor if it was created
+// This is thread safe
+func Close() error {
+	hvmux.Lock()
+	defer hvmux.Unlock()
+
+	if defaultHypervisor == nil {
+		return nil
+	}
+
+	err := defaultHypervisor.Close()
+	defaultHypervisor = nil
+
+	return err
+}
+
+// IsSimulated will return true if the hypervisor does not run real machines (the fake or the libvirt test driver).
+// Host tools like virt-install and checks like kvm do not apply to it
+func IsSimulated() bool {
+	if _, ok := Get().(*Fake); ok {
+		return true
+	}
+
+	u, err := url.Parse(vmutils.GetLibvirtURI())
+	return err == nil && u.Scheme == "test"
+}
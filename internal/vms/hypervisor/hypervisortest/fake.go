@@ -0,0 +1,47 @@
+// Package hypervisortest has the helpers tests use to run snoman against the in-memory hypervisor
+package hypervisortest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"snoman/internal/vms/hypervisor"
+	"testing"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+// UseFake will point every package at an in-memory hypervisor for the rest of the test. Each of pools is
+// created as an active directory pool, ex: the pool of the default VM disk for tests that create VMs
+func UseFake(t *testing.T, pools ...string) *hypervisor.Fake {
+	t.Helper()
+
+	fake := hypervisor.NewFake()
+	hypervisor.Set(fake)
+	t.Cleanup(func() { hypervisor.Set(nil) })
+
+	for _, name := range pools {
+		pool := &libvirtxml.StoragePool{
+			Type:   "dir",
+			Name:   name,
+			Target: &libvirtxml.StoragePoolTarget{Path: t.TempDir()},
+		}
+		if err := fake.CreatePool(context.Background(), pool, true); err != nil {
+			t.Fatalf("unable to create the storage pool '%s': %v", name, err)
+		}
+	}
+
+	return fake
+}
+
+// WriteIso will create an ISO file called name in a temporary folder, only its path matters to the fake
+func WriteIso(t *testing.T, name string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("iso"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
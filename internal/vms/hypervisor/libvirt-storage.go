@@ -0,0 +1,426 @@
+package hypervisor
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+// findPool will find the pool by name. The caller must Free the returned pool
+func findPool(lvc *libvirt.Connect, name string) (*libvirt.StoragePool, error) {
+	pool, err := lvc.LookupStoragePoolByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: storage pool '%s'", ErrNotFound, name)
+	}
+
+	return pool, nil
+}
+
+func poolInfo(pool *libvirt.StoragePool) (*PoolInfo, error) {
+	name, err := pool.GetName()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get storage pool name: %w", err)
+	}
+
+	info := &PoolInfo{Name: name}
+	info.Active, _ = pool.IsActive()
+	info.Autostart, _ = pool.GetAutostart()
+
+	if lvinfo, err := pool.GetInfo(); err == nil {
+		info.Capacity = lvinfo.Capacity
+		info.Available = lvinfo.Available
+	}
+
+	if poolxml, err := pool.GetXMLDesc(0); err == nil {
+		poolcfg := &libvirtxml.StoragePool{}
+		if err := poolcfg.Unmarshal(poolxml); err == nil && poolcfg.Target != nil {
+			info.Path = poolcfg.Target.Path
+		}
+	}
+
+	return info, nil
+}
+
+func volumeInfo(vol *libvirt.StorageVol) (*VolumeInfo, error) {
+	info := &VolumeInfo{}
+
+	var err error
+	if info.Name, err = vol.GetName(); err != nil {
+		return nil, fmt.Errorf("unable to get volume name: %w", err)
+	}
+
+	if info.Path, err = vol.GetPath(); err != nil {
+		return nil, fmt.Errorf("unable to get the path of volume '%s': %w", info.Name, err)
+	}
+
+	if lvinfo, err := vol.GetInfo(); err == nil {
+		info.Capacity = lvinfo.Capacity
+		info.Allocation = lvinfo.Allocation
+	}
+
+	if volxml, err := vol.GetXMLDesc(0); err == nil {
+		volcfg := &libvirtxml.StorageVolume{}
+		if err := volcfg.Unmarshal(volxml); err == nil && volcfg.Target != nil && volcfg.Target.Format != nil {
+			info.Format = volcfg.Target.Format.Type
+		}
+	}
+
+	if pool, err := vol.LookupPoolByVolume(); err == nil {
+		info.Pool, _ = pool.GetName()
+		pool.Free()
+	}
+
+	return info, nil
+}
+
+func (l *Libvirt) ListPools(ctx context.Context) ([]PoolInfo, error) {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pools, err := lvc.ListAllStoragePools(0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list storage pools: %w", err)
+	}
+
+	for i := range pools {
+		defer pools[i].Free()
+	}
+
+	infos := make([]PoolInfo, 0, len(pools))
+	for i := range pools {
+		info, err := poolInfo(&pools[i])
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, *info)
+	}
+
+	return infos, nil
+}
+
+func (l *Libvirt) LookupPool(ctx context.Context, name string) (*PoolInfo, error) {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := findPool(lvc, name)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Free()
+
+	return poolInfo(pool)
+}
+
+// CreatePool will define, build and start the pool
+func (l *Libvirt) CreatePool(ctx context.Context, poolcfg *libvirtxml.StoragePool, autostart bool) error {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	poolxml, err := poolcfg.Marshal()
+	if err != nil {
+		return fmt.Errorf("unable to generate storage pool xml: %w", err)
+	}
+
+	pool, err := lvc.StoragePoolDefineXML(poolxml, 0)
+	if err != nil {
+		return fmt.Errorf("unable to define the storage pool: %w", err)
+	}
+	defer pool.Free()
+
+	// Build creates the target directory
+	if err := pool.Build(libvirt.STORAGE_POOL_BUILD_NEW); err != nil {
+		pool.Undefine()
+		return fmt.Errorf("unable to build the storage pool: %w", err)
+	}
+
+	if err := pool.SetAutostart(autostart); err != nil {
+		return fmt.Errorf("unable to set the storage pool autostart: %w", err)
+	}
+
+	if err := pool.Create(0); err != nil {
+		return fmt.Errorf("unable to start the storage pool: %w", err)
+	}
+
+	return nil
+}
+
+// DestroyPool will stop and undefine the pool. If deleteData is set, the volumes and directory are removed too
+func (l *Libvirt) DestroyPool(ctx context.Context, name string, deleteData bool) error {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	pool, err := findPool(lvc, name)
+	if err != nil {
+		return err
+	}
+	defer pool.Free()
+
+	if deleteData {
+		if active, _ := pool.IsActive(); !active {
+			if err := pool.Create(0); err != nil {
+				return fmt.Errorf("unable to start the storage pool to delete its volumes: %w", err)
+			}
+		}
+
+		vols, err := pool.ListAllStorageVolumes(0)
+		if err != nil {
+			return fmt.Errorf("unable to list the storage pool volumes: %w", err)
+		}
+
+		for i := range vols {
+			defer vols[i].Free()
+		}
+
+		for i := range vols {
+			volname, _ := vols[i].GetName()
+			if err := vols[i].Delete(libvirt.STORAGE_VOL_DELETE_NORMAL); err != nil {
+				return fmt.Errorf("unable to delete volume '%s': %w", volname, err)
+			}
+		}
+	}
+
+	if active, _ := pool.IsActive(); active {
+		if err := pool.Destroy(); err != nil {
+			return fmt.Errorf("could not stop the storage pool: %w", err)
+		}
+	}
+
+	if deleteData {
+		if err := pool.Delete(libvirt.STORAGE_POOL_DELETE_NORMAL); err != nil {
+			return fmt.Errorf("could not delete the storage pool directory: %w", err)
+		}
+	}
+
+	if err := pool.Undefine(); err != nil {
+		return fmt.Errorf("could not undefine the storage pool: %w", err)
+	}
+
+	return nil
+}
+
+func (l *Libvirt) ListVolumes(ctx context.Context, poolName string) ([]VolumeInfo, error) {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := findPool(lvc, poolName)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Free()
+
+	vols, err := pool.ListAllStorageVolumes(0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list volumes in pool '%s': %w", poolName, err)
+	}
+
+	for i := range vols {
+		defer vols[i].Free()
+	}
+
+	infos := make([]VolumeInfo, 0, len(vols))
+	for i := range vols {
+		info, err := volumeInfo(&vols[i])
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, *info)
+	}
+
+	return infos, nil
+}
+
+// withVolume will look up the volume in the pool and run fn with it
+func (l *Libvirt) withVolume(ctx context.Context, poolName string, name string, fn func(*libvirt.StorageVol) error) error {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	pool, err := findPool(lvc, poolName)
+	if err != nil {
+		return err
+	}
+	defer pool.Free()
+
+	vol, err := pool.LookupStorageVolByName(name)
+	if err != nil {
+		return fmt.Errorf("%w: volume '%s' in pool '%s'", ErrNotFound, name, poolName)
+	}
+	defer vol.Free()
+
+	return fn(vol)
+}
+
+func (l *Libvirt) LookupVolume(ctx context.Context, poolName string, name string) (*VolumeInfo, error) {
+	var info *VolumeInfo
+
+	err := l.withVolume(ctx, poolName, name, func(vol *libvirt.StorageVol) error {
+		var err error
+		info, err = volumeInfo(vol)
+		return err
+	})
+
+	return info, err
+}
+
+func (l *Libvirt) LookupVolumeByPath(ctx context.Context, path string) (*VolumeInfo, error) {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	vol, err := lvc.LookupStorageVolByPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: volume at '%s'", ErrNotFound, path)
+	}
+	defer vol.Free()
+
+	return volumeInfo(vol)
+}
+
+func (l *Libvirt) CreateVolume(ctx context.Context, poolName string, volcfg *libvirtxml.StorageVolume) (*VolumeInfo, error) {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := findPool(lvc, poolName)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Free()
+
+	volxml, err := volcfg.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate volume xml: %w", err)
+	}
+
+	vol, err := pool.StorageVolCreateXML(volxml, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create volume '%s' in pool '%s': %w", volcfg.Name, poolName, err)
+	}
+	defer vol.Free()
+
+	return volumeInfo(vol)
+}
+
+func (l *Libvirt) CloneVolume(ctx context.Context, poolName string, source string, volcfg *libvirtxml.StorageVolume) (*VolumeInfo, error) {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := findPool(lvc, poolName)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Free()
+
+	srcvol, err := pool.LookupStorageVolByName(source)
+	if err != nil {
+		return nil, fmt.Errorf("%w: volume '%s' in pool '%s'", ErrNotFound, source, poolName)
+	}
+	defer srcvol.Free()
+
+	volxml, err := volcfg.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate volume xml: %w", err)
+	}
+
+	vol, err := pool.StorageVolCreateXMLFrom(volxml, srcvol, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to clone volume '%s' to '%s': %w", source, volcfg.Name, err)
+	}
+	defer vol.Free()
+
+	return volumeInfo(vol)
+}
+
+// UploadVolume will create the volume and stream data into it. The volume capacity must match the data length
+func (l *Libvirt) UploadVolume(ctx context.Context, poolName string, volcfg *libvirtxml.StorageVolume, data io.Reader) (*VolumeInfo, error) {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := findPool(lvc, poolName)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Free()
+
+	volxml, err := volcfg.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate volume xml: %w", err)
+	}
+
+	vol, err := pool.StorageVolCreateXML(volxml, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create volume '%s' in pool '%s': %w", volcfg.Name, poolName, err)
+	}
+	defer vol.Free()
+
+	if err := uploadToVolume(lvc, vol, volcfg.Capacity.Value, data); err != nil {
+		vol.Delete(libvirt.STORAGE_VOL_DELETE_NORMAL)
+		return nil, err
+	}
+
+	return volumeInfo(vol)
+}
+
+func uploadToVolume(lvc *libvirt.Connect, vol *libvirt.StorageVol, length uint64, data io.Reader) error {
+	stream, err := lvc.NewStream(0)
+	if err != nil {
+		return fmt.Errorf("unable to create upload stream: %w", err)
+	}
+	defer stream.Free()
+
+	if err := vol.Upload(stream, 0, length, 0); err != nil {
+		return fmt.Errorf("unable to start upload: %w", err)
+	}
+
+	err = stream.SendAll(func(s *libvirt.Stream, nbytes int) ([]byte, error) {
+		buf := make([]byte, nbytes)
+		n, err := data.Read(buf)
+		if err == io.EOF {
+			return []byte{}, nil
+		}
+
+		return buf[:n], err
+	})
+	if err != nil {
+		stream.Abort()
+		return fmt.Errorf("unable to upload volume data: %w", err)
+	}
+
+	if err := stream.Finish(); err != nil {
+		return fmt.Errorf("unable to finish the volume upload: %w", err)
+	}
+
+	return nil
+}
+
+func (l *Libvirt) DeleteVolume(ctx context.Context, poolName string, name string) error {
+	return l.withVolume(ctx, poolName, name, func(vol *libvirt.StorageVol) error {
+		if err := vol.Delete(libvirt.STORAGE_VOL_DELETE_NORMAL); err != nil {
+			return fmt.Errorf("unable to delete volume '%s': %w", name, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,591 @@
+package hypervisor
+
+import (
+	"context"
+	"fmt"
+	"snoman/internal/logger"
+	vmutils "snoman/internal/vms/utils"
+	"time"
+
+	"libvirt.org/go/libvirt"
+	"libvirt.org/go/libvirtxml"
+)
+
+// Libvirt is the Hypervisor backed by a real libvirt connection (qemu, remote or the test:///default driver)
+type Libvirt struct {
+	client *vmutils.Client
+}
+
+func NewLibvirt(client *vmutils.Client) *Libvirt {
+	return &Libvirt{client: client}
+}
+
+func (l *Libvirt) Close() error {
+	return l.client.Close()
+}
+
+func (l *Libvirt) conn(ctx context.Context) (*libvirt.Connect, error) {
+	lvc, err := l.client.Connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize libvirt connection: %w", err)
+	}
+
+	return lvc, nil
+}
+
+func (l *Libvirt) HostInfo(ctx context.Context) (*HostInfo, error) {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := lvc.GetNodeInfo()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get host info: %w", err)
+	}
+
+	free, err := lvc.GetFreeMemory()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get free host memory: %w", err)
+	}
+
+	return &HostInfo{
+		Arch:            node.Model,
+		CPUs:            node.Cpus,
+		MemoryBytes:     node.Memory * 1024,
+		FreeMemoryBytes: free,
+	}, nil
+}
+
+// Networks
+
+// findNetwork will try to find the network by name or UUID. The caller must Free the returned network
+func findNetwork(lvc *libvirt.Connect, id string) (*libvirt.Network, error) {
+	if net, err := lvc.LookupNetworkByName(id); err == nil {
+		return net, nil
+	}
+
+	if net, err := lvc.LookupNetworkByUUIDString(id); err == nil {
+		return net, nil
+	}
+
+	return nil, fmt.Errorf("%w: network '%s'", ErrNotFound, id)
+}
+
+func (l *Libvirt) LookupNetwork(ctx context.Context, id string) (*libvirtxml.Network, error) {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	net, err := findNetwork(lvc, id)
+	if err != nil {
+		return nil, err
+	}
+	defer net.Free()
+
+	netxml, err := net.GetXMLDesc(0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get libvirt network xml description: %w", err)
+	}
+
+	netcfg := &libvirtxml.Network{}
+	if err := netcfg.Unmarshal(netxml); err != nil {
+		return nil, fmt.Errorf("unable to parse libvirt network xml: %w", err)
+	}
+
+	return netcfg, nil
+}
+
+// CreateNetwork will define the network, set it to autostart and start it
+func (l *Libvirt) CreateNetwork(ctx context.Context, netcfg *libvirtxml.Network) error {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	netxml, err := netcfg.Marshal()
+	if err != nil {
+		return fmt.Errorf("unable to generate network xml: %w", err)
+	}
+
+	net, err := lvc.NetworkDefineXML(netxml)
+	if err != nil {
+		return fmt.Errorf("unable to define the network: %w", err)
+	}
+	defer net.Free()
+
+	if err := net.SetAutostart(true); err != nil {
+		return fmt.Errorf("unable to set the network to autostart: %w", err)
+	}
+
+	if err := net.Create(); err != nil {
+		return fmt.Errorf("unable to start the network: %w", err)
+	}
+
+	return nil
+}
+
+// DestroyNetwork will stop the network if it is running and undefine it
+func (l *Libvirt) DestroyNetwork(ctx context.Context, id string) error {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	net, err := findNetwork(lvc, id)
+	if err != nil {
+		return err
+	}
+	defer net.Free()
+
+	if active, _ := net.IsActive(); active {
+		if err := net.Destroy(); err != nil {
+			return fmt.Errorf("could not stop the network: %w", err)
+		}
+	}
+
+	if err := net.Undefine(); err != nil {
+		return fmt.Errorf("could not undefine the network: %w", err)
+	}
+
+	return nil
+}
+
+// RedefineNetwork will define the new network config over the existing one and restart the network so it applies
+func (l *Libvirt) RedefineNetwork(ctx context.Context, netcfg *libvirtxml.Network) error {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	netxml, err := netcfg.Marshal()
+	if err != nil {
+		return fmt.Errorf("unable to generate network xml: %w", err)
+	}
+
+	net, err := lvc.NetworkDefineXML(netxml)
+	if err != nil {
+		return fmt.Errorf("unable to redefine the network: %w", err)
+	}
+	defer net.Free()
+
+	if active, _ := net.IsActive(); !active {
+		return nil
+	}
+
+	if err := net.Destroy(); err != nil {
+		return fmt.Errorf("could not stop the network: %w", err)
+	}
+
+	if err := net.Create(); err != nil {
+		return fmt.Errorf("unable to restart the network: %w", err)
+	}
+
+	return nil
+}
+
+// AddNetworkDHCPHost will add a static DHCP host to the running network
+func (l *Libvirt) AddNetworkDHCPHost(ctx context.Context, id string, host *libvirtxml.NetworkDHCPHost) error {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	net, err := findNetwork(lvc, id)
+	if err != nil {
+		return err
+	}
+	defer net.Free()
+
+	hostxml, err := host.Marshal()
+	if err != nil {
+		return fmt.Errorf("could not generate host xml: %w", err)
+	}
+
+	err = net.Update(
+		libvirt.NETWORK_UPDATE_COMMAND_ADD_LAST,
+		libvirt.NETWORK_SECTION_IP_DHCP_HOST,
+		0,
+		hostxml,
+		libvirt.NETWORK_UPDATE_AFFECT_LIVE)
+
+	if err != nil {
+		return fmt.Errorf("could not update network '%s': %w", id, err)
+	}
+
+	return nil
+}
+
+// Domains
+
+// findDomain will try to find the domain by name or UUID. The caller must Free the returned domain
+func findDomain(lvc *libvirt.Connect, id string) (*libvirt.Domain, error) {
+	if dom, err := lvc.LookupDomainByName(id); err == nil {
+		return dom, nil
+	}
+
+	if dom, err := lvc.LookupDomainByUUIDString(id); err == nil {
+		return dom, nil
+	}
+
+	return nil, fmt.Errorf("%w: domain '%s'", ErrNotFound, id)
+}
+
+// withDomain will look up the domain and run fn with it
+func (l *Libvirt) withDomain(ctx context.Context, id string, fn func(*libvirt.Domain) error) error {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	dom, err := findDomain(lvc, id)
+	if err != nil {
+		return err
+	}
+	defer dom.Free()
+
+	return fn(dom)
+}
+
+// ListDomains will return the persistent config of every domain, running or not
+func (l *Libvirt) ListDomains(ctx context.Context) ([]*libvirtxml.Domain, error) {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doms, err := lvc.ListAllDomains(0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list domains: %w", err)
+	}
+
+	for i := range doms {
+		defer doms[i].Free()
+	}
+
+	domcfgs := make([]*libvirtxml.Domain, 0, len(doms))
+	for i := range doms {
+		domxml, err := doms[i].GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get libvirt domain xml description: %w", err)
+		}
+
+		domcfg := &libvirtxml.Domain{}
+		if err := domcfg.Unmarshal(domxml); err != nil {
+			return nil, fmt.Errorf("unable to parse libvirt domain xml: %w", err)
+		}
+
+		domcfgs = append(domcfgs, domcfg)
+	}
+
+	return domcfgs, nil
+}
+
+// LookupDomain will return the persistent (inactive) configuration of the domain
+func (l *Libvirt) LookupDomain(ctx context.Context, id string) (*libvirtxml.Domain, error) {
+	domcfg := &libvirtxml.Domain{}
+
+	err := l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		domxml, err := dom.GetXMLDesc(libvirt.DOMAIN_XML_INACTIVE)
+		if err != nil {
+			return fmt.Errorf("unable to get libvirt domain xml description: %w", err)
+		}
+
+		if err := domcfg.Unmarshal(domxml); err != nil {
+			return fmt.Errorf("unable to parse libvirt domain xml: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return domcfg, nil
+}
+
+func (l *Libvirt) DefineDomain(ctx context.Context, domcfg *libvirtxml.Domain) error {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	domxml, err := domcfg.Marshal()
+	if err != nil {
+		return fmt.Errorf("unable to generate domain xml: %w", err)
+	}
+
+	dom, err := lvc.DomainDefineXML(domxml)
+	if err != nil {
+		return fmt.Errorf("unable to define the domain: %w", err)
+	}
+
+	return dom.Free()
+}
+
+func (l *Libvirt) StartDomain(ctx context.Context, id string) error {
+	return l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		if err := dom.Create(); err != nil {
+			return fmt.Errorf("unable to start domain '%s': %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+// StopDomain will force off the domain
+func (l *Libvirt) StopDomain(ctx context.Context, id string) error {
+	return l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		if err := dom.Destroy(); err != nil {
+			return fmt.Errorf("unable to stop domain '%s': %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+func (l *Libvirt) UndefineDomain(ctx context.Context, id string) error {
+	return l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		if err := dom.Undefine(); err != nil {
+			return fmt.Errorf("unable to undefine domain '%s': %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+func (l *Libvirt) DomainIsActive(ctx context.Context, id string) (bool, error) {
+	var active bool
+
+	err := l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		var err error
+		active, err = dom.IsActive()
+		return err
+	})
+
+	return active, err
+}
+
+func (l *Libvirt) SuspendDomain(ctx context.Context, id string) error {
+	return l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		if err := dom.Suspend(); err != nil {
+			return fmt.Errorf("unable to suspend domain '%s': %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+func (l *Libvirt) ResumeDomain(ctx context.Context, id string) error {
+	return l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		if err := dom.Resume(); err != nil {
+			return fmt.Errorf("unable to resume domain '%s': %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+func (l *Libvirt) ResetDomain(ctx context.Context, id string) error {
+	return l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		if err := dom.Reset(0); err != nil {
+			return fmt.Errorf("unable to reset domain '%s': %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+func (l *Libvirt) GetDomainMetadata(ctx context.Context, id string, uri string) (string, error) {
+	var metadata string
+
+	err := l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		var err error
+		metadata, err = dom.GetMetadata(libvirt.DOMAIN_METADATA_ELEMENT, uri, libvirt.DOMAIN_AFFECT_CONFIG)
+		if err != nil {
+			return fmt.Errorf("%w: metadata '%s' on domain '%s'", ErrNotFound, uri, id)
+		}
+
+		return nil
+	})
+
+	return metadata, err
+}
+
+func (l *Libvirt) SetDomainMetadata(ctx context.Context, id string, prefix string, uri string, metadata string) error {
+	return l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		if err := dom.SetMetadata(libvirt.DOMAIN_METADATA_ELEMENT, metadata, prefix, uri, libvirt.DOMAIN_AFFECT_CONFIG); err != nil {
+			return fmt.Errorf("unable to set metadata on domain '%s': %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+// DomainStats will sample the cpu, balloon, block and interface stats of the running domain
+func (l *Libvirt) DomainStats(ctx context.Context, id string) (*DomainStats, error) {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dom, err := findDomain(lvc, id)
+	if err != nil {
+		return nil, err
+	}
+	defer dom.Free()
+
+	statTypes := libvirt.DOMAIN_STATS_CPU_TOTAL | libvirt.DOMAIN_STATS_BALLOON | libvirt.DOMAIN_STATS_BLOCK | libvirt.DOMAIN_STATS_INTERFACE
+	lvstats, err := lvc.GetAllDomainStats([]*libvirt.Domain{dom}, statTypes, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get stats for domain '%s': %w", id, err)
+	}
+
+	for i := range lvstats {
+		defer lvstats[i].Domain.Free()
+	}
+
+	if len(lvstats) == 0 {
+		return nil, fmt.Errorf("no stats returned for domain '%s'", id)
+	}
+
+	lvs := lvstats[0]
+	stats := &DomainStats{Time: time.Now()}
+
+	if lvs.Cpu != nil {
+		stats.CPUTimeNs = lvs.Cpu.Time
+	}
+
+	// Balloon values are in KiB
+	if lvs.Balloon != nil {
+		stats.MemoryBytes = lvs.Balloon.Current * 1024
+		stats.RSSBytes = lvs.Balloon.Rss * 1024
+	}
+
+	for _, block := range lvs.Block {
+		stats.DiskReadBytes += block.RdBytes
+		stats.DiskWriteBytes += block.WrBytes
+		stats.DiskReadReqs += block.RdReqs
+		stats.DiskWriteReqs += block.WrReqs
+	}
+
+	for _, net := range lvs.Net {
+		stats.NetRxBytes += net.RxBytes
+		stats.NetTxBytes += net.TxBytes
+	}
+
+	return stats, nil
+}
+
+func (l *Libvirt) UpdateDomainDisk(ctx context.Context, id string, disk *libvirtxml.DomainDisk, live bool) error {
+	return l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		diskxml, err := disk.Marshal()
+		if err != nil {
+			return fmt.Errorf("unable to generate disk xml: %w", err)
+		}
+
+		if err := dom.UpdateDeviceFlags(diskxml, deviceModifyFlags(dom, live)); err != nil {
+			return fmt.Errorf("unable to update disk '%s' of domain '%s': %w", disk.Target.Dev, id, err)
+		}
+
+		return nil
+	})
+}
+
+func (l *Libvirt) AttachDomainDisk(ctx context.Context, id string, disk *libvirtxml.DomainDisk, live bool) error {
+	return l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		diskxml, err := disk.Marshal()
+		if err != nil {
+			return fmt.Errorf("unable to generate disk xml: %w", err)
+		}
+
+		if err := dom.AttachDeviceFlags(diskxml, deviceModifyFlags(dom, live)); err != nil {
+			return fmt.Errorf("unable to attach disk '%s' to domain '%s': %w", disk.Target.Dev, id, err)
+		}
+
+		return nil
+	})
+}
+
+func (l *Libvirt) DetachDomainDisk(ctx context.Context, id string, disk *libvirtxml.DomainDisk, live bool) error {
+	return l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		diskxml, err := disk.Marshal()
+		if err != nil {
+			return fmt.Errorf("unable to generate disk xml: %w", err)
+		}
+
+		if err := dom.DetachDeviceFlags(diskxml, deviceModifyFlags(dom, live)); err != nil {
+			return fmt.Errorf("unable to detach disk '%s' from domain '%s': %w", disk.Target.Dev, id, err)
+		}
+
+		return nil
+	})
+}
+
+func (l *Libvirt) UpdateDomainInterface(ctx context.Context, id string, iface *libvirtxml.DomainInterface, live bool) error {
+	return l.withDomain(ctx, id, func(dom *libvirt.Domain) error {
+		ifacexml, err := iface.Marshal()
+		if err != nil {
+			return fmt.Errorf("unable to generate interface xml: %w", err)
+		}
+
+		if err := dom.UpdateDeviceFlags(ifacexml, deviceModifyFlags(dom, live)); err != nil {
+			return fmt.Errorf("unable to update interface '%s' of domain '%s': %w", iface.MAC.Address, id, err)
+		}
+
+		return nil
+	})
+}
+
+// deviceModifyFlags will always change the persistent config and the live domain too if requested and running
+func deviceModifyFlags(dom *libvirt.Domain, live bool) libvirt.DomainDeviceModifyFlags {
+	flags := libvirt.DOMAIN_DEVICE_MODIFY_CONFIG
+	if active, _ := dom.IsActive(); live && active {
+		flags |= libvirt.DOMAIN_DEVICE_MODIFY_LIVE
+	}
+
+	return flags
+}
+
+var lifecycleEvents = map[libvirt.DomainEventType]DomainEventType{
+	libvirt.DOMAIN_EVENT_DEFINED:   DOMAIN_EVENT_DEFINED,
+	libvirt.DOMAIN_EVENT_UNDEFINED: DOMAIN_EVENT_UNDEFINED,
+	libvirt.DOMAIN_EVENT_STARTED:   DOMAIN_EVENT_STARTED,
+	libvirt.DOMAIN_EVENT_SUSPENDED: DOMAIN_EVENT_SUSPENDED,
+	libvirt.DOMAIN_EVENT_RESUMED:   DOMAIN_EVENT_RESUMED,
+	libvirt.DOMAIN_EVENT_STOPPED:   DOMAIN_EVENT_STOPPED,
+	libvirt.DOMAIN_EVENT_SHUTDOWN:  DOMAIN_EVENT_SHUTDOWN,
+	libvirt.DOMAIN_EVENT_CRASHED:   DOMAIN_EVENT_CRASHED,
+}
+
+func (l *Libvirt) WatchDomainEvents(ctx context.Context, fn func(DomainEvent)) error {
+	lvc, err := l.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	callbackID, err := lvc.DomainEventLifecycleRegister(nil, func(c *libvirt.Connect, d *libvirt.Domain, event *libvirt.DomainEventLifecycle) {
+		name, _ := d.GetName()
+
+		eventType, ok := lifecycleEvents[event.Event]
+		if !ok {
+			eventType = DOMAIN_EVENT_OTHER
+		}
+
+		fn(DomainEvent{Domain: name, Type: eventType})
+	})
+	if err != nil {
+		return fmt.Errorf("unable to register for domain events: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := lvc.DomainEventDeregister(callbackID); err != nil {
+			logger.Get().Debugf("unable to deregister domain events: %v", err)
+		}
+	}()
+
+	return nil
+}
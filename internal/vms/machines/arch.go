@@ -0,0 +1,72 @@
+package machines
+
+import (
+	"context"
+	"snoman/internal/arch"
+	"snoman/internal/logger"
+	"snoman/internal/vms/hypervisor"
+)
+
+// machineTypes are the libvirt machine types of each guest architecture
+var machineTypes = map[string]string{
+	arch.X86_64:  "q35",
+	arch.AARCH64: "virt",
+}
+
+// cdromBuses are the buses a cdrom can be attached to, the aarch64 virt machine has no sata controller
+var cdromBuses = map[string]string{
+	arch.X86_64:  "sata",
+	arch.AARCH64: "scsi",
+}
+
+// hostArch will ask the hypervisor for its architecture
+func hostArch(ctx context.Context) string {
+	host, err := hypervisor.Get().HostInfo(ctx)
+	if err != nil {
+		logger.Get().Debugf("unable to get the hypervisor architecture, assuming %s: %v", arch.Host(), err)
+		return arch.Host()
+	}
+
+	return normalizeHostArch(host)
+}
+
+// normalizeHostArch will return the kernel name of the hypervisor architecture, or of this host if the
+// hypervisor does not say
+func normalizeHostArch(host *hypervisor.HostInfo) string {
+	if name, err := arch.Normalize(host.Arch); err == nil && name != "" {
+		return name
+	}
+
+	return arch.Host()
+}
+
+// guestArch will return the architecture of the guest and whether it has to be emulated on a hypervisor
+// of the host architecture
+func (spec VirtualMachineSpec) guestArch(host string) (string, bool) {
+	guest, err := arch.Normalize(spec.Arch)
+	if err != nil || guest == "" {
+		return host, false
+	}
+
+	return guest, guest != host
+}
+
+// virtInstallArchArgs are the virt-install arguments that create the guest as its architecture. A foreign
+// architecture is emulated by qemu, with every cpu feature it can emulate
+func (spec VirtualMachineSpec) virtInstallArchArgs(host string) []string {
+	guest, emulated := spec.guestArch(host)
+	if !emulated {
+		return nil
+	}
+
+	return []string{"--arch", guest, "--virt-type", "qemu", "--cpu", "max"}
+}
+
+// cdromBus will return the bus cdroms are attached to on a guest of the architecture
+func cdromBus(guest string) string {
+	if bus, ok := cdromBuses[guest]; ok {
+		return bus
+	}
+
+	return cdromBuses[arch.X86_64]
+}
@@ -0,0 +1,79 @@
+package machines
+
+import (
+	"fmt"
+	"snoman/internal/arch"
+	"strings"
+	"testing"
+)
+
+func TestValidateArch(t *testing.T) {
+	tests := []struct {
+		name    string
+		arch    string
+		wantErr string
+	}{
+		{name: "hypervisor architecture"},
+		{name: "kernel name", arch: arch.AARCH64},
+		{name: "go name", arch: "arm64"},
+		{name: "upper case", arch: "X86_64"},
+		{name: "power", arch: arch.PPC64LE},
+		{name: "z", arch: arch.S390X},
+		{name: "unknown", arch: "mips", wantErr: "unsupported architecture 'mips'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GetDefaultVirtualMachineSpec()
+			spec.Arch = tt.arch
+
+			err := spec.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalXMLArch(t *testing.T) {
+	tests := []struct {
+		name    string
+		arch    string
+		want    string
+		wantErr string
+	}{
+		{name: "x86_64", arch: "x86_64", want: arch.X86_64},
+		{name: "aarch64", arch: "aarch64", want: arch.AARCH64},
+		{name: "ppc64le", arch: "ppc64le", want: arch.PPC64LE},
+		{name: "s390x", arch: "s390x", want: arch.S390X},
+		{name: "unknown", arch: "riscv64", wantErr: "domain 'sno' has an unsupported architecture"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domxml := fmt.Sprintf(`<domain type="kvm"><name>sno</name><os><type arch="%s">hvm</type></os></domain>`, tt.arch)
+
+			spec := &VirtualMachineSpec{}
+			err := spec.UnmarshalXML([]byte(domxml))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("UnmarshalXML() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnmarshalXML() error = %v", err)
+			}
+
+			if spec.Arch != tt.want {
+				t.Errorf("UnmarshalXML() arch = %s, want %s", spec.Arch, tt.want)
+			}
+		})
+	}
+}
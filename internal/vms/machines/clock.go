@@ -0,0 +1,150 @@
+package machines
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"snoman/internal/logger"
+	"snoman/internal/vms/hypervisor"
+	"strconv"
+	"time"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+// A clock offset is an optional sign followed by amounts, ex: 90d, 1y6mo, -12h
+var (
+	clockOffsetRegex     = regexp.MustCompile(`^[+-]?(\d+(mo|y|w|d|h|m|s))+$`)
+	clockOffsetPartRegex = regexp.MustCompile(`(\d+)(mo|y|w|d|h|m|s)`)
+)
+
+// ClockOffsetSeconds will convert the offset to seconds from now. Months and years are calendar months and
+// years, so the result depends on now
+func ClockOffsetSeconds(offset string, now time.Time) (int64, error) {
+	if !clockOffsetRegex.MatchString(offset) {
+		return 0, fmt.Errorf("invalid clock offset '%s', expected amounts of y, mo, w, d, h, m or s, ex: 90d, 1y6mo, -12h", offset)
+	}
+
+	sign := 1
+	if offset[0] == '-' {
+		sign = -1
+	}
+
+	then := now
+	for _, part := range clockOffsetPartRegex.FindAllStringSubmatch(offset, -1) {
+		amount, err := strconv.Atoi(part[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid clock offset '%s': %w", offset, err)
+		}
+		amount *= sign
+
+		switch part[2] {
+		case "y":
+			then = then.AddDate(amount, 0, 0)
+		case "mo":
+			then = then.AddDate(0, amount, 0)
+		case "w":
+			then = then.AddDate(0, 0, 7*amount)
+		case "d":
+			then = then.AddDate(0, 0, amount)
+		case "h":
+			then = then.Add(time.Duration(amount) * time.Hour)
+		case "m":
+			then = then.Add(time.Duration(amount) * time.Minute)
+		case "s":
+			then = then.Add(time.Duration(amount) * time.Second)
+		}
+	}
+
+	return int64(then.Sub(now) / time.Second), nil
+}
+
+// clockToLibvirtxml will start the guest clock adjustment seconds away from the host clock
+func clockToLibvirtxml(clock *libvirtxml.DomainClock, adjustment int64) *libvirtxml.DomainClock {
+	if clock == nil {
+		clock = &libvirtxml.DomainClock{}
+	}
+
+	if adjustment == 0 {
+		clock.Offset = "utc"
+		clock.Basis = ""
+		clock.Adjustment = ""
+
+		return clock
+	}
+
+	clock.Offset = "variable"
+	clock.Basis = "utc"
+	clock.Adjustment = strconv.FormatInt(adjustment, 10)
+
+	return clock
+}
+
+// clockAdjustment is how many seconds the guest clock is set away from the host clock
+func clockAdjustment(clock *libvirtxml.DomainClock) int64 {
+	if clock == nil || clock.Offset != "variable" {
+		return 0
+	}
+
+	adjustment, _ := strconv.ParseInt(clock.Adjustment, 10, 64)
+
+	return adjustment
+}
+
+// GetClockOffset will return how many seconds the guest clock of the VM is set away from the host clock
+func GetClockOffset(ctx context.Context, id string) (int64, error) {
+	domcfg, err := hypervisor.Get().LookupDomain(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("could not find domain by identifier '%s': %w", id, err)
+	}
+
+	return clockAdjustment(domcfg.Clock), nil
+}
+
+// SetClockOffset will move the guest clock of the VM offset away from now, an empty offset resets it to the host clock.
+// The guest reads its clock when it boots, so the new offset applies from the next start
+func SetClockOffset(ctx context.Context, id string, offset string) (int64, error) {
+	hv := hypervisor.Get()
+
+	var adjustment int64
+	if offset != "" {
+		var err error
+		adjustment, err = ClockOffsetSeconds(offset, time.Now())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	domcfg, err := hv.LookupDomain(ctx, id)
+	if err != nil {
+		return 0, fmt.Errorf("could not find domain by identifier '%s': %w", id, err)
+	}
+
+	domcfg.Clock = clockToLibvirtxml(domcfg.Clock, adjustment)
+
+	if err := hv.DefineDomain(ctx, domcfg); err != nil {
+		return 0, fmt.Errorf("unable to set the clock offset of '%s': %w", id, err)
+	}
+
+	logger.Get().Infow("successfully set clock offset", "vm", id, "offset", offset, "seconds", adjustment)
+
+	return adjustment, nil
+}
+
+// RestartVirtualMachine will power cycle the VM, or start it if it is off
+func RestartVirtualMachine(ctx context.Context, id string) error {
+	hv := hypervisor.Get()
+
+	active, err := hv.DomainIsActive(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not find domain by identifier '%s': %w", id, err)
+	}
+
+	if active {
+		if err := hv.StopDomain(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return hv.StartDomain(ctx, id)
+}
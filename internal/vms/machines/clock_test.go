@@ -0,0 +1,114 @@
+package machines
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClockOffsetSeconds(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC)
+	day := int64(24 * 60 * 60)
+
+	tests := []struct {
+		name    string
+		offset  string
+		want    int64
+		wantErr string
+	}{
+		{
+			name:   "days",
+			offset: "90d",
+			want:   90 * day,
+		},
+		{
+			name:   "weeks",
+			offset: "2w",
+			want:   14 * day,
+		},
+		{
+			name:   "explicit plus sign",
+			offset: "+30s",
+			want:   30,
+		},
+		{
+			name:   "negative hours",
+			offset: "-12h",
+			want:   -12 * 60 * 60,
+		},
+		{
+			name:   "calendar year after the leap day",
+			offset: "1y",
+			want:   365 * day,
+		},
+		{
+			name:   "years and months",
+			offset: "1y6mo",
+			want:   (365 + 184) * day,
+		},
+		{
+			name:   "the sign applies to every amount",
+			offset: "-1y6mo",
+			want:   -(365 + 182) * day,
+		},
+		{
+			name:   "mo is months",
+			offset: "1mo",
+			want:   31 * day,
+		},
+		{
+			name:   "m is minutes",
+			offset: "1m",
+			want:   60,
+		},
+		{
+			name:   "minutes and months",
+			offset: "1m1mo",
+			want:   31*day + 60,
+		},
+		{
+			name:    "empty",
+			offset:  "",
+			wantErr: "invalid clock offset ''",
+		},
+		{
+			name:    "amount without a unit",
+			offset:  "10",
+			wantErr: "invalid clock offset '10'",
+		},
+		{
+			name:    "unknown unit",
+			offset:  "1x",
+			wantErr: "invalid clock offset '1x'",
+		},
+		{
+			name:    "unit without an amount",
+			offset:  "mo",
+			wantErr: "invalid clock offset 'mo'",
+		},
+		{
+			name:    "sign inside the offset",
+			offset:  "1d-2h",
+			wantErr: "invalid clock offset '1d-2h'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ClockOffsetSeconds(tt.offset, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ClockOffsetSeconds() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ClockOffsetSeconds() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ClockOffsetSeconds(%q) = %d, want %d", tt.offset, got, tt.want)
+			}
+		})
+	}
+}
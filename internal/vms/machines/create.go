@@ -1,56 +1,190 @@
 package machines
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
+	"time"
 
+	"snoman/internal/logger"
+	"snoman/internal/runner"
+	"snoman/internal/vms/hypervisor"
 	"snoman/internal/vms/network"
+	"snoman/internal/vms/storage"
 	vmutils "snoman/internal/vms/utils"
 )
 
-func CreateVirtualMachine(spec *VirtualMachineSpec) error {
-	err := vmutils.SpecValidator.Struct(spec)
+func CreateVirtualMachine(ctx context.Context, spec *VirtualMachineSpec) error {
+	if err := PrepareVirtualMachine(ctx, spec); err != nil {
+		return err
+	}
+
+	return StartVirtualMachine(ctx, spec)
+}
+
+// PrepareVirtualMachine will validate the spec, run the pre-flight checks and create the vm network.
+// Anything that has to be listening on the network before the VM boots can be started afterwards
+func PrepareVirtualMachine(ctx context.Context, spec *VirtualMachineSpec) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	report, err := Preflight(ctx, spec)
 	if err != nil {
-		return fmt.Errorf("unable to validate VirtualMachineSpec: %w", err)
+		return fmt.Errorf("unable to run pre-flight checks: %w", err)
+	}
+
+	report.Log()
+	if err := report.Err(); err != nil {
+		return err
 	}
 
 	if spec.Network != nil {
-		err = network.Create(spec.Network)
+		err = network.Create(ctx, spec.Network)
 		if err != nil {
 			return fmt.Errorf("unable to create vm network: %w", err)
 		}
 	}
 
-	if err := startVirtualMachine(spec); err != nil {
+	return nil
+}
+
+// StartVirtualMachine will install and boot the VM on a network created by PrepareVirtualMachine
+func StartVirtualMachine(ctx context.Context, spec *VirtualMachineSpec) error {
+	if err := startVirtualMachine(ctx, spec); err != nil {
 		return fmt.Errorf("unable to start virtual machine: %w", err)
 	}
 
 	return nil
 }
 
-func startVirtualMachine(spec *VirtualMachineSpec) error {
+func startVirtualMachine(ctx context.Context, spec *VirtualMachineSpec) error {
+	log := logger.Get()
+
+	// Allocate the disk ourselves so it can be recorded and cleaned up with the VM
+	disk := volumeRecord{Pool: spec.Disk.Pool, Name: spec.diskVolumeName()}
+	if _, err := storage.CreateVolume(ctx, disk.Pool, disk.Name, uint64(spec.Disk.Size), storage.VOLUME_FORMAT_QCOW2); err != nil {
+		return fmt.Errorf("unable to allocate the vm disk: %w", err)
+	}
+	volumes := []volumeRecord{disk}
+
+	if hypervisor.IsSimulated() {
+		return defineVirtualMachine(ctx, spec, volumes)
+	}
+
+	var bootMedia []string
+	switch {
+	case spec.PxeBoot:
+		// Network booting machines fall back to the disk once it has been installed to
+		bootMedia = []string{"--pxe", "--boot", "network,hd"}
+	case vmutils.IsRemoteLibvirt():
+		// A remote hypervisor can not see our files, so the ISO has to be uploaded next to the disk
+		iso := volumeRecord{Pool: spec.Disk.Pool, Name: spec.isoVolumeName()}
+		if _, err := storage.UploadVolume(ctx, iso.Pool, iso.Name, spec.BipSpec.IsoPath); err != nil {
+			deleteVolumes(ctx, volumes)
+			return fmt.Errorf("unable to upload the installer iso: %w", err)
+		}
+
+		volumes = append(volumes, iso)
+		bootMedia = []string{"--disk", fmt.Sprintf("vol=%s/%s,device=cdrom", iso.Pool, iso.Name), "--boot", "hd,cdrom"}
+	default:
+		bootMedia = []string{"--cdrom", spec.BipSpec.IsoPath, "--boot", "hd,cdrom"}
+	}
+
 	args := []string{
-		"--connect", "qemu:///system",
+		"--connect", vmutils.GetLibvirtURI(),
 		"-n", spec.Name,
 		"-r", fmt.Sprint(spec.RAM),
 		"--vcpus", fmt.Sprint(spec.CPU),
-		"--os-variant", "rhel8.1",
+		"--os-variant", spec.Variant,
 		"--import",
-		fmt.Sprintf("--network=network:\"%s\",mac=\"%s\"", spec.Network.Name, spec.Network.MacAddress),
 		"--graphics=none",
 		"--events", "on_reboot=restart",
-		"--cdrom", spec.BipSpec.IsoPath,
-		"--disk", fmt.Sprintf("pool=\"%s\",size=\"%d\"", spec.Disk.Pool, spec.Disk.Size),
-		"--check", "disk_size=off",
-		"--boot", "hd,cdrom",
+		"--disk", fmt.Sprintf("vol=%s/%s,bus=virtio", disk.Pool, disk.Name),
 		"--noautoconsole",
 		"--wait=-1",
 	}
+	for _, iface := range spec.GetInterfaces() {
+		args = append(args, "--network", iface.virtInstallArg())
+	}
+	args = append(args, spec.virtInstallArchArgs(hostArch(ctx))...)
+
+	// virt-install has no option for the clock adjustment, so it is set on the generated XML
+	if spec.ClockOffset != "" {
+		adjustment, err := ClockOffsetSeconds(spec.ClockOffset, time.Now())
+		if err != nil {
+			deleteVolumes(ctx, volumes)
+			return err
+		}
+
+		args = append(args,
+			"--clock", "offset=variable",
+			"--xml", fmt.Sprintf("./clock/@adjustment=%d", adjustment),
+			"--xml", "./clock/@basis=utc",
+		)
+	}
+	args = append(args, bootMedia...)
+
+	// Cleanup has to happen even if the context was cancelled
+	cleanupCtx := context.WithoutCancel(ctx)
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- runner.Run(ctx, runner.NewCommand(VIRT_INSTALL_BIN, args...))
+	}()
+
+	// virt-install blocks until the install is done, so record the volumes as soon as the domain is defined
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	recorded := false
+	for {
+		select {
+		case err := <-exited:
+			if !recorded && recordVolumes(cleanupCtx, spec.Name, volumes) != nil {
+				// The domain was never defined so nothing else references the volumes
+				log.Warnf("virtual machine '%s' was not defined, removing its volumes", spec.Name)
+				deleteVolumes(cleanupCtx, volumes)
+			}
+
+			if err != nil {
+				return fmt.Errorf("error executing virt-install: %w", err)
+			}
+
+			return nil
+		case <-ticker.C:
+			if !recorded {
+				recorded = recordVolumes(ctx, spec.Name, volumes) == nil
+			}
+		}
+	}
+}
+
+// defineVirtualMachine will define and start the domain through the hypervisor. This is used instead of
+// virt-install for simulated hypervisors, which virt-install can not reach
+func defineVirtualMachine(ctx context.Context, spec *VirtualMachineSpec, volumes []volumeRecord) error {
+	hv := hypervisor.Get()
+	cleanupCtx := context.WithoutCancel(ctx)
+
+	domcfg, err := spec.toLibvirtxml(hostArch(ctx))
+	if err != nil {
+		deleteVolumes(cleanupCtx, volumes)
+		return fmt.Errorf("unable to generate domain configuration: %w", err)
+	}
 
-	cmd := exec.Command("virt-install", args...)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("error executing virt-install: %w", err)
+	if err := hv.DefineDomain(ctx, domcfg); err != nil {
+		deleteVolumes(cleanupCtx, volumes)
+		return err
 	}
 
+	if err := recordVolumes(ctx, spec.Name, volumes); err != nil {
+		return err
+	}
+
+	if err := hv.StartDomain(ctx, spec.Name); err != nil {
+		return err
+	}
+
+	logger.Get().Infow("successfully defined virtual machine", "name", spec.Name, "uri", vmutils.GetLibvirtURI())
+
 	return nil
 }
@@ -0,0 +1,121 @@
+package machines
+
+import (
+	"context"
+	"snoman/internal/biputils"
+	"snoman/internal/vms/hypervisor"
+	"snoman/internal/vms/hypervisor/hypervisortest"
+	"strings"
+	"testing"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+// newTestMachineSpec is the default VM booting an installer ISO that exists
+func newTestMachineSpec(t *testing.T) *VirtualMachineSpec {
+	t.Helper()
+
+	spec := GetDefaultVirtualMachineSpec()
+	spec.BipSpec = &biputils.BootstrapInPlaceIsoSpec{IsoPath: hypervisortest.WriteIso(t, "agent.x86_64.iso")}
+
+	return spec
+}
+
+func TestCreateVirtualMachine(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(spec *VirtualMachineSpec)
+		setup   func(t *testing.T, fake *hypervisor.Fake)
+		wantErr string
+	}{
+		{
+			name: "default machine",
+		},
+		{
+			name:   "without a network",
+			modify: func(spec *VirtualMachineSpec) { spec.Network = nil },
+		},
+		{
+			name:   "emulated arch",
+			modify: func(spec *VirtualMachineSpec) { spec.Arch = "aarch64" },
+		},
+		{
+			name: "not enough memory",
+			setup: func(t *testing.T, fake *hypervisor.Fake) {
+				fake.SetHostInfo(hypervisor.HostInfo{Arch: hypervisor.FAKE_HOST_ARCH, CPUs: 16, MemoryBytes: 1 << 30, FreeMemoryBytes: 1 << 30})
+			},
+			wantErr: "pre-flight checks failed",
+		},
+		{
+			name:    "missing storage pool",
+			modify:  func(spec *VirtualMachineSpec) { spec.Disk.Pool = "missing" },
+			wantErr: "pool 'missing'",
+		},
+		{
+			name: "network already exists",
+			setup: func(t *testing.T, fake *hypervisor.Fake) {
+				net := &libvirtxml.Network{Name: GetDefaultVirtualMachineSpec().Network.Name}
+				if err := fake.CreateNetwork(context.Background(), net); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "unable to create vm network",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := hypervisortest.UseFake(t, GetDefaultVirtualMachineDiskSpec().Pool)
+			if tt.setup != nil {
+				tt.setup(t, fake)
+			}
+
+			spec := newTestMachineSpec(t)
+			if tt.modify != nil {
+				tt.modify(spec)
+			}
+
+			err := CreateVirtualMachine(ctx, spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CreateVirtualMachine() error = %v, want %q", err, tt.wantErr)
+				}
+
+				if _, err := fake.LookupDomain(ctx, spec.Name); err == nil {
+					t.Errorf("the domain was defined although creating the machine failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateVirtualMachine() error = %v", err)
+			}
+
+			if active, err := fake.DomainIsActive(ctx, spec.Name); err != nil || !active {
+				t.Errorf("domain active = %v, %v, want it running", active, err)
+			}
+
+			if _, err := fake.LookupVolume(ctx, spec.Disk.Pool, spec.diskVolumeName()); err != nil {
+				t.Errorf("the disk volume was not created: %v", err)
+			}
+
+			if got := getRecordedVolumes(ctx, spec.Name); len(got) != 1 || got[0].Name != spec.diskVolumeName() {
+				t.Errorf("recorded volumes = %+v, want the disk", got)
+			}
+
+			if spec.Network != nil {
+				if _, err := fake.LookupNetwork(ctx, spec.Network.Name); err != nil {
+					t.Errorf("the network was not created: %v", err)
+				}
+			}
+
+			found, err := Find(ctx, spec.Name)
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if found.CPU != spec.CPU || found.RAM != spec.RAM || found.Disk.Size != spec.Disk.Size {
+				t.Errorf("Find() = %d cpus %d MB %d GB, want %d cpus %d MB %d GB", found.CPU, found.RAM, found.Disk.Size, spec.CPU, spec.RAM, spec.Disk.Size)
+			}
+		})
+	}
+}
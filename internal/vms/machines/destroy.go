@@ -0,0 +1,39 @@
+package machines
+
+import (
+	"context"
+	"fmt"
+	"snoman/internal/logger"
+	"snoman/internal/vms/hypervisor"
+)
+
+// Destroy will stop and undefine the domain by name or UUID and delete any volumes snoman created for it
+func Destroy(ctx context.Context, id string) error {
+	log := logger.Get()
+	hv := hypervisor.Get()
+
+	active, err := hv.DomainIsActive(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not find domain with identifier '%s': %w", id, err)
+	}
+
+	if active {
+		if err := hv.StopDomain(ctx, id); err != nil {
+			return fmt.Errorf("could not stop the domain: %w", err)
+		}
+	}
+
+	volumes := getRecordedVolumes(ctx, id)
+
+	if err := hv.UndefineDomain(ctx, id); err != nil {
+		return fmt.Errorf("could not undefine the domain: %w", err)
+	}
+
+	if err := deleteVolumes(ctx, volumes); err != nil {
+		return fmt.Errorf("could not delete the domain volumes: %w", err)
+	}
+
+	log.Infow("successfully deleted virtual machine by identifier", "id", id, "volumes", len(volumes))
+
+	return nil
+}
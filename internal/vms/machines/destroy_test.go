@@ -0,0 +1,113 @@
+package machines
+
+import (
+	"context"
+	"errors"
+	"snoman/internal/vms/hypervisor"
+	"snoman/internal/vms/hypervisor/hypervisortest"
+	"strings"
+	"testing"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+func TestDestroy(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup returns the id of the domain to destroy
+		setup           func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) string
+		wantErr         string
+		wantDiskDeleted bool
+	}{
+		{
+			name: "running machine by name",
+			setup: func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) string {
+				return spec.Name
+			},
+			wantDiskDeleted: true,
+		},
+		{
+			name: "stopped machine by uuid",
+			setup: func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) string {
+				ctx := context.Background()
+				if err := fake.StopDomain(ctx, spec.Name); err != nil {
+					t.Fatal(err)
+				}
+
+				domcfg, err := fake.LookupDomain(ctx, spec.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return domcfg.UUID
+			},
+			wantDiskDeleted: true,
+		},
+		{
+			name: "machine snoman did not create keeps its volumes",
+			setup: func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) string {
+				// An unreadable record is treated like a domain snoman did not create
+				if err := fake.SetDomainMetadata(context.Background(), spec.Name, SNOMAN_METADATA_PREFIX, SNOMAN_METADATA_URI, "<not-xml"); err != nil {
+					t.Fatal(err)
+				}
+				return spec.Name
+			},
+		},
+		{
+			name: "missing machine",
+			setup: func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) string {
+				return "missing-vm"
+			},
+			wantErr: "could not find domain with identifier 'missing-vm'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := hypervisortest.UseFake(t, GetDefaultVirtualMachineDiskSpec().Pool)
+
+			spec := newTestMachineSpec(t)
+			if err := CreateVirtualMachine(ctx, spec); err != nil {
+				t.Fatalf("unable to create the machine: %v", err)
+			}
+
+			err := Destroy(ctx, tt.setup(t, fake, spec))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Destroy() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Destroy() error = %v", err)
+			}
+
+			if _, err := fake.LookupDomain(ctx, spec.Name); !errors.Is(err, hypervisor.ErrNotFound) {
+				t.Errorf("domain lookup error = %v, want it undefined", err)
+			}
+
+			_, err = fake.LookupVolume(ctx, spec.Disk.Pool, spec.diskVolumeName())
+			if deleted := errors.Is(err, hypervisor.ErrNotFound); deleted != tt.wantDiskDeleted {
+				t.Errorf("disk deleted = %v, want %v", deleted, tt.wantDiskDeleted)
+			}
+		})
+	}
+}
+
+func TestDestroyKeepsUnrecordedMachinesVolumes(t *testing.T) {
+	ctx := context.Background()
+	fake := hypervisortest.UseFake(t, GetDefaultVirtualMachineDiskSpec().Pool)
+
+	// A domain defined outside of snoman has no resource record
+	if err := fake.DefineDomain(ctx, &libvirtxml.Domain{Name: "external-vm"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Destroy(ctx, "external-vm"); err != nil {
+		t.Fatalf("Destroy() error = %v", err)
+	}
+
+	if _, err := fake.LookupDomain(ctx, "external-vm"); !errors.Is(err, hypervisor.ErrNotFound) {
+		t.Errorf("domain lookup error = %v, want it undefined", err)
+	}
+}
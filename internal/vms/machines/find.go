@@ -0,0 +1,71 @@
+package machines
+
+import (
+	"context"
+	"fmt"
+	"snoman/internal/vms/hypervisor"
+	"snoman/internal/vms/network"
+)
+
+// Find will search the hypervisor for the domain by name or uuid and return the machine spec object
+func Find(ctx context.Context, id string) (*VirtualMachineSpec, error) {
+	domcfg, err := hypervisor.Get().LookupDomain(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not find domain by identifier '%s': %w", id, err)
+	}
+
+	spec := &VirtualMachineSpec{}
+	if err := spec.fromLibvirtxml(domcfg); err != nil {
+		return nil, fmt.Errorf("unable to generate spec from domain '%s': %w", id, err)
+	}
+
+	if spec.Disk != nil {
+		if err := spec.Disk.fillFromVolume(ctx, spec.diskVolumeName()); err != nil {
+			return nil, fmt.Errorf("unable to look up the disk of '%s': %w", id, err)
+		}
+	}
+
+	// The domain only knows the network name, the rest of the network spec lives in libvirt
+	if spec.Network != nil {
+		netspec, err := network.Find(ctx, spec.Network.Name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to look up the network of '%s': %w", id, err)
+		}
+
+		// The VM uses the network spec MAC address for its interface
+		netspec.MacAddress = spec.Network.MacAddress
+		spec.Network = netspec
+	}
+
+	return spec, nil
+}
+
+// fillFromVolume will fill in the pool and size of the disk from the backing storage volume
+func (disk *VirtualMachineDiskSpec) fillFromVolume(ctx context.Context, volumeName string) error {
+	hv := hypervisor.Get()
+
+	var vol *hypervisor.VolumeInfo
+	var err error
+
+	if disk.path != "" {
+		vol, err = hv.LookupVolumeByPath(ctx, disk.path)
+		if err != nil {
+			return fmt.Errorf("unable to find storage volume for '%s': %w", disk.path, err)
+		}
+
+		disk.Pool = vol.Pool
+	} else {
+		if disk.volume != "" {
+			volumeName = disk.volume
+		}
+
+		vol, err = hv.LookupVolume(ctx, disk.Pool, volumeName)
+		if err != nil {
+			return fmt.Errorf("unable to find volume '%s' in pool '%s': %w", volumeName, disk.Pool, err)
+		}
+	}
+
+	disk.Size = uint(vol.Capacity / (1024 * 1024 * 1024))
+
+	return nil
+}
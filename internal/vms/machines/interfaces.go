@@ -0,0 +1,238 @@
+package machines
+
+import (
+	"fmt"
+	"regexp"
+	"snoman/internal/biputils/agentconfig"
+	"strings"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+// The guest interface name is kept in the libvirt user alias, which only allows these characters
+var interfaceNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,15}$`)
+
+const interfaceAliasPrefix = "ua-"
+
+// GetInterfaces returns the NICs of the VM. Without any interfaces the VM gets a single NIC on its network
+func (spec VirtualMachineSpec) GetInterfaces() []VirtualMachineInterfaceSpec {
+	if len(spec.Interfaces) > 0 {
+		return spec.Interfaces
+	}
+
+	if spec.Network == nil {
+		return nil
+	}
+
+	return []VirtualMachineInterfaceSpec{
+		{
+			Name:       DEFAULT_INTERFACE_NAME,
+			Network:    spec.Network.Name,
+			MacAddress: spec.Network.MacAddress,
+		},
+	}
+}
+
+func (spec VirtualMachineSpec) validateInterfaces() error {
+	names := map[string]bool{}
+	macs := map[string]bool{}
+	members := map[string]int{}
+
+	for _, bond := range spec.Bonds {
+		if names[bond.Name] {
+			return fmt.Errorf("interface name '%s' is used more than once", bond.Name)
+		}
+		names[bond.Name] = true
+		members[bond.Name] = 0
+	}
+
+	for _, iface := range spec.Interfaces {
+		if !interfaceNameRegex.MatchString(iface.Name) {
+			return fmt.Errorf("interface name '%s' must be at most 15 letters, digits, '-' or '_'", iface.Name)
+		}
+
+		if names[iface.Name] {
+			return fmt.Errorf("interface name '%s' is used more than once", iface.Name)
+		}
+		names[iface.Name] = true
+
+		mac := strings.ToLower(iface.MacAddress)
+		if macs[mac] {
+			return fmt.Errorf("mac address '%s' is used by more than one interface", iface.MacAddress)
+		}
+		macs[mac] = true
+
+		if iface.Bond == "" {
+			continue
+		}
+
+		if _, ok := members[iface.Bond]; !ok {
+			return fmt.Errorf("interface '%s' is a member of bond '%s' which does not exist", iface.Name, iface.Bond)
+		}
+		members[iface.Bond]++
+
+		if iface.VlanID != 0 {
+			return fmt.Errorf("interface '%s' is a bond member, set the vlan on bond '%s' instead", iface.Name, iface.Bond)
+		}
+	}
+
+	for name, count := range members {
+		if count == 0 {
+			return fmt.Errorf("bond '%s' has no member interfaces", name)
+		}
+	}
+
+	return spec.validateNetworkInterfaces()
+}
+
+// validateNetworkInterfaces will check the interfaces on the network snoman creates for the VM. Its gateway and
+// DHCP are on the untagged bridge, so a VLAN tagged there can not reach them, and the DHCP host entry only
+// reserves the host IP for the MAC of the first interface when they match
+func (spec VirtualMachineSpec) validateNetworkInterfaces() error {
+	if spec.Network == nil || len(spec.Interfaces) == 0 {
+		return nil
+	}
+
+	bondVlans := map[string]uint{}
+	for _, bond := range spec.Bonds {
+		bondVlans[bond.Name] = bond.VlanID
+	}
+
+	for _, iface := range spec.Interfaces {
+		if iface.Network != spec.Network.Name {
+			continue
+		}
+
+		if iface.VlanID != 0 {
+			return fmt.Errorf("interface '%s' can not tag vlan %d on network '%s', which only carries untagged traffic to its gateway, attach it to a network that carries the vlan instead", iface.Name, iface.VlanID, iface.Network)
+		}
+
+		if vlan := bondVlans[iface.Bond]; vlan != 0 {
+			return fmt.Errorf("bond '%s' can not tag vlan %d on network '%s' of its interface '%s', which only carries untagged traffic to its gateway, attach it to a network that carries the vlan instead", iface.Bond, vlan, iface.Network, iface.Name)
+		}
+	}
+
+	primary := spec.Interfaces[0]
+	if primary.Network == spec.Network.Name && len(spec.Network.Hosts) > 0 {
+		host := spec.Network.Hosts[0]
+		if host.MacAddress != "" && !strings.EqualFold(host.MacAddress, primary.MacAddress) {
+			return fmt.Errorf("interface '%s' has mac address '%s' but the host IP %s is reserved for '%s' on network '%s', they have to match", primary.Name, primary.MacAddress, host.IpAddress, host.MacAddress, spec.Network.Name)
+		}
+	}
+
+	return nil
+}
+
+func (iface VirtualMachineInterfaceSpec) model() string {
+	if iface.Model == "" {
+		return DEFAULT_INTERFACE_MODEL
+	}
+
+	return iface.Model
+}
+
+// virtInstallArg is the virt-install --network value for the interface
+func (iface VirtualMachineInterfaceSpec) virtInstallArg() string {
+	opts := []string{
+		fmt.Sprintf("network=%s", iface.Network),
+		fmt.Sprintf("mac=%s", iface.MacAddress),
+		fmt.Sprintf("model=%s", iface.model()),
+		fmt.Sprintf("alias.name=%s%s", interfaceAliasPrefix, iface.Name),
+	}
+
+	if iface.LinkState == LINK_STATE_DOWN {
+		opts = append(opts, "link.state=down")
+	}
+
+	return strings.Join(opts, ",")
+}
+
+func (iface VirtualMachineInterfaceSpec) toLibvirtxml() libvirtxml.DomainInterface {
+	domiface := libvirtxml.DomainInterface{
+		MAC: &libvirtxml.DomainInterfaceMAC{Address: iface.MacAddress},
+		Source: &libvirtxml.DomainInterfaceSource{
+			Network: &libvirtxml.DomainInterfaceSourceNetwork{Network: iface.Network},
+		},
+		Model: &libvirtxml.DomainInterfaceModel{Type: iface.model()},
+		Alias: &libvirtxml.DomainAlias{Name: interfaceAliasPrefix + iface.Name},
+	}
+
+	if iface.LinkState != "" {
+		domiface.Link = &libvirtxml.DomainInterfaceLink{State: iface.LinkState}
+	}
+
+	return domiface
+}
+
+// interfaceFromLibvirtxml will rebuild an interface spec. Bonds and VLANs only exist inside the guest, so they
+// can not be recovered from the domain
+func interfaceFromLibvirtxml(domiface libvirtxml.DomainInterface, index int) VirtualMachineInterfaceSpec {
+	iface := VirtualMachineInterfaceSpec{
+		Name:    fmt.Sprintf("eno%d", index+1),
+		Network: domiface.Source.Network.Network,
+	}
+
+	if domiface.Alias != nil && strings.HasPrefix(domiface.Alias.Name, interfaceAliasPrefix) {
+		iface.Name = strings.TrimPrefix(domiface.Alias.Name, interfaceAliasPrefix)
+	}
+
+	if domiface.MAC != nil {
+		iface.MacAddress = domiface.MAC.Address
+	}
+
+	if domiface.Model != nil {
+		iface.Model = domiface.Model.Type
+	}
+
+	if domiface.Link != nil {
+		iface.LinkState = domiface.Link.State
+	}
+
+	return iface
+}
+
+// ConfigureAgentInterfaces will describe the VM interfaces, bonds and VLANs in the agent config NMState.
+// The host IP goes on the first interface, or on the bond and VLAN layered on top of it. Without any
+// interfaces the agent config keeps its single NIC
+func (spec VirtualMachineSpec) ConfigureAgentInterfaces(acspec *agentconfig.BootstrapInPlaceAgentConfigSpec) {
+	if len(spec.Interfaces) == 0 {
+		return
+	}
+
+	bondVlans := map[string]uint{}
+	for _, bond := range spec.Bonds {
+		hostBond := agentconfig.HostBond{Name: bond.Name, Mode: bond.Mode}
+		for _, iface := range spec.Interfaces {
+			if iface.Bond == bond.Name {
+				hostBond.Ports = append(hostBond.Ports, iface.Name)
+			}
+		}
+
+		acspec.Bonds = append(acspec.Bonds, hostBond)
+		if bond.VlanID != 0 {
+			acspec.Vlans = append(acspec.Vlans, agentconfig.NewHostVlan(bond.Name, bond.VlanID))
+		}
+		bondVlans[bond.Name] = bond.VlanID
+	}
+
+	for _, iface := range spec.Interfaces {
+		acspec.Interfaces = append(acspec.Interfaces, agentconfig.HostInterface{Name: iface.Name, MacAddress: iface.MacAddress})
+		if iface.VlanID != 0 {
+			acspec.Vlans = append(acspec.Vlans, agentconfig.NewHostVlan(iface.Name, iface.VlanID))
+		}
+	}
+
+	primary := spec.Interfaces[0]
+	acspec.HostMAC = primary.MacAddress
+
+	ipIface, vlan := primary.Name, primary.VlanID
+	if primary.Bond != "" {
+		ipIface, vlan = primary.Bond, bondVlans[primary.Bond]
+	}
+
+	if vlan != 0 {
+		ipIface = agentconfig.NewHostVlan(ipIface, vlan).Name
+	}
+
+	acspec.IPInterface = ipIface
+}
@@ -0,0 +1,194 @@
+package machines
+
+import (
+	"reflect"
+	"snoman/internal/biputils/agentconfig"
+	"strings"
+	"testing"
+)
+
+const (
+	testPrimaryMac = "52:54:00:6b:3c:01"
+	testSecondMac  = "52:54:00:6b:3c:02"
+	testTrunk      = "trunk-network"
+)
+
+// newTestInterfacesSpec is the default VM with its DHCP host reserved for the first of two interfaces
+func newTestInterfacesSpec() *VirtualMachineSpec {
+	spec := GetDefaultVirtualMachineSpec()
+	spec.Network.Hosts[0].MacAddress = testPrimaryMac
+	spec.Interfaces = []VirtualMachineInterfaceSpec{
+		{Name: "eno1", Network: spec.Network.Name, MacAddress: testPrimaryMac},
+		{Name: "eno2", Network: spec.Network.Name, MacAddress: testSecondMac},
+	}
+
+	return spec
+}
+
+func TestValidateInterfaces(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(spec *VirtualMachineSpec)
+		wantErr string
+	}{
+		{
+			name: "two interfaces on the vm network",
+		},
+		{
+			name: "bond on the vm network",
+			modify: func(spec *VirtualMachineSpec) {
+				spec.Bonds = []VirtualMachineBondSpec{{Name: "bond0", Mode: "active-backup"}}
+				spec.Interfaces[0].Bond = "bond0"
+				spec.Interfaces[1].Bond = "bond0"
+			},
+		},
+		{
+			name: "vlan on an existing network",
+			modify: func(spec *VirtualMachineSpec) {
+				spec.Interfaces[1].Network = testTrunk
+				spec.Interfaces[1].VlanID = 100
+			},
+		},
+		{
+			name: "mac case differs from the dhcp host",
+			modify: func(spec *VirtualMachineSpec) {
+				spec.Interfaces[0].MacAddress = strings.ToUpper(testPrimaryMac)
+			},
+		},
+		{
+			name: "first interface on another network",
+			modify: func(spec *VirtualMachineSpec) {
+				spec.Interfaces[0].Network = testTrunk
+				spec.Interfaces[0].MacAddress = "52:54:00:6b:3c:03"
+			},
+		},
+		{
+			name: "vlan on the vm network",
+			modify: func(spec *VirtualMachineSpec) {
+				spec.Interfaces[0].VlanID = 100
+			},
+			wantErr: "interface 'eno1' can not tag vlan 100 on network 'sno-network'",
+		},
+		{
+			name: "bond vlan on the vm network",
+			modify: func(spec *VirtualMachineSpec) {
+				spec.Bonds = []VirtualMachineBondSpec{{Name: "bond0", Mode: "active-backup", VlanID: 200}}
+				spec.Interfaces[0].Bond = "bond0"
+				spec.Interfaces[1].Bond = "bond0"
+			},
+			wantErr: "bond 'bond0' can not tag vlan 200 on network 'sno-network'",
+		},
+		{
+			name: "first interface is not the dhcp host",
+			modify: func(spec *VirtualMachineSpec) {
+				spec.Interfaces[0].MacAddress, spec.Interfaces[1].MacAddress = testSecondMac, testPrimaryMac
+			},
+			wantErr: "interface 'eno1' has mac address '" + testSecondMac + "' but the host IP",
+		},
+		{
+			name: "duplicate mac",
+			modify: func(spec *VirtualMachineSpec) {
+				spec.Interfaces[1].MacAddress = testPrimaryMac
+			},
+			wantErr: "is used by more than one interface",
+		},
+		{
+			name: "vlan on a bond member",
+			modify: func(spec *VirtualMachineSpec) {
+				spec.Bonds = []VirtualMachineBondSpec{{Name: "bond0", Mode: "active-backup"}}
+				spec.Interfaces[1].Network = testTrunk
+				spec.Interfaces[1].Bond = "bond0"
+				spec.Interfaces[1].VlanID = 100
+			},
+			wantErr: "set the vlan on bond 'bond0' instead",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := newTestInterfacesSpec()
+			if tt.modify != nil {
+				tt.modify(spec)
+			}
+
+			err := spec.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigureAgentInterfaces(t *testing.T) {
+	tests := []struct {
+		name            string
+		modify          func(spec *VirtualMachineSpec)
+		wantIPInterface string
+		wantVlans       []agentconfig.HostVlan
+		wantBonds       []agentconfig.HostBond
+	}{
+		{
+			name:            "untagged interfaces",
+			wantIPInterface: "eno1",
+		},
+		{
+			name: "vlan tagged sno on a trunk network",
+			modify: func(spec *VirtualMachineSpec) {
+				spec.Interfaces[0].Network = testTrunk
+				spec.Interfaces[0].VlanID = 100
+			},
+			wantIPInterface: "eno1.100",
+			wantVlans:       []agentconfig.HostVlan{{Name: "eno1.100", Base: "eno1", ID: 100}},
+		},
+		{
+			name: "tagged bond on a trunk network",
+			modify: func(spec *VirtualMachineSpec) {
+				spec.Bonds = []VirtualMachineBondSpec{{Name: "bond0", Mode: "802.3ad", VlanID: 200}}
+				for i := range spec.Interfaces {
+					spec.Interfaces[i].Network = testTrunk
+					spec.Interfaces[i].Bond = "bond0"
+				}
+			},
+			wantIPInterface: "bond0.200",
+			wantVlans:       []agentconfig.HostVlan{{Name: "bond0.200", Base: "bond0", ID: 200}},
+			wantBonds:       []agentconfig.HostBond{{Name: "bond0", Mode: "802.3ad", Ports: []string{"eno1", "eno2"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := newTestInterfacesSpec()
+			if tt.modify != nil {
+				tt.modify(spec)
+			}
+			if err := spec.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			acspec := &agentconfig.BootstrapInPlaceAgentConfigSpec{}
+			spec.ConfigureAgentInterfaces(acspec)
+
+			if acspec.HostMAC != testPrimaryMac {
+				t.Errorf("HostMAC = %s, want %s", acspec.HostMAC, testPrimaryMac)
+			}
+			if acspec.IPInterface != tt.wantIPInterface {
+				t.Errorf("IPInterface = %s, want %s", acspec.IPInterface, tt.wantIPInterface)
+			}
+			if len(acspec.Interfaces) != 2 {
+				t.Errorf("Interfaces = %+v, want both", acspec.Interfaces)
+			}
+			if !reflect.DeepEqual(acspec.Vlans, tt.wantVlans) {
+				t.Errorf("Vlans = %+v, want %+v", acspec.Vlans, tt.wantVlans)
+			}
+			if !reflect.DeepEqual(acspec.Bonds, tt.wantBonds) {
+				t.Errorf("Bonds = %+v, want %+v", acspec.Bonds, tt.wantBonds)
+			}
+		})
+	}
+}
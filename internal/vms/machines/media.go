@@ -0,0 +1,311 @@
+package machines
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"snoman/internal/logger"
+	"snoman/internal/vms/hypervisor"
+	"snoman/internal/vms/storage"
+	vmutils "snoman/internal/vms/utils"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+// MediaInfo describes a cdrom device of a virtual machine
+type MediaInfo struct {
+	Target string
+	Bus    string
+	// Source is the ISO path or pool/volume, it is empty if the drive has no media
+	Source string
+}
+
+var BOOT_DEVICES = []string{"hd", "cdrom", "network", "fd"}
+
+// hotplugBuses are the buses a drive can be added to while the VM runs, sata and ide only take drives at boot
+var hotplugBuses = map[string]bool{
+	"scsi": true,
+	"usb":  true,
+}
+
+// ListMedia will return every cdrom device of the virtual machine
+func ListMedia(ctx context.Context, id string) ([]MediaInfo, error) {
+	domcfg, err := hypervisor.Get().LookupDomain(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not find domain by identifier '%s': %w", id, err)
+	}
+
+	media := []MediaInfo{}
+	for _, disk := range cdroms(domcfg) {
+		info := MediaInfo{Target: disk.Target.Dev, Bus: disk.Target.Bus}
+
+		if disk.Source != nil {
+			switch {
+			case !hasMedia(disk):
+			case disk.Source.File != nil:
+				info.Source = disk.Source.File.File
+			case disk.Source.Volume != nil:
+				info.Source = fmt.Sprintf("%s/%s", disk.Source.Volume.Pool, disk.Source.Volume.Volume)
+			}
+		}
+
+		media = append(media, info)
+	}
+
+	return media, nil
+}
+
+// InsertMedia will put the ISO into a cdrom of the virtual machine, live if it is running and persistently.
+// If target is empty the first empty cdrom is used, and a new cdrom is added if there is none. A new cdrom
+// can only be added to a running VM on a bus that supports hot plug. On remote hypervisors the ISO is
+// uploaded to the pool of the VM disk and removed when the VM is destroyed
+func InsertMedia(ctx context.Context, id string, isoPath string, target string) error {
+	log := logger.Get()
+	hv := hypervisor.Get()
+
+	domcfg, err := hv.LookupDomain(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not find domain by identifier '%s': %w", id, err)
+	}
+
+	disk := pickCdrom(domcfg, target)
+	added := disk == nil
+	if added {
+		if target == "" {
+			target = nextDiskTarget(domcfg, "sd")
+		}
+
+		guest := ""
+		if domcfg.OS != nil && domcfg.OS.Type != nil {
+			guest = domcfg.OS.Type.Arch
+		}
+
+		disk = &libvirtxml.DomainDisk{
+			Device:   "cdrom",
+			Driver:   &libvirtxml.DomainDiskDriver{Name: "qemu", Type: "raw"},
+			Target:   &libvirtxml.DomainDiskTarget{Dev: target, Bus: cdromBus(guest)},
+			ReadOnly: &libvirtxml.DomainDiskReadOnly{},
+		}
+
+		// Checked before the ISO is uploaded, which would be left behind otherwise
+		active, err := hv.DomainIsActive(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if active && !hotplugBuses[disk.Target.Bus] {
+			return fmt.Errorf("a cdrom can not be hot plugged on the %s bus of running domain '%s', insert the media into an existing cdrom or stop the domain first", disk.Target.Bus, id)
+		}
+	}
+
+	disk.Source, err = mediaSource(ctx, domcfg, isoPath)
+	if err != nil {
+		return err
+	}
+
+	if added {
+		err = hv.AttachDomainDisk(ctx, id, disk, true)
+	} else {
+		err = hv.UpdateDomainDisk(ctx, id, disk, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Infow("successfully inserted media", "vm", id, "target", disk.Target.Dev, "iso", isoPath)
+
+	return nil
+}
+
+// EjectMedia will remove the media from a cdrom of the virtual machine. If target is empty every cdrom is ejected
+func EjectMedia(ctx context.Context, id string, target string) error {
+	log := logger.Get()
+	hv := hypervisor.Get()
+
+	domcfg, err := hv.LookupDomain(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not find domain by identifier '%s': %w", id, err)
+	}
+
+	found := false
+	for _, disk := range cdroms(domcfg) {
+		if target != "" && disk.Target.Dev != target {
+			continue
+		}
+		found = true
+
+		if !hasMedia(disk) {
+			continue
+		}
+
+		disk.Source = nil
+		if err := hv.UpdateDomainDisk(ctx, id, &disk, true); err != nil {
+			return err
+		}
+
+		log.Infow("successfully ejected media", "vm", id, "target", disk.Target.Dev)
+	}
+
+	if !found {
+		return fmt.Errorf("domain '%s' has no cdrom '%s'", id, target)
+	}
+
+	return nil
+}
+
+// SetBootOrder will change the boot devices of the virtual machine (hd, cdrom, network or fd).
+// This changes the persistent config, so it applies from the next boot
+func SetBootOrder(ctx context.Context, id string, devices []string) error {
+	hv := hypervisor.Get()
+
+	if len(devices) == 0 {
+		return fmt.Errorf("at least one boot device is required")
+	}
+
+	for _, dev := range devices {
+		if !slices.Contains(BOOT_DEVICES, dev) {
+			return fmt.Errorf("unsupported boot device '%s', expected one of %v", dev, BOOT_DEVICES)
+		}
+	}
+
+	domcfg, err := hv.LookupDomain(ctx, id)
+	if err != nil {
+		return fmt.Errorf("could not find domain by identifier '%s': %w", id, err)
+	}
+
+	if domcfg.OS == nil {
+		domcfg.OS = &libvirtxml.DomainOS{}
+	}
+
+	domcfg.OS.BootDevices = make([]libvirtxml.DomainBootDevice, 0, len(devices))
+	for _, dev := range devices {
+		domcfg.OS.BootDevices = append(domcfg.OS.BootDevices, libvirtxml.DomainBootDevice{Dev: dev})
+	}
+
+	// libvirt does not allow per device boot order together with the os boot devices
+	if domcfg.Devices != nil {
+		for i := range domcfg.Devices.Disks {
+			domcfg.Devices.Disks[i].Boot = nil
+		}
+
+		for i := range domcfg.Devices.Interfaces {
+			domcfg.Devices.Interfaces[i].Boot = nil
+		}
+	}
+
+	if err := hv.DefineDomain(ctx, domcfg); err != nil {
+		return fmt.Errorf("unable to update the boot order of '%s': %w", id, err)
+	}
+
+	logger.Get().Infow("successfully set boot order", "vm", id, "devices", devices)
+
+	return nil
+}
+
+func cdroms(domcfg *libvirtxml.Domain) []libvirtxml.DomainDisk {
+	drives := []libvirtxml.DomainDisk{}
+	if domcfg.Devices == nil {
+		return drives
+	}
+
+	for _, disk := range domcfg.Devices.Disks {
+		if disk.Device == "cdrom" && disk.Target != nil {
+			drives = append(drives, disk)
+		}
+	}
+
+	return drives
+}
+
+// hasMedia will return true if the drive has an ISO file or volume in it
+func hasMedia(disk libvirtxml.DomainDisk) bool {
+	// An empty drive still parses with an empty source of its type
+	if disk.Source == nil {
+		return false
+	}
+
+	return (disk.Source.File != nil && disk.Source.File.File != "") ||
+		(disk.Source.Volume != nil && disk.Source.Volume.Volume != "")
+}
+
+// pickCdrom will return the cdrom with the target, or the first empty one if target is empty
+func pickCdrom(domcfg *libvirtxml.Domain, target string) *libvirtxml.DomainDisk {
+	for _, disk := range cdroms(domcfg) {
+		if (target == "" && !hasMedia(disk)) || (target != "" && disk.Target.Dev == target) {
+			return &disk
+		}
+	}
+
+	return nil
+}
+
+// nextDiskTarget will return the first unused target device name with the prefix (sda, sdb, ...)
+func nextDiskTarget(domcfg *libvirtxml.Domain, prefix string) string {
+	used := map[string]bool{}
+	if domcfg.Devices != nil {
+		for _, disk := range domcfg.Devices.Disks {
+			if disk.Target != nil {
+				used[disk.Target.Dev] = true
+			}
+		}
+	}
+
+	for c := 'a'; c <= 'z'; c++ {
+		if dev := fmt.Sprintf("%s%c", prefix, c); !used[dev] {
+			return dev
+		}
+	}
+
+	return ""
+}
+
+// mediaSource will return the disk source for the ISO, uploading it first if the hypervisor can not read local files
+func mediaSource(ctx context.Context, domcfg *libvirtxml.Domain, isoPath string) (*libvirtxml.DomainDiskSource, error) {
+	absPath, err := filepath.Abs(isoPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve '%s': %w", isoPath, err)
+	}
+
+	if _, err := os.Stat(absPath); err != nil {
+		return nil, fmt.Errorf("could not find the iso: %w", err)
+	}
+
+	if !vmutils.IsRemoteLibvirt() {
+		return &libvirtxml.DomainDiskSource{File: &libvirtxml.DomainDiskSourceFile{File: absPath}}, nil
+	}
+
+	spec := &VirtualMachineSpec{}
+	if err := spec.fromLibvirtxml(domcfg); err != nil || spec.Disk == nil {
+		return nil, fmt.Errorf("unable to find the storage pool of '%s' to upload the iso to", domcfg.Name)
+	}
+
+	if err := spec.Disk.fillFromVolume(ctx, spec.diskVolumeName()); err != nil {
+		return nil, fmt.Errorf("unable to find the storage pool of '%s': %w", domcfg.Name, err)
+	}
+
+	vol := volumeRecord{Pool: spec.Disk.Pool, Name: fmt.Sprintf("%s-%s", domcfg.Name, filepath.Base(absPath))}
+
+	// Inserting the same ISO again replaces the previous upload
+	volumes := getRecordedVolumes(ctx, domcfg.Name)
+	if _, err := hypervisor.Get().LookupVolume(ctx, vol.Pool, vol.Name); err == nil {
+		if err := storage.DeleteVolume(ctx, vol.Pool, vol.Name); err != nil {
+			return nil, fmt.Errorf("unable to replace the previously uploaded iso: %w", err)
+		}
+
+		volumes = slices.DeleteFunc(volumes, func(v volumeRecord) bool { return v == vol })
+	}
+
+	if _, err := storage.UploadVolume(ctx, vol.Pool, vol.Name, absPath); err != nil {
+		return nil, fmt.Errorf("unable to upload the iso: %w", err)
+	}
+
+	// Clean the volume up together with the VM
+	volumes = append(volumes, vol)
+	if err := recordVolumes(ctx, domcfg.Name, volumes); err != nil {
+		logger.Get().Warnf("unable to record volume '%s', it will not be removed with the VM: %v", vol.Name, err)
+	}
+
+	return &libvirtxml.DomainDiskSource{Volume: &libvirtxml.DomainDiskSourceVolume{Pool: vol.Pool, Volume: vol.Name}}, nil
+}
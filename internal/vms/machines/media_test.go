@@ -0,0 +1,319 @@
+package machines
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"snoman/internal/vms/hypervisor"
+	"snoman/internal/vms/hypervisor/hypervisortest"
+	"strings"
+	"testing"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+// liveHypervisor records whether the disk changes were asked to apply to the running domain
+type liveHypervisor struct {
+	hypervisor.Hypervisor
+	live []bool
+}
+
+func (h *liveHypervisor) AttachDomainDisk(ctx context.Context, id string, disk *libvirtxml.DomainDisk, live bool) error {
+	h.live = append(h.live, live)
+	return h.Hypervisor.AttachDomainDisk(ctx, id, disk, live)
+}
+
+func (h *liveHypervisor) UpdateDomainDisk(ctx context.Context, id string, disk *libvirtxml.DomainDisk, live bool) error {
+	h.live = append(h.live, live)
+	return h.Hypervisor.UpdateDomainDisk(ctx, id, disk, live)
+}
+
+// createTestMachine will create the default VM, booted from its installer ISO in sda
+func createTestMachine(t *testing.T, modify func(spec *VirtualMachineSpec)) (*hypervisor.Fake, *VirtualMachineSpec) {
+	t.Helper()
+
+	fake := hypervisortest.UseFake(t, GetDefaultVirtualMachineDiskSpec().Pool)
+	spec := newTestMachineSpec(t)
+	if modify != nil {
+		modify(spec)
+	}
+
+	if err := CreateVirtualMachine(context.Background(), spec); err != nil {
+		t.Fatalf("unable to create the machine: %v", err)
+	}
+
+	return fake, spec
+}
+
+func TestInsertMedia(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(spec *VirtualMachineSpec)
+		// setup prepares the created machine and returns the iso and target to insert
+		setup       func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) (string, string)
+		wantTarget  string
+		wantBus     string
+		wantCdroms  int
+		wantErr     string
+		wantNoCalls bool
+	}{
+		{
+			name: "into the empty cdrom",
+			setup: func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) (string, string) {
+				if err := EjectMedia(context.Background(), spec.Name, ""); err != nil {
+					t.Fatal(err)
+				}
+				return hypervisortest.WriteIso(t, "tools.iso"), ""
+			},
+			wantTarget: "sda",
+			wantBus:    "sata",
+			wantCdroms: 1,
+		},
+		{
+			name: "replacing the media of a cdrom",
+			setup: func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) (string, string) {
+				return hypervisortest.WriteIso(t, "tools.iso"), "sda"
+			},
+			wantTarget: "sda",
+			wantBus:    "sata",
+			wantCdroms: 1,
+		},
+		{
+			name: "new cdrom on a stopped machine",
+			setup: func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) (string, string) {
+				if err := fake.StopDomain(context.Background(), spec.Name); err != nil {
+					t.Fatal(err)
+				}
+				return hypervisortest.WriteIso(t, "tools.iso"), ""
+			},
+			wantTarget: "sdb",
+			wantBus:    "sata",
+			wantCdroms: 2,
+		},
+		{
+			name:   "new cdrom hot plugged on scsi",
+			modify: func(spec *VirtualMachineSpec) { spec.Arch = "aarch64" },
+			setup: func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) (string, string) {
+				return hypervisortest.WriteIso(t, "tools.iso"), ""
+			},
+			wantTarget: "sdb",
+			wantBus:    "scsi",
+			wantCdroms: 2,
+		},
+		{
+			name: "new cdrom on the sata bus of a running machine",
+			setup: func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) (string, string) {
+				return hypervisortest.WriteIso(t, "tools.iso"), ""
+			},
+			wantErr:     "can not be hot plugged on the sata bus",
+			wantNoCalls: true,
+		},
+		{
+			name: "missing iso",
+			setup: func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) (string, string) {
+				return filepath.Join(t.TempDir(), "missing.iso"), "sda"
+			},
+			wantErr:     "could not find the iso",
+			wantNoCalls: true,
+		},
+		{
+			name: "missing machine",
+			setup: func(t *testing.T, fake *hypervisor.Fake, spec *VirtualMachineSpec) (string, string) {
+				spec.Name = "missing-vm"
+				return hypervisortest.WriteIso(t, "tools.iso"), ""
+			},
+			wantErr:     "could not find domain by identifier 'missing-vm'",
+			wantNoCalls: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake, spec := createTestMachine(t, tt.modify)
+			iso, target := tt.setup(t, fake, spec)
+
+			hv := &liveHypervisor{Hypervisor: fake}
+			hypervisor.Set(hv)
+
+			err := InsertMedia(ctx, spec.Name, iso, target)
+			if tt.wantNoCalls && len(hv.live) != 0 {
+				t.Errorf("the domain disks were changed %d times, want none", len(hv.live))
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("InsertMedia() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("InsertMedia() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(hv.live, []bool{true}) {
+				t.Errorf("disk changes live = %v, want a single live change", hv.live)
+			}
+
+			media, err := ListMedia(ctx, spec.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(media) != tt.wantCdroms {
+				t.Fatalf("ListMedia() = %+v, want %d cdroms", media, tt.wantCdroms)
+			}
+
+			want := MediaInfo{Target: tt.wantTarget, Bus: tt.wantBus, Source: iso}
+			if !slices.Contains(media, want) {
+				t.Errorf("ListMedia() = %+v, want %+v", media, want)
+			}
+		})
+	}
+}
+
+func TestEjectMedia(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		wantErr string
+	}{
+		{
+			name: "every cdrom",
+		},
+		{
+			name:   "by target",
+			target: "sda",
+		},
+		{
+			name:    "missing cdrom",
+			target:  "sdz",
+			wantErr: "has no cdrom 'sdz'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, spec := createTestMachine(t, nil)
+
+			err := EjectMedia(ctx, spec.Name, tt.target)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("EjectMedia() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EjectMedia() error = %v", err)
+			}
+
+			media, err := ListMedia(ctx, spec.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := []MediaInfo{{Target: "sda", Bus: "sata"}}
+			if !reflect.DeepEqual(media, want) {
+				t.Errorf("ListMedia() = %+v, want %+v", media, want)
+			}
+
+			// Ejecting an empty drive is not an error
+			if err := EjectMedia(ctx, spec.Name, tt.target); err != nil {
+				t.Errorf("ejecting again error = %v", err)
+			}
+		})
+	}
+}
+
+func TestListMedia(t *testing.T) {
+	ctx := context.Background()
+	_, spec := createTestMachine(t, nil)
+
+	media, err := ListMedia(ctx, spec.Name)
+	if err != nil {
+		t.Fatalf("ListMedia() error = %v", err)
+	}
+
+	want := []MediaInfo{{Target: "sda", Bus: "sata", Source: spec.BipSpec.IsoPath}}
+	if !reflect.DeepEqual(media, want) {
+		t.Errorf("ListMedia() = %+v, want %+v", media, want)
+	}
+
+	if _, err := ListMedia(ctx, "missing-vm"); err == nil {
+		t.Errorf("ListMedia() of a missing machine should fail")
+	}
+}
+
+func TestSetBootOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		devices []string
+		wantErr string
+	}{
+		{
+			name:    "cdrom first",
+			devices: []string{"cdrom", "hd"},
+		},
+		{
+			name:    "network only",
+			devices: []string{"network"},
+		},
+		{
+			name:    "unsupported device",
+			devices: []string{"usb", "hd"},
+			wantErr: "unsupported boot device 'usb'",
+		},
+		{
+			name:    "no device",
+			wantErr: "at least one boot device is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake, spec := createTestMachine(t, nil)
+
+			// Per device boot order can not be combined with the os boot devices
+			domcfg, err := fake.LookupDomain(ctx, spec.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			domcfg.OS.BootDevices = nil
+			domcfg.Devices.Disks[0].Boot = &libvirtxml.DomainDeviceBoot{Order: 1}
+			if err := fake.DefineDomain(ctx, domcfg); err != nil {
+				t.Fatal(err)
+			}
+
+			err = SetBootOrder(ctx, spec.Name, tt.devices)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SetBootOrder() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetBootOrder() error = %v", err)
+			}
+
+			domcfg, err = fake.LookupDomain(ctx, spec.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, dev := range domcfg.OS.BootDevices {
+				got = append(got, dev.Dev)
+			}
+			if !reflect.DeepEqual(got, tt.devices) {
+				t.Errorf("boot devices = %v, want %v", got, tt.devices)
+			}
+
+			for _, disk := range domcfg.Devices.Disks {
+				if disk.Boot != nil {
+					t.Errorf("disk %s kept its boot order", disk.Target.Dev)
+				}
+			}
+		})
+	}
+}
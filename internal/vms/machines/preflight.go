@@ -0,0 +1,171 @@
+package machines
+
+import (
+	"context"
+	"fmt"
+	"snoman/internal/biputils"
+	"snoman/internal/preflight"
+	"snoman/internal/vms/hypervisor"
+	vmutils "snoman/internal/vms/utils"
+)
+
+const (
+	VIRT_INSTALL_BIN = "virt-install"
+	QEMU_SYSTEM_BIN  = "qemu-system-%s" // The emulator of a guest architecture
+	memoryHeadroom   = 1.1              // Warn if less than 10% of free memory would be left after starting the VM
+)
+
+// Preflight will check that the host has the resources and tools needed to create the virtual machine
+func Preflight(ctx context.Context, spec *VirtualMachineSpec) (*preflight.Report, error) {
+	report := &preflight.Report{}
+
+	hv := hypervisor.Get()
+
+	host, err := hv.HostInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get hypervisor host info: %w", err)
+	}
+
+	checkHostMemory(report, host, spec)
+	checkHostCPU(report, host, spec)
+	guest, emulated := checkHostArch(report, host, spec)
+	if spec.Disk != nil {
+		checkStoragePool(ctx, report, hv, spec.Disk)
+	}
+	checkInterfaceNetworks(ctx, report, hv, spec)
+
+	// The kvm checks look at this host, which is not the hypervisor when connected remotely or simulated
+	switch {
+	case hypervisor.IsSimulated():
+		report.Pass("kvm", "not needed by simulated hypervisor %s", vmutils.GetLibvirtURI())
+	case vmutils.IsRemoteLibvirt():
+		report.Warn("kvm", "not checked on remote hypervisor %s", vmutils.GetLibvirtURI())
+		report.Warn("nested-virt", "not checked on remote hypervisor %s", vmutils.GetLibvirtURI())
+	case emulated:
+		// Emulated guests run on qemu alone
+		emulator := fmt.Sprintf(QEMU_SYSTEM_BIN, guest)
+		preflight.CheckBinary(report, emulator, emulator)
+	default:
+		preflight.CheckKVM(report)
+		preflight.CheckNestedVirt(report)
+	}
+
+	// Simulated hypervisors define the domain directly instead of running virt-install
+	if !hypervisor.IsSimulated() {
+		preflight.CheckBinary(report, VIRT_INSTALL_BIN, VIRT_INSTALL_BIN)
+	}
+
+	return report, nil
+}
+
+// PreflightInstaller will add the installer check to the report when the ISO of the bip spec still has to be
+// generated, so a workflow can find out before it starts generating
+func PreflightInstaller(report *preflight.Report, bipSpec *biputils.BootstrapInPlaceIsoSpec) {
+	if bipSpec != nil && bipSpec.IsoPath != "" {
+		return
+	}
+
+	abiPath := biputils.DEFAULT_ABI_PATH
+	if bipSpec != nil {
+		switch {
+		case bipSpec.AbiPath != "":
+			abiPath = bipSpec.AbiPath
+		case bipSpec.InstallerDir != "":
+			// The installer of the release is only known once the release is resolved
+			report.Pass("openshift-install", "picked from %s for the release", bipSpec.InstallerDir)
+			return
+		}
+	}
+
+	preflight.CheckBinary(report, "openshift-install", abiPath)
+}
+
+func checkHostMemory(r *preflight.Report, host *hypervisor.HostInfo, spec *VirtualMachineSpec) {
+	freeMB := host.FreeMemoryBytes / (1024 * 1024)
+	needMB := uint64(spec.RAM)
+
+	switch {
+	case freeMB < needMB:
+		r.Fail("memory", "%d MB requested but only %d MB free", needMB, freeMB)
+	case float64(freeMB) < float64(needMB)*memoryHeadroom:
+		r.Warn("memory", "%d MB requested and %d MB free, the host will be left with little headroom", needMB, freeMB)
+	default:
+		r.Pass("memory", "%d MB requested, %d MB free", needMB, freeMB)
+	}
+}
+
+func checkHostCPU(r *preflight.Report, host *hypervisor.HostInfo, spec *VirtualMachineSpec) {
+	// libvirt will happily overcommit CPUs, so this is only a warning
+	if host.CPUs < spec.CPU {
+		r.Warn("cpu", "%d vCPUs requested but the host only has %d", spec.CPU, host.CPUs)
+		return
+	}
+
+	r.Pass("cpu", "%d vCPUs requested, the host has %d", spec.CPU, host.CPUs)
+}
+
+// checkHostArch will warn when the guest has to be emulated, and return its architecture
+func checkHostArch(r *preflight.Report, host *hypervisor.HostInfo, spec *VirtualMachineSpec) (string, bool) {
+	hostArch := normalizeHostArch(host)
+	guest, emulated := spec.guestArch(hostArch)
+	if _, ok := machineTypes[guest]; !ok {
+		r.Fail("arch", "virtual machines of architecture '%s' are not supported", guest)
+		return guest, emulated
+	}
+
+	if emulated {
+		r.Warn("arch", "%s guest will be emulated on the %s host, expect the install to take several times longer", guest, hostArch)
+		return guest, emulated
+	}
+
+	r.Pass("arch", "%s guest runs natively", guest)
+
+	return guest, emulated
+}
+
+func checkStoragePool(ctx context.Context, r *preflight.Report, hv hypervisor.Hypervisor, disk *VirtualMachineDiskSpec) {
+	name := fmt.Sprintf("pool '%s'", disk.Pool)
+
+	pool, err := hv.LookupPool(ctx, disk.Pool)
+	if err != nil {
+		r.Fail(name, "storage pool not found: %v", err)
+		return
+	}
+
+	if !pool.Active {
+		r.Fail(name, "storage pool is not active")
+		return
+	}
+
+	availGB := pool.Available / (1024 * 1024 * 1024)
+	if availGB < uint64(disk.Size) {
+		r.Fail(name, "%d GB disk requested but only %d GB available", disk.Size, availGB)
+		return
+	}
+
+	r.Pass(name, "%d GB disk requested, %d GB available", disk.Size, availGB)
+}
+
+// checkInterfaceNetworks will make sure the networks the interfaces attach to exist. The VM network is
+// created along with the VM so it is not checked
+func checkInterfaceNetworks(ctx context.Context, r *preflight.Report, hv hypervisor.Hypervisor, spec *VirtualMachineSpec) {
+	checked := map[string]bool{}
+	if spec.Network != nil {
+		checked[spec.Network.Name] = true
+	}
+
+	for _, iface := range spec.GetInterfaces() {
+		if checked[iface.Network] {
+			continue
+		}
+		checked[iface.Network] = true
+
+		name := fmt.Sprintf("network '%s'", iface.Network)
+		if _, err := hv.LookupNetwork(ctx, iface.Network); err != nil {
+			r.Fail(name, "network for interface '%s' not found: %v", iface.Name, err)
+			continue
+		}
+
+		r.Pass(name, "network for interface '%s' exists", iface.Name)
+	}
+}
@@ -0,0 +1,69 @@
+package machines
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"snoman/internal/logger"
+	"snoman/internal/vms/hypervisor"
+	"snoman/internal/vms/storage"
+)
+
+const (
+	SNOMAN_METADATA_URI    = "https://github.com/jeff-roche/ib-orchestrator/xmlns/resources/1.0"
+	SNOMAN_METADATA_PREFIX = "snoman"
+)
+
+// resourceRecord is stored in the domain metadata so snoman knows what to clean up with the VM
+type resourceRecord struct {
+	XMLName xml.Name       `xml:"resources"`
+	Volumes []volumeRecord `xml:"volume"`
+}
+
+type volumeRecord struct {
+	Pool string `xml:"pool,attr"`
+	Name string `xml:"name,attr"`
+}
+
+// recordVolumes will store the volumes in the metadata of the domain. This fails if the domain is not defined yet
+func recordVolumes(ctx context.Context, name string, volumes []volumeRecord) error {
+	data, err := xml.Marshal(resourceRecord{Volumes: volumes})
+	if err != nil {
+		return fmt.Errorf("unable to generate resource record: %w", err)
+	}
+
+	err = hypervisor.Get().SetDomainMetadata(ctx, name, SNOMAN_METADATA_PREFIX, SNOMAN_METADATA_URI, string(data))
+	if err != nil {
+		return fmt.Errorf("unable to record resources on domain '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+// getRecordedVolumes will return the volumes recorded for the domain, or nothing if snoman did not create it
+func getRecordedVolumes(ctx context.Context, id string) []volumeRecord {
+	data, err := hypervisor.Get().GetDomainMetadata(ctx, id, SNOMAN_METADATA_URI)
+	if err != nil {
+		return nil
+	}
+
+	record := &resourceRecord{}
+	if err := xml.Unmarshal([]byte(data), record); err != nil {
+		logger.Get().Warnf("unable to parse the snoman resource record: %v", err)
+		return nil
+	}
+
+	return record.Volumes
+}
+
+func deleteVolumes(ctx context.Context, volumes []volumeRecord) error {
+	errs := []error{}
+	for _, vol := range volumes {
+		if err := storage.DeleteVolume(ctx, vol.Pool, vol.Name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
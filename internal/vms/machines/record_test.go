@@ -0,0 +1,75 @@
+package machines
+
+import (
+	"context"
+	"reflect"
+	"snoman/internal/vms/hypervisor/hypervisortest"
+	"strings"
+	"testing"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+func TestRecordVolumes(t *testing.T) {
+	tests := []struct {
+		name    string
+		domain  string
+		volumes []volumeRecord
+		wantErr string
+	}{
+		{
+			name:    "disk",
+			domain:  "recorded-vm",
+			volumes: []volumeRecord{{Pool: "default", Name: "recorded-vm.qcow2"}},
+		},
+		{
+			name:   "disk and uploaded iso",
+			domain: "recorded-vm",
+			volumes: []volumeRecord{
+				{Pool: "default", Name: "recorded-vm.qcow2"},
+				{Pool: "images", Name: "recorded-vm-installer.iso"},
+			},
+		},
+		{
+			name:    "domain not defined yet",
+			domain:  "missing-vm",
+			volumes: []volumeRecord{{Pool: "default", Name: "missing-vm.qcow2"}},
+			wantErr: "unable to record resources on domain 'missing-vm'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := hypervisortest.UseFake(t, GetDefaultVirtualMachineDiskSpec().Pool)
+
+			if err := fake.DefineDomain(ctx, &libvirtxml.Domain{Name: "recorded-vm"}); err != nil {
+				t.Fatal(err)
+			}
+
+			err := recordVolumes(ctx, tt.domain, tt.volumes)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("recordVolumes() error = %v, want %q", err, tt.wantErr)
+				}
+
+				if got := getRecordedVolumes(ctx, tt.domain); got != nil {
+					t.Errorf("getRecordedVolumes() = %+v, want nothing", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("recordVolumes() error = %v", err)
+			}
+
+			metadata, err := fake.GetDomainMetadata(ctx, tt.domain, SNOMAN_METADATA_URI)
+			if err != nil || !strings.HasPrefix(metadata, "<resources>") {
+				t.Errorf("metadata = %q, %v, want a resources record", metadata, err)
+			}
+
+			if got := getRecordedVolumes(ctx, tt.domain); !reflect.DeepEqual(got, tt.volumes) {
+				t.Errorf("getRecordedVolumes() = %+v, want %+v", got, tt.volumes)
+			}
+		})
+	}
+}
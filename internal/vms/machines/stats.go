@@ -0,0 +1,221 @@
+package machines
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"snoman/internal/logger"
+	"snoman/internal/vms/hypervisor"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StatsSample is one point of the resource usage time series of a virtual machine
+type StatsSample struct {
+	Time           time.Time `json:"time"`
+	CPUTimeNs      uint64    `json:"cpu_time_ns"`
+	CPUPercent     float64   `json:"cpu_percent"` // 100 is one host cpu fully used since the previous sample
+	MemoryBytes    uint64    `json:"memory_bytes"`
+	RSSBytes       uint64    `json:"rss_bytes"`
+	DiskReadBytes  uint64    `json:"disk_read_bytes"`
+	DiskWriteBytes uint64    `json:"disk_write_bytes"`
+	DiskReadReqs   uint64    `json:"disk_read_reqs"`
+	DiskWriteReqs  uint64    `json:"disk_write_reqs"`
+	NetRxBytes     uint64    `json:"net_rx_bytes"`
+	NetTxBytes     uint64    `json:"net_tx_bytes"`
+}
+
+// NewStatsSample will convert the hypervisor stats into a sample, using prev to work out the cpu usage
+// prev can be nil for the first sample
+func NewStatsSample(prev *hypervisor.DomainStats, cur *hypervisor.DomainStats) StatsSample {
+	sample := StatsSample{
+		Time:           cur.Time,
+		CPUTimeNs:      cur.CPUTimeNs,
+		MemoryBytes:    cur.MemoryBytes,
+		RSSBytes:       cur.RSSBytes,
+		DiskReadBytes:  cur.DiskReadBytes,
+		DiskWriteBytes: cur.DiskWriteBytes,
+		DiskReadReqs:   cur.DiskReadReqs,
+		DiskWriteReqs:  cur.DiskWriteReqs,
+		NetRxBytes:     cur.NetRxBytes,
+		NetTxBytes:     cur.NetTxBytes,
+	}
+
+	// The counters reset if the domain restarted in between
+	if prev != nil && cur.CPUTimeNs >= prev.CPUTimeNs {
+		if wall := cur.Time.Sub(prev.Time); wall > 0 {
+			sample.CPUPercent = float64(cur.CPUTimeNs-prev.CPUTimeNs) / float64(wall.Nanoseconds()) * 100
+		}
+	}
+
+	return sample
+}
+
+// StatsWriter writes the samples of a time series
+type StatsWriter interface {
+	Write(sample StatsSample) error
+}
+
+// NewStatsWriter will return a writer for the csv or jsonl format
+func NewStatsWriter(out io.Writer, format string) (StatsWriter, error) {
+	switch format {
+	case STATS_FORMAT_CSV, "":
+		return &csvStatsWriter{out: csv.NewWriter(out)}, nil
+	case STATS_FORMAT_JSONL:
+		return &jsonlStatsWriter{out: json.NewEncoder(out)}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported stats format '%s'", format)
+}
+
+type csvStatsWriter struct {
+	out           *csv.Writer
+	headerWritten bool
+}
+
+var csvStatsHeader = []string{
+	"time", "cpu_time_ns", "cpu_percent", "memory_bytes", "rss_bytes",
+	"disk_read_bytes", "disk_write_bytes", "disk_read_reqs", "disk_write_reqs",
+	"net_rx_bytes", "net_tx_bytes",
+}
+
+func (w *csvStatsWriter) Write(sample StatsSample) error {
+	if !w.headerWritten {
+		if err := w.out.Write(csvStatsHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	u := func(v uint64) string { return strconv.FormatUint(v, 10) }
+
+	err := w.out.Write([]string{
+		sample.Time.Format(time.RFC3339),
+		u(sample.CPUTimeNs),
+		strconv.FormatFloat(sample.CPUPercent, 'f', 1, 64),
+		u(sample.MemoryBytes),
+		u(sample.RSSBytes),
+		u(sample.DiskReadBytes),
+		u(sample.DiskWriteBytes),
+		u(sample.DiskReadReqs),
+		u(sample.DiskWriteReqs),
+		u(sample.NetRxBytes),
+		u(sample.NetTxBytes),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Flush every row so the file is useful while the workflow is still running
+	w.out.Flush()
+
+	return w.out.Error()
+}
+
+type jsonlStatsWriter struct {
+	out *json.Encoder
+}
+
+func (w *jsonlStatsWriter) Write(sample StatsSample) error {
+	return w.out.Encode(sample)
+}
+
+// SampleStats will sample the domain every interval and pass each sample to fn until ctx is done.
+// Samples are skipped while the domain is not defined or not running, so this can be started before the VM exists
+func SampleStats(ctx context.Context, name string, interval time.Duration, fn func(StatsSample) error) error {
+	log := logger.Get()
+	hv := hypervisor.Get()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev *hypervisor.DomainStats
+	for {
+		cur, err := hv.DomainStats(ctx, name)
+		if err == nil {
+			if err := fn(NewStatsSample(prev, cur)); err != nil {
+				return err
+			}
+			prev = cur
+		} else if !errors.Is(err, context.Canceled) {
+			log.Debugf("skipping stats sample of '%s': %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// StatsRecorder writes the stats time series of a virtual machine into the logs folder of its working directory
+type StatsRecorder struct {
+	cancel context.CancelFunc
+	done   sync.WaitGroup
+	file   io.Closer
+}
+
+// StartStatsRecorder will start sampling the virtual machine in the background. It returns nil if the spec has
+// no working directory or stats are disabled
+func StartStatsRecorder(ctx context.Context, spec *VirtualMachineSpec) (*StatsRecorder, error) {
+	stats := spec.Stats
+	if stats == nil {
+		stats = &VirtualMachineStatsSpec{}
+	}
+
+	if spec.Workdir == "" || stats.Disabled {
+		return nil, nil
+	}
+
+	interval := stats.IntervalSeconds
+	if interval == 0 {
+		interval = DEFAULT_STATS_INTERVAL_SECONDS
+	}
+
+	format := stats.Format
+	if format == "" {
+		format = STATS_FORMAT_CSV
+	}
+
+	file, err := logger.OpenLogFile(spec.Workdir, fmt.Sprintf("%s-stats.%s", spec.Name, format))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the stats file: %w", err)
+	}
+
+	w, err := NewStatsWriter(file, format)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r := &StatsRecorder{cancel: cancel, file: file}
+
+	r.done.Add(1)
+	go func() {
+		defer r.done.Done()
+
+		if err := SampleStats(ctx, spec.Name, time.Duration(interval)*time.Second, w.Write); err != nil {
+			logger.Get().Warnf("stopped recording stats of '%s': %v", spec.Name, err)
+		}
+	}()
+
+	return r, nil
+}
+
+// Stop will stop sampling and close the stats file. It is safe to call on a nil recorder
+func (r *StatsRecorder) Stop() error {
+	if r == nil {
+		return nil
+	}
+
+	r.cancel()
+	r.done.Wait()
+
+	return r.file.Close()
+}
@@ -2,11 +2,16 @@ package machines
 
 import (
 	"fmt"
+	"regexp"
+	"snoman/internal/arch"
 	"snoman/internal/biputils"
 	"snoman/internal/vms/network"
 	vmutils "snoman/internal/vms/utils"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
+	"libvirt.org/go/libvirtxml"
 )
 
 type VirtualMachineSpec struct {
@@ -18,6 +23,38 @@ type VirtualMachineSpec struct {
 	Variant string                             `yaml:"os_variant" validate:"required"`
 	Workdir string                             `yaml:"working_directory,omitempty" validate:"omitempty,dirpath"`
 	BipSpec *biputils.BootstrapInPlaceIsoSpec  `yaml:"bip,omitempty" validate:"omitempty"`
+	URI     string                             `yaml:"connection_uri,omitempty" validate:"omitempty,uri"`
+	Stats   *VirtualMachineStatsSpec           `yaml:"stats,omitempty" validate:"omitempty"`
+	PxeBoot bool                               `yaml:"pxe_boot,omitempty" validate:"omitempty"`
+	// ClockOffset starts the guest clock away from the host clock, ex: 90d, 1y6mo, -12h
+	ClockOffset string `yaml:"clock_offset,omitempty" validate:"omitempty"`
+	// Interfaces replace the single interface on Network, Bonds group them in the guest
+	Interfaces []VirtualMachineInterfaceSpec `yaml:"interfaces,omitempty" validate:"omitempty,dive"`
+	Bonds      []VirtualMachineBondSpec      `yaml:"bonds,omitempty" validate:"omitempty,dive"`
+	// Arch is the guest architecture by either of its names, ex: aarch64 or arm64. The hypervisor architecture
+	// is used when empty, any other architecture is emulated and much slower
+	Arch string `yaml:"arch,omitempty" validate:"omitempty"`
+}
+
+// VirtualMachineInterfaceSpec is a NIC attached to a libvirt network. VLANs are tagged by the guest through
+// the agent config NMState, so the network has to carry the tagged traffic. The VM network snoman creates does
+// not, VLANs are only accepted on networks that already exist. The first interface gets the host IP, on the VM
+// network it has to have the MAC of the DHCP host entry
+type VirtualMachineInterfaceSpec struct {
+	Name       string `yaml:"name" validate:"required"`
+	Network    string `yaml:"network" validate:"required"`
+	MacAddress string `yaml:"mac_address" validate:"required,mac"`
+	Model      string `yaml:"model,omitempty" validate:"omitempty,oneof=virtio e1000e"`
+	VlanID     uint   `yaml:"vlan_id,omitempty" validate:"omitempty,min=1,max=4094"`
+	LinkState  string `yaml:"link_state,omitempty" validate:"omitempty,oneof=up down"`
+	Bond       string `yaml:"bond,omitempty" validate:"omitempty"`
+}
+
+// VirtualMachineBondSpec is a guest bond of the interfaces that name it
+type VirtualMachineBondSpec struct {
+	Name   string `yaml:"name" validate:"required"`
+	Mode   string `yaml:"mode" validate:"required,oneof=balance-rr active-backup balance-xor broadcast 802.3ad balance-tlb balance-alb"`
+	VlanID uint   `yaml:"vlan_id,omitempty" validate:"omitempty,min=1,max=4094"`
 }
 
 type VirtualMachineDiskSpec struct {
@@ -25,17 +62,40 @@ type VirtualMachineDiskSpec struct {
 	Size        uint   `yaml:"size_gb" validate:"required"`
 	Check       bool   `yaml:"disk_check,omitempty" validate:"omitempty"`
 	InstallDisk string `yaml:"install_disk,omitempty" validate:"omitempty"`
+	path        string
+	volume      string
+}
+
+// VirtualMachineStatsSpec controls the resource usage time series written to the logs folder during workflows
+type VirtualMachineStatsSpec struct {
+	Disabled        bool   `yaml:"disabled,omitempty" validate:"omitempty"`
+	IntervalSeconds uint   `yaml:"interval_seconds,omitempty" validate:"omitempty"`
+	Format          string `yaml:"format,omitempty" validate:"omitempty,oneof=csv jsonl"`
 }
 
 const (
-	DEFAULT_VM_NAME string = "default-sno-vm"
+	DEFAULT_VM_NAME       string = "default-sno-vm"
+	DEFAULT_VM_CPU        uint   = 8
+	DEFAULT_VM_RAM_MB     uint   = 16384
+	DEFAULT_VM_OS_VARIANT string = "rhel8.1"
+
+	DEFAULT_STATS_INTERVAL_SECONDS uint = 10
+	STATS_FORMAT_CSV                    = "csv"
+	STATS_FORMAT_JSONL                  = "jsonl"
+
+	DEFAULT_INTERFACE_NAME  string = "eno1"
+	DEFAULT_INTERFACE_MODEL string = "virtio"
+	LINK_STATE_DOWN         string = "down"
 )
 
 func GetDefaultVirtualMachineSpec() *VirtualMachineSpec {
 	spec := &VirtualMachineSpec{
 		Name:    DEFAULT_VM_NAME,
 		Network: network.GetDefaultVirtualMachineNetworkSpec(),
+		CPU:     DEFAULT_VM_CPU,
+		RAM:     DEFAULT_VM_RAM_MB,
 		Disk:    GetDefaultVirtualMachineDiskSpec(),
+		Variant: DEFAULT_VM_OS_VARIANT,
 	}
 
 	return spec
@@ -57,6 +117,20 @@ func (spec VirtualMachineSpec) Validate() error {
 		return fmt.Errorf("unable to validate VirtualMachineSpec: %w", err)
 	}
 
+	if err := spec.validateInterfaces(); err != nil {
+		return fmt.Errorf("unable to validate VirtualMachineSpec: %w", err)
+	}
+
+	if _, err := arch.Normalize(spec.Arch); err != nil {
+		return fmt.Errorf("unable to validate VirtualMachineSpec: %w", err)
+	}
+
+	if spec.ClockOffset != "" {
+		if _, err := ClockOffsetSeconds(spec.ClockOffset, time.Now()); err != nil {
+			return fmt.Errorf("unable to validate VirtualMachineSpec: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -85,3 +159,280 @@ func (spec *VirtualMachineSpec) UnmarshalYAML(yamlData []byte) error {
 
 	return nil
 }
+
+// libosinfoDomains maps os variant families to the libosinfo id domain used in the domain metadata
+var libosinfoDomains = map[string]string{
+	"rhel":          "redhat.com",
+	"fedora":        "fedoraproject.org",
+	"centos-stream": "centos.org",
+}
+
+var osVariantRegex = regexp.MustCompile(`^([a-z-]+?)(\d[\d.]*)$`)
+
+var libosinfoRegex = regexp.MustCompile(`<libosinfo:os id="https?://[^/]+/([^/"]+)/([^/"]+)"`)
+
+// isoVolumeName is the name of the volume the installer ISO is uploaded to on remote hypervisors
+func (spec VirtualMachineSpec) isoVolumeName() string {
+	return fmt.Sprintf("%s-installer.iso", spec.Name)
+}
+
+// diskVolumeName is the name of the volume holding the VM disk, this matches what virt-install generates
+func (spec VirtualMachineSpec) diskVolumeName() string {
+	return fmt.Sprintf("%s.qcow2", spec.Name)
+}
+
+// MarshalXML will render the libvirt domain XML that would be defined for the spec
+func (spec VirtualMachineSpec) MarshalXML() (string, error) {
+	domcfg, err := spec.toLibvirtxml(arch.Host())
+	if err != nil {
+		return "", err
+	}
+
+	return domcfg.Marshal()
+}
+
+// toLibvirtxml will render the domain for a hypervisor of the host architecture
+func (spec VirtualMachineSpec) toLibvirtxml(host string) (*libvirtxml.Domain, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	guest, emulated := spec.guestArch(host)
+	machine, ok := machineTypes[guest]
+	if !ok {
+		return nil, fmt.Errorf("virtual machines of architecture '%s' are not supported", guest)
+	}
+
+	domcfg := &libvirtxml.Domain{
+		Type: "kvm",
+		Name: spec.Name,
+		Memory: &libvirtxml.DomainMemory{
+			Value: spec.RAM,
+			Unit:  "MiB",
+		},
+		VCPU: &libvirtxml.DomainVCPU{
+			Value: spec.CPU,
+		},
+		OS: &libvirtxml.DomainOS{
+			Type: &libvirtxml.DomainOSType{
+				Arch:    guest,
+				Machine: machine,
+				Type:    "hvm",
+			},
+			BootDevices: []libvirtxml.DomainBootDevice{
+				{Dev: "hd"},
+				{Dev: "cdrom"},
+			},
+		},
+		Features: &libvirtxml.DomainFeatureList{
+			ACPI: &libvirtxml.DomainFeature{},
+			APIC: &libvirtxml.DomainFeatureAPIC{},
+		},
+		CPU: &libvirtxml.DomainCPU{
+			Mode: "host-passthrough",
+		},
+		OnReboot: "restart",
+		Devices: &libvirtxml.DomainDeviceList{
+			Disks: []libvirtxml.DomainDisk{
+				{
+					Device: "disk",
+					Driver: &libvirtxml.DomainDiskDriver{Name: "qemu", Type: "qcow2"},
+					Source: &libvirtxml.DomainDiskSource{
+						Volume: &libvirtxml.DomainDiskSourceVolume{
+							Pool:   spec.Disk.Pool,
+							Volume: spec.diskVolumeName(),
+						},
+					},
+					Target: &libvirtxml.DomainDiskTarget{Dev: "vda", Bus: "virtio"},
+				},
+			},
+			Consoles: []libvirtxml.DomainConsole{
+				{
+					Source: &libvirtxml.DomainChardevSource{Pty: &libvirtxml.DomainChardevSourcePty{}},
+					Target: &libvirtxml.DomainConsoleTarget{Type: "serial"},
+				},
+			},
+		},
+	}
+
+	// A foreign architecture is emulated by qemu instead of run by kvm, with every cpu feature qemu has
+	if emulated {
+		domcfg.Type = "qemu"
+		domcfg.CPU = &libvirtxml.DomainCPU{Mode: "maximum"}
+	}
+
+	// The APIC is x86 only and aarch64 guests only boot from UEFI
+	if guest != arch.X86_64 {
+		domcfg.Features.APIC = nil
+		domcfg.OS.Firmware = "efi"
+	}
+
+	// Network booting machines fall back to the disk once it has been installed to
+	if spec.PxeBoot {
+		domcfg.OS.BootDevices = []libvirtxml.DomainBootDevice{
+			{Dev: "network"},
+			{Dev: "hd"},
+		}
+	}
+
+	if !spec.PxeBoot && spec.BipSpec != nil && spec.BipSpec.IsoPath != "" {
+		domcfg.Devices.Disks = append(domcfg.Devices.Disks, libvirtxml.DomainDisk{
+			Device: "cdrom",
+			Driver: &libvirtxml.DomainDiskDriver{Name: "qemu", Type: "raw"},
+			Source: &libvirtxml.DomainDiskSource{
+				File: &libvirtxml.DomainDiskSourceFile{File: spec.BipSpec.IsoPath},
+			},
+			Target:   &libvirtxml.DomainDiskTarget{Dev: "sda", Bus: cdromBus(guest)},
+			ReadOnly: &libvirtxml.DomainDiskReadOnly{},
+		})
+	}
+
+	if spec.ClockOffset != "" {
+		adjustment, err := ClockOffsetSeconds(spec.ClockOffset, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		domcfg.Clock = clockToLibvirtxml(nil, adjustment)
+	}
+
+	for _, iface := range spec.GetInterfaces() {
+		domcfg.Devices.Interfaces = append(domcfg.Devices.Interfaces, iface.toLibvirtxml())
+	}
+
+	if matches := osVariantRegex.FindStringSubmatch(spec.Variant); matches != nil {
+		if domain, ok := libosinfoDomains[matches[1]]; ok {
+			domcfg.Metadata = &libvirtxml.DomainMetadata{
+				XML: fmt.Sprintf(`<libosinfo:libosinfo xmlns:libosinfo="http://libosinfo.org/xmlns/libvirt/domain/1.0"><libosinfo:os id="http://%s/%s/%s"/></libosinfo:libosinfo>`, domain, matches[1], matches[2]),
+			}
+		}
+	}
+
+	return domcfg, nil
+}
+
+// UnmarshalXML will fill in the spec from a libvirt domain XML. Information that lives outside of the domain,
+// like the disk size or the network configuration, will need to be looked up separately
+func (spec *VirtualMachineSpec) UnmarshalXML(xmlData []byte) error {
+	domcfg := &libvirtxml.Domain{}
+
+	if err := domcfg.Unmarshal(string(xmlData)); err != nil {
+		return fmt.Errorf("unable to parse libvirt xml: %w", err)
+	}
+
+	if err := spec.fromLibvirtxml(domcfg); err != nil {
+		return fmt.Errorf("unable to generate spec from xml: %w", err)
+	}
+
+	return nil
+}
+
+func (spec *VirtualMachineSpec) fromLibvirtxml(dom *libvirtxml.Domain) error {
+	spec.Name = dom.Name
+
+	if dom.VCPU != nil {
+		spec.CPU = dom.VCPU.Value
+	}
+
+	if dom.Memory != nil {
+		ram, err := toMiB(dom.Memory.Value, dom.Memory.Unit)
+		if err != nil {
+			return err
+		}
+		spec.RAM = ram
+	}
+
+	if dom.OS != nil && dom.OS.Type != nil {
+		guest, err := arch.Normalize(dom.OS.Type.Arch)
+		if err != nil {
+			return fmt.Errorf("domain '%s' has an unsupported architecture: %w", dom.Name, err)
+		}
+		spec.Arch = guest
+	}
+
+	if dom.Metadata != nil {
+		if matches := libosinfoRegex.FindStringSubmatch(dom.Metadata.XML); matches != nil {
+			spec.Variant = matches[1] + matches[2]
+		}
+	}
+
+	if adjustment := clockAdjustment(dom.Clock); adjustment != 0 {
+		spec.ClockOffset = fmt.Sprintf("%ds", adjustment)
+	}
+
+	if dom.Devices == nil {
+		return nil
+	}
+
+	// Disks, the first disk is the install target and the first cdrom is the installer ISO
+	for _, disk := range dom.Devices.Disks {
+		if disk.Source == nil {
+			continue
+		}
+
+		switch disk.Device {
+		case "cdrom":
+			if spec.BipSpec == nil && disk.Source.File != nil && disk.Source.File.File != "" {
+				spec.BipSpec = &biputils.BootstrapInPlaceIsoSpec{IsoPath: disk.Source.File.File}
+			}
+		case "disk", "":
+			if spec.Disk != nil {
+				continue
+			}
+
+			spec.Disk = &VirtualMachineDiskSpec{}
+			if disk.Source.Volume != nil {
+				spec.Disk.Pool = disk.Source.Volume.Pool
+				spec.Disk.volume = disk.Source.Volume.Volume
+			} else if disk.Source.File != nil {
+				spec.Disk.path = disk.Source.File.File
+			}
+
+			if disk.Target != nil && disk.Target.Dev != "" {
+				spec.Disk.InstallDisk = fmt.Sprintf("/dev/%s", disk.Target.Dev)
+			}
+		}
+	}
+
+	// Network, the first interface attached to a libvirt network is the VM network
+	ifaces := []VirtualMachineInterfaceSpec{}
+	for _, domiface := range dom.Devices.Interfaces {
+		if domiface.Source == nil || domiface.Source.Network == nil {
+			continue
+		}
+
+		ifaces = append(ifaces, interfaceFromLibvirtxml(domiface, len(ifaces)))
+	}
+
+	if len(ifaces) > 0 {
+		spec.Network = &network.VirtualMachineNetworkSpec{Name: ifaces[0].Network, MacAddress: ifaces[0].MacAddress}
+	}
+
+	// A single default interface is described by the network alone
+	if len(ifaces) > 1 || (len(ifaces) == 1 && ifaces[0].Name != DEFAULT_INTERFACE_NAME) {
+		spec.Interfaces = ifaces
+	}
+
+	return nil
+}
+
+func toMiB(value uint, unit string) (uint, error) {
+	switch strings.ToLower(unit) {
+	case "b", "bytes":
+		return value / (1024 * 1024), nil
+	case "", "k", "kib":
+		return value / 1024, nil
+	case "kb":
+		return uint(uint64(value) * 1000 / (1024 * 1024)), nil
+	case "m", "mib":
+		return value, nil
+	case "mb":
+		return uint(uint64(value) * 1000 * 1000 / (1024 * 1024)), nil
+	case "g", "gib":
+		return value * 1024, nil
+	case "gb":
+		return uint(uint64(value) * 1000 * 1000 * 1000 / (1024 * 1024)), nil
+	}
+
+	return 0, fmt.Errorf("unsupported memory unit '%s'", unit)
+}
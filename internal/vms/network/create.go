@@ -1,65 +1,49 @@
 package network
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"snoman/internal/logger"
-	vmutils "snoman/internal/vms/utils"
+	"snoman/internal/vms/hypervisor"
 )
 
-func Create(spec *VirtualMachineNetworkSpec) error {
+func Create(ctx context.Context, spec *VirtualMachineNetworkSpec) error {
 	log := logger.Get()
+	hv := hypervisor.Get()
 
-	lvc, err := vmutils.GetLibvirtConnection()
-	if err != nil {
-		return fmt.Errorf("unable to initialize libvirt connection: %w", err)
-	}
-	defer lvc.Close()
+	// Make sure this network does not already exist
+	for _, id := range []string{spec.Name, spec.UUID} {
+		if id == "" {
+			continue
+		}
 
-	// Make sure we have an active libvirt connection
-	if alive, err := lvc.IsAlive(); !alive {
-		return fmt.Errorf("can not create virtual machine network, libvirt connection is not alive: %w", err)
-	}
+		dup, err := hv.LookupNetwork(ctx, id)
+		if err == nil {
+			if netxml, err := dup.Marshal(); err == nil {
+				log.Debugln("Duplicate network found")
+				log.Debugf("Duplicate network XML:\n%s", netxml)
+			}
 
-	// Make sure this network does not already exist
-	dupNameNet := findNetworkByNameOrUUID(spec.Name, lvc)
-	dupUuidNet := findNetworkByNameOrUUID(spec.UUID, lvc)
-	if dupNameNet != nil || dupUuidNet != nil {
-		var netxml string
-		var err error
+			if dup.Name == spec.Name {
+				return fmt.Errorf("a network with name '%s' already exists", spec.Name)
+			}
 
-		if dupNameNet != nil {
-			netxml, _ = dupNameNet.GetXMLDesc(0)
-			err = fmt.Errorf("a network with name '%s' already exists", spec.Name)
-			dupNameNet.Free()
-		} else {
-			netxml, _ = dupUuidNet.GetXMLDesc(0)
-			err = fmt.Errorf("a network with UUID '%s' already exists", spec.UUID)
-			dupUuidNet.Free()
+			return fmt.Errorf("a network with UUID '%s' already exists", spec.UUID)
 		}
 
-		log.Debugln("Duplicate network found")
-		log.Debugf("Duplicate network XML:\n%s", netxml)
-		return err
+		if !errors.Is(err, hypervisor.ErrNotFound) {
+			return fmt.Errorf("unable to check for duplicate networks: %w", err)
+		}
 	}
 
-	netxml, err := spec.MarshalXML()
+	netcfg, err := spec.toLibvirtxml()
 	if err != nil {
 		return fmt.Errorf("unable to generate network configuration: %w", err)
 	}
 
-	net, err := lvc.NetworkDefineXML(netxml)
-	if err != nil {
-		return fmt.Errorf("unable to define the vm network: %w", err)
-	}
-
-	err = net.SetAutostart(true)
-	if err != nil {
-		return fmt.Errorf("unable to set the network to autostart: %w", err)
-	}
-
-	err = net.Create()
-	if err != nil {
-		return fmt.Errorf("unable to create the network: %w", err)
+	if err := hv.CreateNetwork(ctx, netcfg); err != nil {
+		return fmt.Errorf("unable to create the vm network: %w", err)
 	}
 
 	log.Infof("successfully created network '%s' with UUID '%s'", spec.Name, spec.UUID)
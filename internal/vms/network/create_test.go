@@ -0,0 +1,104 @@
+package network
+
+import (
+	"context"
+	"snoman/internal/vms/hypervisor/hypervisortest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCreate(t *testing.T) {
+	existing := GetDefaultVirtualMachineNetworkSpec()
+
+	tests := []struct {
+		name    string
+		spec    func() *VirtualMachineNetworkSpec
+		wantErr string
+	}{
+		{
+			name: "default network",
+			spec: func() *VirtualMachineNetworkSpec {
+				spec := GetDefaultVirtualMachineNetworkSpec()
+				spec.Name = "other-network"
+				return spec
+			},
+		},
+		{
+			name: "without a uuid",
+			spec: func() *VirtualMachineNetworkSpec {
+				spec := GetDefaultVirtualMachineNetworkSpec()
+				spec.Name = "other-network"
+				spec.UUID = ""
+				return spec
+			},
+		},
+		{
+			name: "duplicate name",
+			spec: func() *VirtualMachineNetworkSpec {
+				spec := GetDefaultVirtualMachineNetworkSpec()
+				spec.UUID = uuid.NewString()
+				return spec
+			},
+			wantErr: "a network with name '" + existing.Name + "' already exists",
+		},
+		{
+			name: "duplicate uuid",
+			spec: func() *VirtualMachineNetworkSpec {
+				spec := GetDefaultVirtualMachineNetworkSpec()
+				spec.Name = "other-network"
+				spec.UUID = existing.UUID
+				return spec
+			},
+			wantErr: "a network with UUID '" + existing.UUID + "' already exists",
+		},
+		{
+			name: "invalid spec",
+			spec: func() *VirtualMachineNetworkSpec {
+				spec := GetDefaultVirtualMachineNetworkSpec()
+				spec.Name = "other-network"
+				spec.CIDR = "not-a-cidr"
+				return spec
+			},
+			wantErr: "unable to generate network configuration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := hypervisortest.UseFake(t)
+
+			if err := Create(ctx, existing); err != nil {
+				t.Fatalf("unable to create the existing network: %v", err)
+			}
+
+			spec := tt.spec()
+			err := Create(ctx, spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Create() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			netcfg, err := fake.LookupNetwork(ctx, spec.Name)
+			if err != nil {
+				t.Fatalf("the network was not created: %v", err)
+			}
+			if netcfg.Forward == nil || netcfg.Forward.Mode != "nat" {
+				t.Errorf("forward = %+v, want nat", netcfg.Forward)
+			}
+			if got := netcfg.IPs[0].Address; got != spec.GatewayIP() {
+				t.Errorf("gateway = %s, want %s", got, spec.GatewayIP())
+			}
+			if len(netcfg.IPs[0].DHCP.Hosts) != len(spec.Hosts) {
+				t.Errorf("dhcp hosts = %d, want %d", len(netcfg.IPs[0].DHCP.Hosts), len(spec.Hosts))
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"snoman/internal/vms/hypervisor/hypervisortest"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	ctx := context.Background()
+	hypervisortest.UseFake(t)
+
+	spec := GetDefaultVirtualMachineNetworkSpec()
+	spec.BootFile = "http://192.168.126.1:8080/agent.x86_64.ipxe"
+	if err := Create(ctx, spec); err != nil {
+		t.Fatalf("unable to create the network: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr error
+	}{
+		{name: "by name", id: spec.Name},
+		{name: "by uuid", id: spec.UUID},
+		{name: "missing", id: "missing-network", wantErr: ErrNetworkNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := Find(ctx, tt.id)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Find() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+
+			// The spec is rebuilt from the libvirt XML, so only what the XML carries is compared
+			if found.Name != spec.Name || found.UUID != spec.UUID || found.BridgeName != spec.BridgeName {
+				t.Errorf("Find() = %s %s %s, want %s %s %s", found.Name, found.UUID, found.BridgeName, spec.Name, spec.UUID, spec.BridgeName)
+			}
+			if found.CIDR != spec.CIDR || found.Domain != spec.Domain || found.BootFile != spec.BootFile {
+				t.Errorf("Find() = %s %s %s, want %s %s %s", found.CIDR, found.Domain, found.BootFile, spec.CIDR, spec.Domain, spec.BootFile)
+			}
+			if !reflect.DeepEqual(found.Hosts, spec.Hosts) {
+				t.Errorf("Find() hosts = %+v, want %+v", found.Hosts, spec.Hosts)
+			}
+		})
+	}
+}
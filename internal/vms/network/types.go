@@ -27,6 +27,8 @@ type VirtualMachineNetworkSpec struct {
 	Hosts                 []VMNet_DHCP_Host `yaml:"hosts,omitempty" validate:"omitempty"`
 	ClusterNetworkCIDR    string            `yaml:"cluster_cidr,omitempty" validate:"omitempty,cidr"`
 	ClusterSvcNetworkCIDR string            `yaml:"cluster_svc_cidr,omitempty" validate:"omitempty,cidr"`
+	BootFile              string            `yaml:"dhcp_boot_file,omitempty" validate:"omitempty"`
+	BootServer            string            `yaml:"dhcp_boot_server,omitempty" validate:"omitempty,ip"`
 	prefix                string
 }
 
@@ -72,6 +74,13 @@ func (spec *VirtualMachineNetworkSpec) genAdditionalFields() {
 	spec.prefix, _ = getNetworkPrefixFromCIDR(spec.CIDR)
 }
 
+// GatewayIP is the address libvirt gives the host on the network
+func (spec VirtualMachineNetworkSpec) GatewayIP() string {
+	prefix, _ := getNetworkPrefixFromCIDR(spec.CIDR)
+
+	return strings.Join([]string{prefix, "1"}, ".")
+}
+
 func (spec VirtualMachineNetworkSpec) MarshalYAML() (string, error) {
 	if err := spec.Validate(); err != nil {
 		return "", err
@@ -101,13 +110,22 @@ func (spec *VirtualMachineNetworkSpec) UnmarshalYAML(yamlData []byte) error {
 }
 
 func (spec VirtualMachineNetworkSpec) MarshalXML() (string, error) {
-	if err := spec.Validate(); err != nil {
+	netcfg, err := spec.toLibvirtxml()
+	if err != nil {
 		return "", err
 	}
 
+	return netcfg.Marshal()
+}
+
+func (spec VirtualMachineNetworkSpec) toLibvirtxml() (*libvirtxml.Network, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
 	ipprefix, err := strconv.ParseUint(strings.Split(spec.CIDR, "/")[1], 10, 64)
 	if err != nil {
-		return "", fmt.Errorf("unable to parse IP prefix from CIDR '%s': %w", spec.CIDR, err)
+		return nil, fmt.Errorf("unable to parse IP prefix from CIDR '%s': %w", spec.CIDR, err)
 	}
 
 	// Create the net config xml
@@ -164,7 +182,17 @@ func (spec VirtualMachineNetworkSpec) MarshalXML() (string, error) {
 		}
 	}
 
-	return netcfg.Marshal()
+	// Network booting clients are pointed at the boot file through DHCP
+	if spec.BootFile != "" {
+		netcfg.IPs[0].DHCP.Bootp = []libvirtxml.NetworkBootp{
+			{
+				File:   spec.BootFile,
+				Server: spec.BootServer,
+			},
+		}
+	}
+
+	return netcfg, nil
 }
 
 func (spec *VirtualMachineNetworkSpec) UnmarshalXML(xmlData []byte) error {
@@ -210,6 +238,11 @@ func (spec *VirtualMachineNetworkSpec) fromLibvirtxml(net *libvirtxml.Network) e
 		}
 	}
 
+	if len(net.IPs) > 0 && net.IPs[0].DHCP != nil && len(net.IPs[0].DHCP.Bootp) > 0 {
+		spec.BootFile = net.IPs[0].DHCP.Bootp[0].File
+		spec.BootServer = net.IPs[0].DHCP.Bootp[0].Server
+	}
+
 	spec.genAdditionalFields() // Calculate any hidden fields from the data retrieved
 
 	return nil
@@ -0,0 +1,74 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"snoman/internal/vms/hypervisor"
+	"snoman/internal/vms/hypervisor/hypervisortest"
+	"testing"
+)
+
+func TestAddHostToNetwork(t *testing.T) {
+	tests := []struct {
+		name    string
+		netid   func(spec *VirtualMachineNetworkSpec) string
+		host    VMNet_DHCP_Host
+		wantErr error
+	}{
+		{
+			name:  "by name",
+			netid: func(spec *VirtualMachineNetworkSpec) string { return spec.Name },
+			host:  VMNet_DHCP_Host{Name: "worker", MacAddress: "52:54:00:00:00:02", IpAddress: "192.168.126.11"},
+		},
+		{
+			name:  "by uuid",
+			netid: func(spec *VirtualMachineNetworkSpec) string { return spec.UUID },
+			host:  VMNet_DHCP_Host{Name: "worker", MacAddress: "52:54:00:00:00:02", IpAddress: "192.168.126.11"},
+		},
+		{
+			name:    "duplicate address",
+			netid:   func(spec *VirtualMachineNetworkSpec) string { return spec.Name },
+			host:    VMNet_DHCP_Host{Name: "worker", MacAddress: "52:54:00:00:00:02", IpAddress: DEFAULT_HOST_IP},
+			wantErr: hypervisor.ErrAlreadyExists,
+		},
+		{
+			name:    "missing network",
+			netid:   func(spec *VirtualMachineNetworkSpec) string { return "missing-network" },
+			host:    VMNet_DHCP_Host{Name: "worker", MacAddress: "52:54:00:00:00:02", IpAddress: "192.168.126.11"},
+			wantErr: hypervisor.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			hypervisortest.UseFake(t)
+
+			spec := GetDefaultVirtualMachineNetworkSpec()
+			if err := Create(ctx, spec); err != nil {
+				t.Fatalf("unable to create the network: %v", err)
+			}
+
+			err := AddHostToNetwork(ctx, tt.netid(spec), &tt.host)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("AddHostToNetwork() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddHostToNetwork() error = %v", err)
+			}
+
+			found, err := Find(ctx, spec.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := append(spec.Hosts, tt.host)
+			if len(found.Hosts) != len(want) || found.Hosts[len(want)-1] != tt.host {
+				t.Errorf("hosts = %+v, want %+v", found.Hosts, want)
+			}
+		})
+	}
+}
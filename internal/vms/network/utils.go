@@ -4,10 +4,10 @@ import (
 	"crypto/rand"
 	"fmt"
 	"net"
+	"snoman/internal/logger"
+	"snoman/internal/vms/hypervisor"
 	"snoman/internal/vms/utils"
 	"strings"
-
-	"libvirt.org/go/libvirt"
 )
 
 const (
@@ -38,13 +38,13 @@ func getNetworkPrefixFromCIDR(cidr string) (string, error) {
 	return strings.Join(strings.Split(cidr, ".")[0:3], "."), nil
 }
 
-// findNetworkByNameOrUUID will try to find the network and return nil if the network could not be found
-func findNetworkByNameOrUUID(id string, lvc *libvirt.Connect) (net *libvirt.Network) {
-	net, _ = lvc.LookupNetworkByName(id)
-
-	if net == nil {
-		net, _ = lvc.LookupNetworkByUUIDString(id)
+// ServiceAddress is the address a service for the VMs on the network is bound to, ex: the built-in proxy. That is
+// the network gateway, except on simulated hypervisors whose networks never reach the host, where it is localhost
+func ServiceAddress(spec *VirtualMachineNetworkSpec) string {
+	if hypervisor.IsSimulated() {
+		logger.Get().Debugf("serving on localhost instead of the gateway of network '%s' for simulated hypervisor %s", spec.Name, utils.GetLibvirtURI())
+		return "127.0.0.1"
 	}
 
-	return
+	return spec.GatewayIP()
 }
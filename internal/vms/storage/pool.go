@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"snoman/internal/logger"
+	"snoman/internal/vms/hypervisor"
+)
+
+var ErrPoolNotFound = fmt.Errorf("the specified storage pool could not be found")
+
+// poolError will translate a not found error into ErrPoolNotFound when it is the pool that is missing
+// rather than a volume in it
+func poolError(ctx context.Context, err error, name string) error {
+	if !errors.Is(err, hypervisor.ErrNotFound) {
+		return err
+	}
+
+	if _, lookupErr := hypervisor.Get().LookupPool(ctx, name); errors.Is(lookupErr, hypervisor.ErrNotFound) {
+		return fmt.Errorf("%w: '%s'", ErrPoolNotFound, name)
+	}
+
+	return err
+}
+
+// CreatePool will define, build and start a directory storage pool
+func CreatePool(ctx context.Context, spec *StoragePoolSpec) error {
+	log := logger.Get()
+	hv := hypervisor.Get()
+
+	if _, err := hv.LookupPool(ctx, spec.Name); err == nil {
+		return fmt.Errorf("a storage pool with name '%s' already exists", spec.Name)
+	}
+
+	poolcfg, err := spec.toLibvirtxml()
+	if err != nil {
+		return fmt.Errorf("unable to generate storage pool configuration: %w", err)
+	}
+
+	if err := hv.CreatePool(ctx, poolcfg, spec.Autostart); err != nil {
+		return err
+	}
+
+	log.Infow("successfully created storage pool", "name", spec.Name, "path", spec.Path)
+
+	return nil
+}
+
+// DestroyPool will stop and undefine the pool. If deleteData is set, the volumes and directory are removed too
+func DestroyPool(ctx context.Context, name string, deleteData bool) error {
+	log := logger.Get()
+
+	if err := hypervisor.Get().DestroyPool(ctx, name, deleteData); err != nil {
+		return poolError(ctx, err, name)
+	}
+
+	log.Infow("successfully destroyed storage pool", "name", name)
+
+	return nil
+}
+
+// ListPools will return the state of every storage pool on the hypervisor
+func ListPools(ctx context.Context) ([]PoolInfo, error) {
+	pools, err := hypervisor.Get().ListPools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]PoolInfo, 0, len(pools))
+	for i := range pools {
+		infos = append(infos, toPoolInfo(&pools[i]))
+	}
+
+	return infos, nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"snoman/internal/vms/hypervisor"
+	vmutils "snoman/internal/vms/utils"
+
+	"gopkg.in/yaml.v2"
+	"libvirt.org/go/libvirtxml"
+)
+
+type StoragePoolSpec struct {
+	Name      string `yaml:"name" validate:"required"`
+	Path      string `yaml:"path" validate:"required"`
+	Autostart bool   `yaml:"autostart,omitempty" validate:"omitempty"`
+}
+
+// PoolInfo is the current state of a storage pool
+type PoolInfo struct {
+	Name        string
+	Active      bool
+	Autostart   bool
+	Path        string
+	CapacityGB  uint64
+	AvailableGB uint64
+}
+
+// VolumeInfo is the current state of a storage volume
+type VolumeInfo struct {
+	Name         string
+	Pool         string
+	Path         string
+	Format       string
+	CapacityGB   uint64
+	AllocationGB uint64
+}
+
+const (
+	DEFAULT_POOL_ROOT      = "/var/lib/libvirt/images"
+	POOL_WORKDIR_SUBFOLDER = "pools"
+	VOLUME_FORMAT_QCOW2    = "qcow2"
+	VOLUME_FORMAT_RAW      = "raw"
+	bytesPerGB             = 1024 * 1024 * 1024
+)
+
+// GetDefaultStoragePoolSpec returns a directory pool spec. If workdir is set the pool is rooted inside it
+func GetDefaultStoragePoolSpec(name string, workdir string) *StoragePoolSpec {
+	path := filepath.Join(DEFAULT_POOL_ROOT, name)
+	if workdir != "" {
+		path = filepath.Join(workdir, POOL_WORKDIR_SUBFOLDER, name)
+	}
+
+	return &StoragePoolSpec{
+		Name:      name,
+		Path:      path,
+		Autostart: true,
+	}
+}
+
+func (spec StoragePoolSpec) Validate() error {
+	err := vmutils.SpecValidator.Struct(spec)
+	if err != nil {
+		return fmt.Errorf("unable to validate StoragePoolSpec: %w", err)
+	}
+
+	return nil
+}
+
+func (spec StoragePoolSpec) MarshalYAML() (string, error) {
+	if err := spec.Validate(); err != nil {
+		return "", err
+	}
+
+	data, err := yaml.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (spec *StoragePoolSpec) UnmarshalYAML(yamlData []byte) error {
+	err := yaml.Unmarshal(yamlData, spec)
+	if err != nil {
+		return fmt.Errorf("unable to parse the spec: %w", err)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (spec StoragePoolSpec) MarshalXML() (string, error) {
+	poolcfg, err := spec.toLibvirtxml()
+	if err != nil {
+		return "", err
+	}
+
+	return poolcfg.Marshal()
+}
+
+func (spec StoragePoolSpec) toLibvirtxml() (*libvirtxml.StoragePool, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	poolcfg := &libvirtxml.StoragePool{
+		Type: "dir",
+		Name: spec.Name,
+		Target: &libvirtxml.StoragePoolTarget{
+			Path: spec.Path,
+		},
+	}
+
+	return poolcfg, nil
+}
+
+func volumeConfig(name string, capacity uint64, format string) *libvirtxml.StorageVolume {
+	return &libvirtxml.StorageVolume{
+		Name: name,
+		Capacity: &libvirtxml.StorageVolumeSize{
+			Unit:  "bytes",
+			Value: capacity,
+		},
+		Target: &libvirtxml.StorageVolumeTarget{
+			Format: &libvirtxml.StorageVolumeTargetFormat{Type: format},
+		},
+	}
+}
+
+func toPoolInfo(info *hypervisor.PoolInfo) PoolInfo {
+	return PoolInfo{
+		Name:        info.Name,
+		Active:      info.Active,
+		Autostart:   info.Autostart,
+		Path:        info.Path,
+		CapacityGB:  info.Capacity / bytesPerGB,
+		AvailableGB: info.Available / bytesPerGB,
+	}
+}
+
+func toVolumeInfo(info *hypervisor.VolumeInfo) VolumeInfo {
+	return VolumeInfo{
+		Name:         info.Name,
+		Pool:         info.Pool,
+		Path:         info.Path,
+		Format:       info.Format,
+		CapacityGB:   info.Capacity / bytesPerGB,
+		AllocationGB: info.Allocation / bytesPerGB,
+	}
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"snoman/internal/logger"
+	"snoman/internal/vms/hypervisor"
+)
+
+// CreateVolume will allocate a new volume in the pool and return its path
+func CreateVolume(ctx context.Context, poolName string, name string, sizeGB uint64, format string) (string, error) {
+	log := logger.Get()
+
+	vol, err := hypervisor.Get().CreateVolume(ctx, poolName, volumeConfig(name, sizeGB*bytesPerGB, format))
+	if err != nil {
+		return "", poolError(ctx, err, poolName)
+	}
+
+	log.Infow("successfully created volume", "pool", poolName, "name", name, "size_gb", sizeGB)
+
+	return vol.Path, nil
+}
+
+// DeleteVolume will remove the volume from the pool
+func DeleteVolume(ctx context.Context, poolName string, name string) error {
+	log := logger.Get()
+
+	if err := hypervisor.Get().DeleteVolume(ctx, poolName, name); err != nil {
+		return poolError(ctx, err, poolName)
+	}
+
+	log.Infow("successfully deleted volume", "pool", poolName, "name", name)
+
+	return nil
+}
+
+// CloneVolume will create a copy of an existing volume in the same pool and return the new volume path
+func CloneVolume(ctx context.Context, poolName string, source string, name string) (string, error) {
+	log := logger.Get()
+	hv := hypervisor.Get()
+
+	srcvol, err := hv.LookupVolume(ctx, poolName, source)
+	if err != nil {
+		return "", poolError(ctx, err, poolName)
+	}
+
+	format := srcvol.Format
+	if format == "" {
+		format = VOLUME_FORMAT_RAW
+	}
+
+	vol, err := hv.CloneVolume(ctx, poolName, source, volumeConfig(name, srcvol.Capacity, format))
+	if err != nil {
+		return "", err
+	}
+
+	log.Infow("successfully cloned volume", "pool", poolName, "source", source, "name", name)
+
+	return vol.Path, nil
+}
+
+// UploadVolume will create a raw volume in the pool with the contents of a local file (like an ISO)
+// and return the path of the volume on the hypervisor
+func UploadVolume(ctx context.Context, poolName string, name string, localPath string) (string, error) {
+	log := logger.Get()
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to open '%s': %w", localPath, err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("unable to stat '%s': %w", localPath, err)
+	}
+
+	log.Infof("uploading %s to volume '%s' in pool '%s'", localPath, name, poolName)
+	vol, err := hypervisor.Get().UploadVolume(ctx, poolName, volumeConfig(name, uint64(stat.Size()), VOLUME_FORMAT_RAW), file)
+	if err != nil {
+		return "", fmt.Errorf("unable to upload '%s': %w", localPath, poolError(ctx, err, poolName))
+	}
+
+	log.Infow("successfully uploaded volume", "pool", poolName, "name", name, "path", vol.Path)
+
+	return vol.Path, nil
+}
+
+// ListVolumes will return the state of every volume in the pool
+func ListVolumes(ctx context.Context, poolName string) ([]VolumeInfo, error) {
+	vols, err := hypervisor.Get().ListVolumes(ctx, poolName)
+	if err != nil {
+		return nil, poolError(ctx, err, poolName)
+	}
+
+	infos := make([]VolumeInfo, 0, len(vols))
+	for i := range vols {
+		infos = append(infos, toVolumeInfo(&vols[i]))
+	}
+
+	return infos, nil
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"snoman/internal/logger"
+	"sync"
+	"time"
+
+	"libvirt.org/go/libvirt"
+)
+
+const (
+	KEEPALIVE_INTERVAL_SECONDS = 5
+	KEEPALIVE_MAX_MISSED       = 3
+	CONNECT_ATTEMPTS           = 3
+	CONNECT_RETRY_DELAY        = 2 * time.Second
+)
+
+var eventLoopOnce sync.Once
+
+// Client holds a single libvirt connection that is shared by every operation of a command or daemon.
+// The connection is opened on first use, kept alive, and reopened if libvirt drops it
+type Client struct {
+	uri  string
+	mux  sync.Mutex
+	conn *libvirt.Connect
+}
+
+func NewClient(uri string) *Client {
+	return &Client{uri: uri}
+}
+
+func (c *Client) URI() string {
+	return c.uri
+}
+
+// Connect will return the live connection, opening or reopening it as needed.
+// The returned connection is owned by the client and must not be closed by the caller
+func (c *Client) Connect(ctx context.Context) (*libvirt.Connect, error) {
+	log := logger.Get()
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.conn != nil {
+		if alive, err := c.conn.IsAlive(); err == nil && alive {
+			return c.conn, nil
+		}
+
+		log.Warnf("libvirt connection to %s was lost, reconnecting", c.uri)
+		c.conn.Close()
+		c.conn = nil
+	}
+
+	// Keepalive messages are only processed while an event loop is running
+	startEventLoop()
+
+	var lastErr error
+	for attempt := 1; attempt <= CONNECT_ATTEMPTS; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		conn, err := libvirt.NewConnect(c.uri)
+		if err == nil {
+			if err := conn.SetKeepAlive(KEEPALIVE_INTERVAL_SECONDS, KEEPALIVE_MAX_MISSED); err != nil {
+				log.Debugf("unable to enable libvirt keepalive: %v", err)
+			}
+
+			log.Debugf("connected to libvirt at %s", c.uri)
+			c.conn = conn
+
+			return conn, nil
+		}
+
+		lastErr = err
+		log.Debugf("libvirt connection attempt %d to %s failed: %v", attempt, c.uri, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(CONNECT_RETRY_DELAY):
+		}
+	}
+
+	return nil, fmt.Errorf("unable to connect to libvirt at %s: %w", c.uri, lastErr)
+}
+
+func (c *Client) Close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+
+	_, err := c.conn.Close()
+	c.conn = nil
+
+	return err
+}
+
+func startEventLoop() {
+	eventLoopOnce.Do(func() {
+		if err := libvirt.EventRegisterDefaultImpl(); err != nil {
+			logger.Get().Warnf("unable to register the libvirt event loop, keepalive is disabled: %v", err)
+			return
+		}
+
+		go func() {
+			for {
+				if err := libvirt.EventRunDefaultImpl(); err != nil {
+					logger.Get().Debugf("libvirt event loop iteration failed: %v", err)
+					time.Sleep(time.Second)
+				}
+			}
+		}()
+	})
+}
@@ -1,14 +1,70 @@
 package utils
 
 import (
+	"net/url"
+	"os"
 	"snoman/internal/logger"
+	"sync"
 
 	"gopkg.in/yaml.v2"
-	"libvirt.org/go/libvirt"
 )
 
-func GetLibvirtConnection() (*libvirt.Connect, error) {
-	return libvirt.NewConnect("qemu:///system") // TODO: make sure this is the right connection string (make configurable?)
+const (
+	DEFAULT_LIBVIRT_URI = "qemu:///system"
+	LIBVIRT_URI_ENV     = "LIBVIRT_DEFAULT_URI"
+)
+
+var (
+	uriOverride string // Set from the command line, wins over everything else
+	uriSpec     string // Set from a spec file
+	urimux      sync.RWMutex
+)
+
+// SetLibvirtURI will set the connection URI used for every libvirt call. This takes precedence over specs and the env
+func SetLibvirtURI(uri string) {
+	urimux.Lock()
+	defer urimux.Unlock()
+
+	uriOverride = uri
+}
+
+// SetSpecLibvirtURI will set the connection URI requested by a spec. It is ignored if SetLibvirtURI was used
+func SetSpecLibvirtURI(uri string) {
+	urimux.Lock()
+	defer urimux.Unlock()
+
+	uriSpec = uri
+}
+
+// GetLibvirtURI will return the connection URI from the command line, spec, LIBVIRT_DEFAULT_URI or the default in that order
+func GetLibvirtURI() string {
+	urimux.RLock()
+	defer urimux.RUnlock()
+
+	if uriOverride != "" {
+		return uriOverride
+	}
+
+	if uriSpec != "" {
+		return uriSpec
+	}
+
+	if env := os.Getenv(LIBVIRT_URI_ENV); env != "" {
+		return env
+	}
+
+	return DEFAULT_LIBVIRT_URI
+}
+
+// IsRemoteLibvirt will return true if the hypervisor is on another host (ex: qemu+ssh://user@host/system)
+// and local files can not be referenced by path
+func IsRemoteLibvirt() bool {
+	u, err := url.Parse(GetLibvirtURI())
+	if err != nil {
+		return false
+	}
+
+	return u.Host != ""
 }
 
 func LogYaml(data interface{}) {
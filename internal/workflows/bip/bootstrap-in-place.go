@@ -1,20 +1,32 @@
 package bip
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"snoman/internal/arch"
 	"snoman/internal/biputils"
 	"snoman/internal/biputils/agentconfig"
 	"snoman/internal/biputils/installconfig"
+	"snoman/internal/biputils/secrets"
+	"snoman/internal/chaos"
 	"snoman/internal/logger"
+	"snoman/internal/proxy"
+	"snoman/internal/pxe"
+	"snoman/internal/registry"
+	"snoman/internal/targets"
 	"snoman/internal/vms/machines"
+	"snoman/internal/vms/network"
+	vmutils "snoman/internal/vms/utils"
+	"time"
 
 	"go.uber.org/zap"
 )
 
-func Run(spec *BootstrapInPlaceSpec) error {
+func Run(ctx context.Context, spec *BootstrapInPlaceSpec) error {
 	log := logger.Get()
+	started := time.Now()
 
 	// Make sure the workdir exists and create the directory for openshift-install to operate in
 	log.Info("validating the working folder exists")
@@ -29,45 +41,360 @@ func Run(spec *BootstrapInPlaceSpec) error {
 		}
 	}
 
-	// Generate the ISO if needed
+	// Inject the scheduled faults for as long as the workflow runs
+	var scheduler *chaos.Scheduler
+	if spec.Chaos != nil {
+		if spec.Chaos.VM == "" {
+			spec.Chaos.VM = spec.MachineConfig.Name
+		}
+
+		scheduler = chaos.NewScheduler(spec.Chaos)
+		if err := scheduler.Start(ctx); err != nil {
+			return fmt.Errorf("unable to start the chaos schedule: %w", err)
+		}
+		defer scheduler.Stop()
+	}
+
+	// The VM writes its stats next to the rest of the workflow logs
+	if spec.MachineConfig.Workdir == "" {
+		spec.MachineConfig.Workdir = spec.Workdir
+	}
+
+	// A VM we create has to be of the architecture of the release it installs, and the host has to be able to
+	// run it. That is checked before the installer spends minutes generating
+	if spec.Target == nil {
+		if err := matchMachineArch(spec); err != nil {
+			return err
+		}
+
+		if err := preflightMachine(ctx, spec); err != nil {
+			return err
+		}
+	}
+
+	// The cluster is pointed at the built-in proxy before the configs are generated
+	if spec.ServeProxy != nil {
+		if err := configureServedProxy(spec); err != nil {
+			return fmt.Errorf("unable to configure the built-in proxy: %w", err)
+		}
+	}
+
+	// The release image is pinned so the run record says exactly what was installed
 	if spec.IsoSpec.IsoPath == "" {
-		log.Info("generating installer ISO image")
-		if err := generateISO(spec, log); err != nil {
-			return fmt.Errorf("unable to generate installer ISO: %w", err)
+		if err := pinReleaseImage(ctx, spec, log); err != nil {
+			return fmt.Errorf("unable to pin the release image: %w", err)
+		}
+	}
+
+	var bootPath string
+	if spec.Pxe != nil {
+		// Only a VM we create can be pointed at the artifacts through its network
+		if spec.Target != nil {
+			return fmt.Errorf("pxe boot is only supported when installing onto a virtual machine")
 		}
+
+		log.Info("generating installer pxe artifacts")
+		artifacts, err := generatePxeArtifacts(ctx, spec, log)
+		if err != nil {
+			return fmt.Errorf("unable to generate installer pxe artifacts: %w", err)
+		}
+
+		bootPath = artifacts.Dir
+		spec.Target = targets.NewVirtualMachinePxeProvider(spec.MachineConfig, artifacts.IpxeScript, spec.Pxe.HttpPort, spec.Pxe.TftpPort)
+	} else {
+		// Generate the ISO if needed
+		if spec.IsoSpec.IsoPath == "" {
+			log.Info("generating installer ISO image")
+			if err := generateISO(ctx, spec, log); err != nil {
+				return fmt.Errorf("unable to generate installer ISO: %w", err)
+			}
+		}
+
+		// Validate the ISO file exists
+		if _, err := os.Stat(spec.IsoSpec.IsoPath); err != nil {
+			return fmt.Errorf("could not find the installer iso: %w", err)
+		}
+
+		bootPath = spec.IsoSpec.IsoPath
+	}
+
+	if err := writeRunRecord(spec, started); err != nil {
+		log.Warnf("unable to write the run record: %v", err)
+	}
+
+	if scheduler != nil {
+		scheduler.Phase(PHASE_INSTALLER_GENERATED)
 	}
 
-	// Validate the ISO file exists
-	if _, err := os.Stat(spec.IsoSpec.IsoPath); err != nil {
-		return fmt.Errorf("could not find the installer iso: %w", err)
+	// Boot the target from the installer
+	if spec.Target == nil {
+		spec.Target = targets.NewVirtualMachineProvider(spec.MachineConfig)
 	}
+	defer spec.Target.Close()
 
 	// Create the dnsmasq config
-	dnsmasqAddr := fmt.Sprintf("/api.%s.%s/%s", spec.MachineConfig.Name, spec.MachineConfig.Network.Domain, spec.MachineConfig.Network.Hosts[0].IpAddress)
-	if err := biputils.CreateDnsmasqConfig(dnsmasqAddr); err != nil {
+	dnsmasqAddr, err := dnsmasqAddress(spec)
+	if err != nil {
+		return err
+	}
+
+	if err := biputils.CreateDnsmasqConfig(ctx, spec.DnsmasqConfigPath, dnsmasqAddr); err != nil {
 		return fmt.Errorf("could not create dnsmasq config: %w", err)
 	}
 
-	// Create the virtual machine and network
-	if err := machines.CreateVirtualMachine(spec.MachineConfig); err != nil {
-		return fmt.Errorf("could not create the virtual machine: %w", err)
+	if spec.ServeProxy != nil {
+		accessLog, err := addProxyServer(spec)
+		if err != nil {
+			return fmt.Errorf("unable to start the built-in proxy: %w", err)
+		}
+		defer accessLog.Close()
+	}
+
+	if scheduler != nil {
+		scheduler.Phase(PHASE_BOOTING)
+	}
+
+	log.Infof("booting %s from the installer", spec.Target.Name())
+	if err := spec.Target.Boot(ctx, bootPath); err != nil {
+		return fmt.Errorf("could not boot the target: %w", err)
+	}
+
+	if err := spec.Target.Wait(ctx); err != nil {
+		return fmt.Errorf("failed waiting on the target: %w", err)
+	}
+
+	return nil
+}
+
+// preflightMachine will check the host can create the VM, and has the installer when it is needed
+func preflightMachine(ctx context.Context, spec *BootstrapInPlaceSpec) error {
+	report, err := machines.Preflight(ctx, spec.MachineConfig)
+	if err != nil {
+		return fmt.Errorf("unable to run pre-flight checks: %w", err)
+	}
+
+	machines.PreflightInstaller(report, spec.IsoSpec)
+
+	report.Log()
+
+	return report.Err()
+}
+
+// dnsmasqAddress resolves the cluster API to the address of the target, ex: /api.sno.example.com/192.168.1.10
+func dnsmasqAddress(spec *BootstrapInPlaceSpec) (string, error) {
+	if spec.MachineConfig.Network == nil || spec.MachineConfig.Network.Domain == "" {
+		return "", fmt.Errorf("a network domain is required to resolve the cluster api")
+	}
+
+	var address string
+	if target, ok := spec.Target.(targets.HostAddressProvider); ok {
+		address = target.HostAddress()
+	}
+	if address == "" {
+		return "", fmt.Errorf("the address of %s is not known, it is required to resolve the cluster api", spec.Target.Name())
+	}
+
+	return fmt.Sprintf("/api.%s.%s/%s", spec.MachineConfig.Name, spec.MachineConfig.Network.Domain, address), nil
+}
+
+func generateISO(ctx context.Context, spec *BootstrapInPlaceSpec, log *zap.SugaredLogger) error {
+	installerWorkdir, err := generateConfigs(spec, "", log)
+	if err != nil {
+		return err
 	}
 
+	// Generate the ISO image
+	log.Info("generating install iso")
+	if err := biputils.GenerateIso(ctx, spec.IsoSpec, installerWorkdir); err != nil {
+		logInstallerLog(installerWorkdir, log)
+		return fmt.Errorf("unable to generate iso image: %w", err)
+	}
+
+	spec.IsoSpec.IsoPath = biputils.GetIsoPath(installerWorkdir, spec.IsoSpec.OpenshiftArch)
+
 	return nil
 }
 
-func generateISO(spec *BootstrapInPlaceSpec, log *zap.SugaredLogger) error {
+func generatePxeArtifacts(ctx context.Context, spec *BootstrapInPlaceSpec, log *zap.SugaredLogger) (*biputils.PxeArtifacts, error) {
+	// The iPXE script fetches the kernel, initrd and rootfs from the server on the VM network gateway
+	baseURL := pxe.HttpBaseURL(spec.MachineConfig.Network.GatewayIP(), spec.Pxe.HttpPort)
+
+	installerWorkdir, err := generateConfigs(spec, baseURL, log)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("generating install pxe artifacts")
+	artifacts, err := biputils.GeneratePxeArtifacts(ctx, spec.IsoSpec, installerWorkdir)
+	if err != nil {
+		logInstallerLog(installerWorkdir, log)
+		return nil, fmt.Errorf("unable to generate pxe artifacts: %w", err)
+	}
+
+	return artifacts, nil
+}
+
+// pinReleaseImage will replace the release image tag with the digest it points at. Mirrors are only used
+// for images pulled by digest, so a mirrored release has to be pinned, any other is installed by tag if the
+// registry can not be reached
+func pinReleaseImage(ctx context.Context, spec *BootstrapInPlaceSpec, log *zap.SugaredLogger) error {
+	if err := spec.IsoSpec.ResolveRelease(); err != nil {
+		return err
+	}
+
+	ref, err := registry.ParseReference(spec.IsoSpec.ReleaseImage)
+	if err != nil {
+		return err
+	}
+
+	if ref.Digest != "" {
+		return nil
+	}
+
+	mirrored := spec.Mirror != nil && spec.Mirror.IsMirrored(ref.Name())
+	pullSecret, trustBundle := spec.PullSecret, ""
+	if spec.Mirror != nil {
+		trustBundle = spec.Mirror.AdditionalTrustBundle
+		if spec.Mirror.PullSecret != "" {
+			pullSecret, err = secrets.MergePullSecrets(spec.PullSecret, spec.Mirror.PullSecret)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	client, err := registry.NewClient(pullSecret, trustBundle)
+	if err != nil {
+		return err
+	}
+
+	digest, err := client.ResolveDigest(ctx, ref)
+	if err != nil {
+		if mirrored {
+			return err
+		}
+
+		log.Warnf("unable to pin release image %s by digest, it will be installed by tag: %v", ref, err)
+		return nil
+	}
+
+	if err := spec.IsoSpec.ResolvedRelease.Pin(digest); err != nil {
+		return err
+	}
+	spec.IsoSpec.ReleaseImage = spec.IsoSpec.ResolvedRelease.Image
+	log.Infof("using release image %s for %s", spec.IsoSpec.ReleaseImage, ref)
+
+	return nil
+}
+
+// matchMachineArch will create the VM as the release architecture, which is emulated when the hypervisor
+// is of another architecture
+func matchMachineArch(spec *BootstrapInPlaceSpec) error {
+	releaseArch, err := arch.Normalize(spec.IsoSpec.OpenshiftArch)
+	if err != nil {
+		return fmt.Errorf("unable to validate the release architecture: %w", err)
+	}
+
+	if releaseArch == "" {
+		return nil
+	}
+	spec.IsoSpec.OpenshiftArch = releaseArch
+
+	machineArch, err := arch.Normalize(spec.MachineConfig.Arch)
+	if err != nil {
+		return fmt.Errorf("unable to validate the virtual machine architecture: %w", err)
+	}
+
+	if machineArch == "" {
+		spec.MachineConfig.Arch = releaseArch
+	} else if machineArch != releaseArch {
+		return fmt.Errorf("the virtual machine architecture %s does not match the release architecture %s", machineArch, releaseArch)
+	}
+
+	return nil
+}
+
+// configureServedProxy will point the cluster proxy at the address the built-in proxy is served on
+func configureServedProxy(spec *BootstrapInPlaceSpec) error {
+	// Only a VM we create has a network gateway on this host
+	if spec.Target != nil {
+		return fmt.Errorf("the built-in proxy is only supported when installing onto a virtual machine")
+	}
+
+	if spec.MachineConfig.Network == nil {
+		return fmt.Errorf("a network is required to serve the proxy to the virtual machine")
+	}
+
+	if vmutils.IsRemoteLibvirt() {
+		return fmt.Errorf("the built-in proxy is not supported on remote hypervisor %s", vmutils.GetLibvirtURI())
+	}
+
+	if spec.Proxy == nil {
+		spec.Proxy = &installconfig.ProxySpec{}
+	} else if spec.Proxy.HTTPProxy != "" || spec.Proxy.HTTPSProxy != "" {
+		return fmt.Errorf("an external proxy can not be used with the built-in proxy")
+	}
+
+	port := spec.ServeProxy.Port
+	if port == 0 {
+		port = proxy.DEFAULT_PORT
+	}
+
+	url := proxy.URL(spec.MachineConfig.Network.GatewayIP(), port)
+	spec.Proxy.HTTPProxy = url
+	spec.Proxy.HTTPSProxy = url
+
+	return nil
+}
+
+// addProxyServer will have the target serve the built-in proxy on the VM network gateway, the returned
+// access log must be closed once the target is
+func addProxyServer(spec *BootstrapInPlaceSpec) (*os.File, error) {
+	services, ok := spec.Target.(targets.NetworkServiceHost)
+	if !ok {
+		return nil, fmt.Errorf("%s can not serve the proxy on its network", spec.Target.Name())
+	}
+
+	host := network.ServiceAddress(spec.MachineConfig.Network)
+
+	accessLog, err := logger.OpenLogFile(spec.Workdir, proxy.DEFAULT_ACCESS_LOG_FILE)
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := proxy.NewServer(host, spec.ServeProxy, accessLog)
+	if err != nil {
+		accessLog.Close()
+		return nil, err
+	}
+	services.AddNetworkService(server)
+
+	return accessLog, nil
+}
+
+func logInstallerLog(installerWorkdir string, log *zap.SugaredLogger) {
+	installerLog := filepath.Join(installerWorkdir, ".openshift_install.log")
+	if _, err := os.Stat(installerLog); err == nil {
+		log.Debugf("more failure information available in %s", installerLog)
+	}
+}
+
+// generateConfigs will write the install-config.yaml and agent-config.yaml and return the openshift-install working folder
+func generateConfigs(spec *BootstrapInPlaceSpec, bootArtifactsBaseURL string, log *zap.SugaredLogger) (string, error) {
 	installerWorkdir := filepath.Join(spec.Workdir, "clusterconfig")
 
+	// The installer folder is cleared on every run, so its transcript goes next to the rest of the workflow logs
+	spec.IsoSpec.LogWorkdir = spec.Workdir
+
 	// Remove any previous installer config folder and create a new one
 	log.Info("clearing any previous openshift-installer configurations")
 	if err := os.RemoveAll(installerWorkdir); err != nil {
-		return fmt.Errorf("unable to clear previous bootstrap configs: %w", err)
+		return "", fmt.Errorf("unable to clear previous bootstrap configs: %w", err)
 	}
 
 	log.Infof("creating openshift-install working folder at '%s'", installerWorkdir)
 	if err := os.MkdirAll(installerWorkdir, 0755); err != nil {
-		return fmt.Errorf("unable to create installer working folder: %w", err)
+		return "", fmt.Errorf("unable to create installer working folder: %w", err)
 	}
 
 	// Generate the install-config.yaml
@@ -81,10 +408,19 @@ func generateISO(spec *BootstrapInPlaceSpec, log *zap.SugaredLogger) error {
 		InstallDisk:       spec.MachineConfig.Disk.InstallDisk,
 		PullSecret:        spec.PullSecret,
 		SshPubKey:         spec.PublicKey,
+		Architecture:      spec.IsoSpec.OpenshiftArch,
+		Mirror:            spec.Mirror,
+		Proxy:             spec.Proxy,
+	}
+
+	// openshift-install uses the same proxy as the cluster, except the built-in one which only runs once the
+	// VM network exists
+	if spec.Proxy != nil && spec.ServeProxy == nil {
+		spec.IsoSpec.Proxy = icspec.ClusterProxy()
 	}
 
 	if err := installconfig.CreateBootstrapInstallConfigFile(icspec, spec.Workdir); err != nil {
-		return fmt.Errorf("unable to generate bootstrap install config file: %w", err)
+		return "", fmt.Errorf("unable to generate bootstrap install config file: %w", err)
 	}
 
 	spec.IsoSpec.InstallConfigPath = filepath.Join(spec.Workdir, "install-config.yaml")
@@ -94,38 +430,29 @@ func generateISO(spec *BootstrapInPlaceSpec, log *zap.SugaredLogger) error {
 
 	// We only support 1 host in this workflow
 	if len(spec.MachineConfig.Network.Hosts) == 0 {
-		return fmt.Errorf("no VM network host configuration provided")
+		return "", fmt.Errorf("no VM network host configuration provided")
 	} else if len(spec.MachineConfig.Network.Hosts) > 1 {
 		log.Warn("more than one VM network host config provided. Only the first configuration will be used")
 	}
 
 	acspec := &agentconfig.BootstrapInPlaceAgentConfigSpec{
-		VmName:    spec.MachineConfig.Name,
-		HostIP:    spec.MachineConfig.Network.Hosts[0].IpAddress,
-		HostMAC:   spec.MachineConfig.Network.Hosts[0].MacAddress,
-		HostRoute: spec.MachineConfig.Network.Hosts[0].IpAddress,
+		VmName:               spec.MachineConfig.Name,
+		HostIP:               spec.MachineConfig.Network.Hosts[0].IpAddress,
+		HostMAC:              spec.MachineConfig.Network.Hosts[0].MacAddress,
+		HostRoute:            spec.MachineConfig.Network.Hosts[0].IpAddress,
+		BootArtifactsBaseURL: bootArtifactsBaseURL,
 	}
+	spec.MachineConfig.ConfigureAgentInterfaces(acspec)
 
-	if err := agentconfig.CreateBootstrapAgentConfigFile(acspec, spec.Workdir); err != nil {
-		return fmt.Errorf("unable to generate bootstrap agent config file: %w", err)
+	if installDisk := spec.MachineConfig.Disk.InstallDisk; installDisk != "" {
+		acspec.RootDeviceHints = &agentconfig.RootDeviceHints{DeviceName: installDisk}
 	}
 
-	spec.IsoSpec.AgentConfigPath = filepath.Join(spec.Workdir, "agent-config.yaml")
-
-	// Generate the ISO image
-	log.Info("generating install iso")
-	if err := biputils.GenerateIso(spec.IsoSpec, installerWorkdir); err != nil {
-		installerLog := filepath.Join(installerWorkdir, ".openshift_install.log")
-		if _, err := os.Stat(installerLog); err == nil {
-			log.Debugf("more failure information available in %s", installerLog)
-		}
-
-		return fmt.Errorf("unable to generate iso image: %w", err)
+	if err := agentconfig.CreateBootstrapAgentConfigFile(acspec, spec.Workdir); err != nil {
+		return "", fmt.Errorf("unable to generate bootstrap agent config file: %w", err)
 	}
 
-	isoFileName := fmt.Sprintf("agent.%s.iso", spec.IsoSpec.OpenshiftArch)
-	spec.IsoSpec.IsoPath = filepath.Join(installerWorkdir, isoFileName)
-
-	return nil
+	spec.IsoSpec.AgentConfigPath = filepath.Join(spec.Workdir, "agent-config.yaml")
 
+	return installerWorkdir, nil
 }
@@ -0,0 +1,160 @@
+package bip
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"snoman/internal/biputils"
+	"snoman/internal/logger"
+	"snoman/internal/runner"
+	"snoman/internal/vms/hypervisor/hypervisortest"
+	"snoman/internal/vms/machines"
+	"snoman/internal/vms/network"
+	"strings"
+	"testing"
+)
+
+// reloadNetworkManager is the command the dnsmasq config is applied with
+var reloadNetworkManager = runner.Invocation{Name: "systemctl", Args: []string{"reload", "NetworkManager.service"}}
+
+// useReplayer will answer the commands of the workflow from the invocations for the rest of the test
+func useReplayer(t *testing.T, invocations ...runner.Invocation) *runner.Replayer {
+	t.Helper()
+
+	replayer := runner.NewReplayer(&runner.Recording{Invocations: invocations})
+	runner.Set(replayer)
+	t.Cleanup(func() { runner.Set(nil) })
+
+	return replayer
+}
+
+// newTestSpec installs the default VM from an existing ISO, with everything written under a temporary folder
+func newTestSpec(t *testing.T) *BootstrapInPlaceSpec {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	return &BootstrapInPlaceSpec{
+		MachineConfig:     machines.GetDefaultVirtualMachineSpec(),
+		Workdir:           filepath.Join(dir, "workdir"),
+		IsoSpec:           &biputils.BootstrapInPlaceIsoSpec{IsoPath: hypervisortest.WriteIso(t, "agent.x86_64.iso")},
+		DnsmasqConfigPath: filepath.Join(dir, "bip.conf"),
+	}
+}
+
+// addresslessTarget is a target that does not know the address of its machine
+type addresslessTarget struct{}
+
+func (addresslessTarget) Name() string                               { return "addressless target" }
+func (addresslessTarget) Boot(ctx context.Context, iso string) error { return nil }
+func (addresslessTarget) Wait(ctx context.Context) error             { return nil }
+func (addresslessTarget) Close() error                               { return nil }
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(spec *BootstrapInPlaceSpec)
+		invocations []runner.Invocation
+		wantErr     string
+	}{
+		{
+			name:        "iso onto a vm",
+			invocations: []runner.Invocation{reloadNetworkManager},
+		},
+		{
+			name:    "missing iso",
+			modify:  func(spec *BootstrapInPlaceSpec) { spec.IsoSpec.IsoPath = filepath.Join(spec.Workdir, "missing.iso") },
+			wantErr: "could not find the installer iso",
+		},
+		{
+			name:    "target without an address",
+			modify:  func(spec *BootstrapInPlaceSpec) { spec.Target = addresslessTarget{} },
+			wantErr: "the address of addressless target is not known",
+		},
+		{
+			name:    "vm without a network",
+			modify:  func(spec *BootstrapInPlaceSpec) { spec.MachineConfig.Network = nil },
+			wantErr: "a network domain is required",
+		},
+		{
+			// No command is replayed, generating the iso would fail on running the installer
+			name: "host without the memory of the vm",
+			modify: func(spec *BootstrapInPlaceSpec) {
+				spec.IsoSpec.IsoPath = ""
+				spec.MachineConfig.RAM = 1024 * 1024 * 1024
+			},
+			wantErr: "pre-flight checks failed: memory",
+		},
+		{
+			name: "missing installer",
+			modify: func(spec *BootstrapInPlaceSpec) {
+				spec.IsoSpec.IsoPath = ""
+				spec.IsoSpec.AbiPath = filepath.Join(spec.Workdir, "openshift-install")
+			},
+			wantErr: "pre-flight checks failed: openshift-install: not found",
+		},
+		{
+			name:        "networkmanager reload fails",
+			invocations: []runner.Invocation{{Name: reloadNetworkManager.Name, Args: reloadNetworkManager.Args, ExitCode: 1}},
+			wantErr:     "systemctl exited with code 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := hypervisortest.UseFake(t, machines.GetDefaultVirtualMachineDiskSpec().Pool)
+			replayer := useReplayer(t, tt.invocations...)
+
+			spec := newTestSpec(t)
+			if tt.modify != nil {
+				tt.modify(spec)
+			}
+
+			err := Run(ctx, spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+
+				if _, err := fake.LookupDomain(ctx, spec.MachineConfig.Name); err == nil {
+					t.Errorf("the vm was created although the workflow failed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if remaining := replayer.Remaining(); remaining != 0 {
+				t.Errorf("%d commands were not run", remaining)
+			}
+
+			if active, err := fake.DomainIsActive(ctx, spec.MachineConfig.Name); err != nil || !active {
+				t.Errorf("domain active = %v, %v, want it running", active, err)
+			}
+
+			netspec, err := network.Find(ctx, spec.MachineConfig.Network.Name)
+			if err != nil {
+				t.Fatalf("the vm network was not created: %v", err)
+			}
+			if len(netspec.Hosts) != 1 || netspec.Hosts[0].IpAddress != network.DEFAULT_HOST_IP {
+				t.Errorf("dhcp hosts = %+v, want %s", netspec.Hosts, network.DEFAULT_HOST_IP)
+			}
+
+			data, err := os.ReadFile(spec.DnsmasqConfigPath)
+			if err != nil {
+				t.Fatalf("the dnsmasq config was not written: %v", err)
+			}
+			wantAddress := "address=/api." + spec.MachineConfig.Name + "." + network.DEFAULT_DOMAIN + "/" + network.DEFAULT_HOST_IP
+			if !strings.Contains(string(data), wantAddress) {
+				t.Errorf("dnsmasq config = %q, want %q", data, wantAddress)
+			}
+
+			recordPath := filepath.Join(spec.Workdir, logger.DEFAULT_LOG_FOLDER_NAME, RUN_RECORD_FILE)
+			if _, err := os.Stat(recordPath); err != nil {
+				t.Errorf("the run record was not written: %v", err)
+			}
+		})
+	}
+}
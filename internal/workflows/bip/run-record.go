@@ -0,0 +1,39 @@
+package bip
+
+import (
+	"fmt"
+	"snoman/internal/logger"
+	"snoman/internal/release"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const RUN_RECORD_FILE = "run-record.yaml"
+
+// RunRecord is what a run installed, it is written to the logs folder so runs can be compared and repeated
+type RunRecord struct {
+	Started time.Time `yaml:"started"`
+	Cluster string    `yaml:"cluster"`
+	Arch    string    `yaml:"arch,omitempty"`
+	// Release is pinned by digest unless the registry could not be reached
+	Release *release.Release `yaml:"release,omitempty"`
+	IsoPath string           `yaml:"iso_path,omitempty"`
+}
+
+func writeRunRecord(spec *BootstrapInPlaceSpec, started time.Time) error {
+	record := &RunRecord{
+		Started: started,
+		Cluster: spec.MachineConfig.Name,
+		Arch:    spec.IsoSpec.OpenshiftArch,
+		Release: spec.IsoSpec.ResolvedRelease,
+		IsoPath: spec.IsoSpec.IsoPath,
+	}
+
+	data, err := yaml.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("unable to generate the run record: %w", err)
+	}
+
+	return logger.WriteLogFile(data, spec.Workdir, RUN_RECORD_FILE)
+}
@@ -2,13 +2,35 @@ package bip
 
 import (
 	"snoman/internal/biputils"
+	"snoman/internal/biputils/installconfig"
+	"snoman/internal/chaos"
+	"snoman/internal/proxy"
+	"snoman/internal/targets"
 	"snoman/internal/vms/machines"
 )
 
 type BootstrapInPlaceSpec struct {
-	MachineConfig *machines.VirtualMachineSpec
-	PullSecret    string
-	PublicKey     string
-	Workdir       string
-	IsoSpec       *biputils.BootstrapInPlaceIsoSpec
+	MachineConfig     *machines.VirtualMachineSpec
+	PullSecret        string
+	PublicKey         string
+	Workdir           string
+	IsoSpec           *biputils.BootstrapInPlaceIsoSpec
+	Target            targets.Provider // Defaults to a libvirt VM built from MachineConfig
+	Pxe               *PxeSpec         // When set, the VM network boots instead of using an ISO
+	Chaos             *chaos.ScheduleSpec
+	Mirror            *installconfig.MirrorSpec // When set, the cluster is installed from a mirror registry
+	Proxy             *installconfig.ProxySpec  // When set, the cluster and openshift-install use the proxy
+	ServeProxy        *proxy.Spec               // When set, the cluster uses the built-in proxy on the VM network gateway
+	DnsmasqConfigPath string                    // Defaults to biputils.BIP_NETWORK_CONF_FILE, which needs root
+}
+
+// Workflow phases chaos faults can wait on
+const (
+	PHASE_INSTALLER_GENERATED = "installer-generated"
+	PHASE_BOOTING             = "booting"
+)
+
+type PxeSpec struct {
+	HttpPort uint
+	TftpPort uint // 0 disables TFTP
 }